@@ -1,12 +1,14 @@
 package cfg
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"time"
@@ -14,10 +16,12 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/go-jira"
 	"github.com/dghubble/oauth1"
-	"github.com/fsnotify/fsnotify"
+	"github.com/indeedeng/issue-sync/lib/utils"
+	"github.com/pelletier/go-toml"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
+	"gopkg.in/yaml.v2"
 )
 
 // dateFormat is the format used for the `since` configuration parameter
@@ -38,8 +42,135 @@ const (
 	GitHubCommits   FieldKey = iota
 	LastISUpdate    FieldKey = iota
 	GitHubIssueData FieldKey = iota
+	EpicLink        FieldKey = iota
 )
 
+// Hierarchy mapping modes, used as values of the `hierarchy-mapping` option.
+const (
+	HierarchyToEpicLink  = "epic-link"
+	HierarchyToParent    = "parent"
+	HierarchyToIssueLink = "issue-link"
+)
+
+// Reference rewriting formats, used as values of the `rewrite-references` option.
+const (
+	ReferencesToKey       = "key"
+	ReferencesToSmartLink = "smart-link"
+)
+
+// Milestone mapping modes, used as values of the `milestone-mapping` option.
+const (
+	MilestoneToFixVersion = "fix-version"
+	MilestoneToSprint     = "sprint"
+)
+
+// Fields which can be synced from JIRA back to GitHub, used as values of the
+// `reverse-sync` option.
+const (
+	ReverseSyncStatus     = "status"
+	ReverseSyncPriority   = "priority"
+	ReverseSyncFixVersion = "fix-version"
+	ReverseSyncComments   = "comments"
+)
+
+// Conflict resolution policies, used as values of the `conflict-policy` option.
+const (
+	ConflictGitHubWins  = "github-wins"
+	ConflictJIRAWins    = "jira-wins"
+	ConflictSkipAndFlag = "skip-and-flag"
+	ConflictMerge       = "merge"
+)
+
+// ConflictFields are the JIRA fields which a conflict policy can be set for.
+var ConflictFields = []string{"summary", "description"}
+
+// Policies for JIRA issues whose GitHub issue stops matching the issue filters,
+// used as values of the `unmatched-policy` option.
+const (
+	UnmatchedLeave  = "leave"
+	UnmatchedClose  = "close"
+	UnmatchedUnlink = "unlink"
+)
+
+// defaultUnmatchedCloseStatus is the JIRA status issues are moved to when their
+// GitHub issue stops matching the filters, if `unmatched-close-status` isn't set.
+const defaultUnmatchedCloseStatus = "Done"
+
+// defaultConflictLabel is the JIRA label added to issues with a conflict, if
+// `conflict-label` isn't set.
+const defaultConflictLabel = "issue-sync-conflict"
+
+// defaultPriorityLabelPrefix is the prefix of the GitHub labels which JIRA priorities
+// are synced to, if `priority-label-prefix` isn't set.
+const defaultPriorityLabelPrefix = "priority: "
+
+// defaultPublicCommentPrefix is the prefix which marks a JIRA comment as public,
+// so that it's copied to GitHub.
+const defaultPublicCommentPrefix = "[public]"
+
+// defaultGitHubHTMLURL is the base URL of GitHub's web pages, unless
+// `github-base-url` is set.
+const defaultGitHubHTMLURL = "https://github.com"
+
+// Log formats, set with `log-format`.
+const (
+	// LogFormatText logs human-readable lines.
+	LogFormatText = "text"
+	// LogFormatJSON logs a JSON object per line.
+	LogFormatJSON = "json"
+)
+
+// defaultSecretsFile is the file beside the configuration file which holds the
+// credentials, unless `secrets-file` is set.
+const defaultSecretsFile = "issue-sync-secrets.json"
+
+// defaultSinceOverlap is how far before the last run the next one lists GitHub
+// issues from, unless `since-overlap` is set.
+const defaultSinceOverlap = time.Minute
+
+// Defaults of the retry queue options.
+const (
+	defaultRetryQueueFile   = "issue-sync-failures.json"
+	defaultRetryBackoff     = 5 * time.Minute
+	defaultRetryMaxBackoff  = 24 * time.Hour
+	defaultRetryMaxAttempts = 10
+)
+
+// Defaults of the notification options.
+const (
+	defaultNotifyIssueFailures  = 3
+	defaultNotifyRepeatInterval = 24 * time.Hour
+	defaultNotifyMaxPerHour     = 4
+)
+
+// JIRA authentication methods, set with `jira-auth-method`.
+const (
+	// JIRAAuthBasic authenticates with a username and password.
+	JIRAAuthBasic = "basic"
+	// JIRAAuthAPIToken authenticates to JIRA Cloud with an email and API token.
+	JIRAAuthAPIToken = "api-token"
+	// JIRAAuthPAT authenticates to JIRA Data Center with a personal access token.
+	JIRAAuthPAT = "pat"
+	// JIRAAuthOAuth1 authenticates with an OAuth 1.0a application link.
+	JIRAAuthOAuth1 = "oauth1"
+	// JIRAAuthOAuth2 authenticates to JIRA Cloud with an OAuth 2.0 (3LO) app.
+	JIRAAuthOAuth2 = "oauth2"
+)
+
+// Versions of the JIRA REST API, set with `jira-api-version`.
+const (
+	// JIRAAPIVersionAuto uses version 3 for JIRA Cloud, and version 2 otherwise.
+	JIRAAPIVersionAuto = "auto"
+	// JIRAAPIVersion2 sends rich text as wiki markup.
+	JIRAAPIVersion2 = "2"
+	// JIRAAPIVersion3 sends rich text as Atlassian Document Format documents.
+	JIRAAPIVersion3 = "3"
+)
+
+// secretKeys are the options which hold credentials. SaveConfig saves them to the
+// `secrets-file`, never to the configuration file.
+var secretKeys []string
+
 // Config is the root configuration object the application creates.
 type Config struct {
 	// cmdFile is the file Viper is using for its configuration (default $HOME/.issue-sync.json).
@@ -50,9 +181,6 @@ type Config struct {
 	// log is a logger set up with the configured log level, app name, etc.
 	log logrus.Entry
 
-	// basicAuth represents whether we're using HTTP Basic authentication or OAuth.
-	basicAuth bool
-
 	// fieldIDs is the list of custom fields we pulled from the `fields` JIRA endpoint.
 	fieldIDs map[FieldKey]string
 
@@ -64,12 +192,79 @@ type Config struct {
 	since time.Time
 
 	fieldMapper FieldMapper
+
+	// secrets records where each credential was set, so that SaveConfig never
+	// writes those which weren't set in a file.
+	secrets map[string]secret
+
+	// noPrompt is whether validation reports missing credentials rather than
+	// prompting for them.
+	noPrompt bool
+	// jiraPassPrompted is whether the JIRA password was entered at the prompt,
+	// so that it's kept when the configuration is reloaded.
+	jiraPassPrompted bool
+}
+
+// ConfigErrors are all the problems found validating a configuration.
+type ConfigErrors []error
+
+// Error lists the problems.
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	if len(e) == 1 {
+		return messages[0]
+	}
+	return fmt.Sprintf("%d configuration problems: %s", len(e), strings.Join(messages, "; "))
 }
 
 // NewConfig creates a new, immutable configuration object. This object
 // holds the Viper configuration and the logger, and is validated. The
 // JIRA configuration is not yet initialized.
 func NewConfig(cmd *cobra.Command) (Config, error) {
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return Config{}, err
+	}
+
+	warnings, errs := config.problems()
+	for _, warning := range warnings {
+		config.log.Warn(warning)
+	}
+	if len(errs) > 0 {
+		return Config{}, errs
+	}
+
+	return config, nil
+}
+
+// CheckConfig loads the configuration like NewConfig, without prompting for
+// missing credentials, and returns every problem found in it: warnings, such as
+// unknown keys in the configuration file, and errors, which NewConfig would
+// fail with.
+func CheckConfig(cmd *cobra.Command) ([]string, []error) {
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return nil, []error{err}
+	}
+	config.noPrompt = true
+
+	warnings, errs := config.problems()
+	return warnings, errs
+}
+
+// LoadConfig creates a configuration object from the command line and
+// configuration file like NewConfig, without validating it, for commands which
+// only read local settings and never call GitHub or JIRA.
+func LoadConfig(cmd *cobra.Command) (Config, error) {
+	return loadConfig(cmd)
+}
+
+// loadConfig creates a configuration object from the command line and
+// configuration file, without validating it.
+func loadConfig(cmd *cobra.Command) (Config, error) {
 	config := Config{}
 
 	var err error
@@ -86,15 +281,93 @@ func NewConfig(cmd *cobra.Command) (Config, error) {
 
 	config.cmdFile = config.cmdConfig.ConfigFileUsed()
 
-	config.log = *NewLogger("issue-sync", config.cmdConfig.GetString("log-level"))
+	config.log = *NewLoggerWithFormat("issue-sync", config.cmdConfig.GetString("log-level"), config.GetLogFormat())
 
-	if err := config.validateConfig(); err != nil {
+	config.secrets, err = loadSecrets(&config.cmdConfig, config.cmdFile, config.GetSecretsFile())
+	if err != nil {
 		return Config{}, err
 	}
 
 	return config, nil
 }
 
+// NewConfigFromSettings creates a configuration object holding the given
+// settings, keyed by their long option names, rather than those of the command
+// line and configuration file. Unlike NewConfig, it isn't validated; call
+// Validate once every required setting is present.
+func NewConfigFromSettings(settings map[string]interface{}) Config {
+	config := Config{}
+
+	config.cmdConfig = *viper.New()
+	for key, value := range settings {
+		config.cmdConfig.Set(key, value)
+	}
+
+	config.log = *NewLoggerWithFormat("issue-sync", config.cmdConfig.GetString("log-level"), config.GetLogFormat())
+
+	secrets, err := loadSecrets(&config.cmdConfig, "", "")
+	if err != nil {
+		config.log.Warn(err)
+	}
+	config.secrets = secrets
+
+	return config
+}
+
+// Validate checks that the configuration is complete and valid.
+func (c *Config) Validate() error {
+	if errs := c.validateConfig(); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// problems returns the warnings and errors found in the configuration file and
+// in the values of the options.
+func (c *Config) problems() ([]string, ConfigErrors) {
+	warnings, errs := c.checkConfigFile()
+	return warnings, append(errs, c.validateConfig()...)
+}
+
+// checkConfigFile checks the configuration and secrets files on their own: keys
+// which aren't options, or are deprecated, are warned about, and values which
+// can't be read as the type of their option are errors.
+func (c *Config) checkConfigFile() ([]string, ConfigErrors) {
+	var warnings []string
+	var errs ConfigErrors
+
+	for _, path := range []string{c.cmdFile, c.GetSecretsFile()} {
+		if path == "" {
+			continue
+		}
+		file, err := readConfigFile(path)
+		if os.IsNotExist(err) {
+			// Missing files are created by SaveConfig.
+			continue
+		} else if err != nil {
+			errs = append(errs, fmt.Errorf("unable to read %s: %v", path, err))
+			continue
+		}
+
+		for _, key := range unknownKeys(file) {
+			warnings = append(warnings, fmt.Sprintf("unknown option %s in %s", key, path))
+		}
+		if file.GetString("json-field-mapper") != "" {
+			warnings = append(warnings, fmt.Sprintf("json-field-mapper in %s is ignored; set github-to-jira-field-mapper instead", path))
+		}
+		for _, o := range Options {
+			if !file.IsSet(o.Name) {
+				continue
+			}
+			if err := checkOptionType(o, file.Get(o.Name)); err != nil {
+				errs = append(errs, fmt.Errorf("%v in %s", err, path))
+			}
+		}
+	}
+
+	return warnings, errs
+}
+
 // LoadJIRAConfig loads the JIRA configuration (project key,
 // custom field IDs) from a remote JIRA server.
 func (c *Config) LoadJIRAConfig(client jira.Client) error {
@@ -120,7 +393,7 @@ func (c *Config) LoadJIRAConfig(client jira.Client) error {
 	}
 	c.project = *proj
 
-	if c.cmdConfig.GetString("github-to-jira-field-mapper") == "json-field-mapper" {
+	if c.usesJSONFieldMapper() {
 		c.fieldMapper = JsonFieldMapper{
 			Config: c,
 		}
@@ -135,6 +408,10 @@ func (c *Config) LoadJIRAConfig(client jira.Client) error {
 		return err
 	}
 
+	if c.GetHierarchyMapping() == HierarchyToEpicLink && c.GetFieldID(EpicLink) == "" {
+		return errors.New("could not find ID of 'Epic Link' custom field; check that JIRA Software is installed")
+	}
+
 	return nil
 }
 
@@ -148,10 +425,110 @@ func (c Config) GetConfigString(key string) string {
 	return c.cmdConfig.GetString(key)
 }
 
-// IsBasicAuth is true if we're using HTTP Basic Authentication, and false if
-// we're using OAuth.
+// IsBasicAuth is true if we're using HTTP Basic Authentication with a username
+// and password.
 func (c Config) IsBasicAuth() bool {
-	return c.basicAuth
+	return c.GetJIRAAuthMethod() == JIRAAuthBasic
+}
+
+// UsesGitHubApp is true if we authenticate to GitHub as an installation of a
+// GitHub App, and false if we use `github-token`.
+func (c Config) UsesGitHubApp() bool {
+	return c.GetGitHubAppID() != 0
+}
+
+// GetGitHubAppID returns the ID of the GitHub App we authenticate as, or 0 if we
+// use `github-token`.
+func (c Config) GetGitHubAppID() int64 {
+	return c.cmdConfig.GetInt64("github-app-id")
+}
+
+// GetGitHubAppInstallationID returns the ID of the installation of the GitHub App
+// we authenticate as, or 0 if the installation on the repository should be used.
+func (c Config) GetGitHubAppInstallationID() int64 {
+	return c.cmdConfig.GetInt64("github-app-installation-id")
+}
+
+// GetGitHubBaseURL returns the URL of the GitHub REST API, which is only set for
+// GitHub Enterprise Server (e.g. https://github.example.com/api/v3/); an empty
+// string means github.com.
+func (c Config) GetGitHubBaseURL() string {
+	return c.cmdConfig.GetString("github-base-url")
+}
+
+// GetGitHubUploadURL returns the URL of the GitHub upload API. For GitHub
+// Enterprise Server, it defaults to the base URL.
+func (c Config) GetGitHubUploadURL() string {
+	if u := c.cmdConfig.GetString("github-upload-url"); u != "" {
+		return u
+	}
+	return c.GetGitHubBaseURL()
+}
+
+// GetGitHubHTMLURL returns the base URL of GitHub's web pages, without a trailing
+// slash: https://github.com, or the host of the GitHub Enterprise Server API.
+func (c Config) GetGitHubHTMLURL() string {
+	base, err := url.Parse(c.GetGitHubBaseURL())
+	if err != nil || base.Host == "" {
+		return defaultGitHubHTMLURL
+	}
+	return fmt.Sprintf("%s://%s", base.Scheme, base.Host)
+}
+
+// GetGitHubTransportOptions returns the network settings of the connections to
+// GitHub.
+func (c Config) GetGitHubTransportOptions() utils.TransportOptions {
+	return c.getTransportOptions("github")
+}
+
+// GetJIRATransportOptions returns the network settings of the connections to
+// JIRA.
+func (c Config) GetJIRATransportOptions() utils.TransportOptions {
+	return c.getTransportOptions("jira")
+}
+
+// GetNotifyTransportOptions returns the network settings of the connections to
+// the notification webhooks.
+func (c Config) GetNotifyTransportOptions() utils.TransportOptions {
+	return c.getTransportOptions("notify")
+}
+
+// getTransportOptions returns the network settings set with the options of a
+// backend, e.g. `jira-proxy` for "jira".
+func (c Config) getTransportOptions(backend string) utils.TransportOptions {
+	return utils.TransportOptions{
+		Proxy:              c.cmdConfig.GetString(backend + "-proxy"),
+		NoProxy:            c.cmdConfig.GetStringSlice(backend + "-no-proxy"),
+		CABundle:           c.cmdConfig.GetString(backend + "-ca-bundle"),
+		ClientCert:         c.cmdConfig.GetString(backend + "-client-cert"),
+		ClientKey:          c.cmdConfig.GetString(backend + "-client-key"),
+		InsecureSkipVerify: c.cmdConfig.GetBool(backend + "-insecure-skip-verify"),
+		Headers:            c.cmdConfig.GetStringMapString(backend + "-headers"),
+	}
+}
+
+// GetJIRAAuthMethod returns how we authenticate to JIRA: JIRAAuthBasic,
+// JIRAAuthAPIToken, JIRAAuthPAT, JIRAAuthOAuth1 or JIRAAuthOAuth2. If
+// `jira-auth-method` isn't set, basic authentication is used if a username and
+// password are provided, and OAuth 1.0a otherwise.
+func (c Config) GetJIRAAuthMethod() string {
+	if method := c.cmdConfig.GetString("jira-auth-method"); method != "" {
+		return method
+	}
+	if (c.cmdConfig.GetString("jira-user") != "") && (c.cmdConfig.GetString("jira-pass") != "") {
+		return JIRAAuthBasic
+	}
+	return JIRAAuthOAuth1
+}
+
+// GetJIRAAPIVersion returns the version of the JIRA REST API to use:
+// JIRAAPIVersion2, JIRAAPIVersion3, or JIRAAPIVersionAuto, the default, to
+// detect it from the server.
+func (c Config) GetJIRAAPIVersion() string {
+	if version := c.cmdConfig.GetString("jira-api-version"); version != "" {
+		return version
+	}
+	return JIRAAPIVersionAuto
 }
 
 // GetSinceParam returns the `since` configuration parameter, parsed as a time.Time.
@@ -164,6 +541,22 @@ func (c Config) GetLogger() logrus.Entry {
 	return c.log
 }
 
+// GetLogFormat returns the format of the logs, LogFormatText unless
+// `log-format` is set.
+func (c Config) GetLogFormat() string {
+	if format := c.cmdConfig.GetString("log-format"); format != "" {
+		return format
+	}
+	return LogFormatText
+}
+
+// WithLogFields returns a copy of the configuration whose logger adds fields to
+// every message, such as those of the issue being synced.
+func (c Config) WithLogFields(fields logrus.Fields) Config {
+	c.log = *c.log.WithFields(fields)
+	return c
+}
+
 // IsDryRun returns whether the application is running in dry-run mode or not.
 func (c Config) IsDryRun() bool {
 	return c.cmdConfig.GetBool("dry-run")
@@ -187,6 +580,127 @@ func (c Config) GetDaemonPeriod() time.Duration {
 	return c.cmdConfig.GetDuration("period")
 }
 
+// GetReportFile returns the path the JSON report of each run is written to, if
+// `report-file` is set.
+func (c Config) GetReportFile() string {
+	return c.cmdConfig.GetString("report-file")
+}
+
+// GetFailureThreshold returns how many issues can fail to sync before a one-shot
+// run exits with an error.
+func (c Config) GetFailureThreshold() int {
+	return c.cmdConfig.GetInt("failure-threshold")
+}
+
+// GetNotifyWebhookURL returns the URL failures and recoveries are posted to as
+// JSON, if `notify-webhook-url` is set.
+func (c Config) GetNotifyWebhookURL() string {
+	return c.cmdConfig.GetString("notify-webhook-url")
+}
+
+// GetNotifySlackURL returns the URL of the Slack-compatible incoming webhook
+// failures and recoveries are posted to, if `notify-slack-url` is set.
+func (c Config) GetNotifySlackURL() string {
+	return c.cmdConfig.GetString("notify-slack-url")
+}
+
+// GetNotifyIssueFailures returns how many runs in a row an issue must fail to
+// sync in before it's notified.
+func (c Config) GetNotifyIssueFailures() int {
+	if !c.cmdConfig.IsSet("notify-issue-failures") {
+		return defaultNotifyIssueFailures
+	}
+	return c.cmdConfig.GetInt("notify-issue-failures")
+}
+
+// GetNotifyRepeatInterval returns how long until a persisting problem is
+// notified again.
+func (c Config) GetNotifyRepeatInterval() time.Duration {
+	if !c.cmdConfig.IsSet("notify-repeat-interval") {
+		return defaultNotifyRepeatInterval
+	}
+	return c.cmdConfig.GetDuration("notify-repeat-interval")
+}
+
+// GetNotifyMaxPerHour returns the maximum number of notifications sent per hour.
+func (c Config) GetNotifyMaxPerHour() int {
+	if !c.cmdConfig.IsSet("notify-max-per-hour") {
+		return defaultNotifyMaxPerHour
+	}
+	return c.cmdConfig.GetInt("notify-max-per-hour")
+}
+
+// GetNotifyStateFile returns the path of the file the notified problems are kept
+// in, if `notify-state-file` is set.
+func (c Config) GetNotifyStateFile() string {
+	return c.cmdConfig.GetString("notify-state-file")
+}
+
+// GetRetryQueueFile returns the path of the file GitHub issues which failed to
+// sync are queued in: `retry-queue-file`, or issue-sync-failures.json beside the
+// configuration file.
+func (c Config) GetRetryQueueFile() string {
+	if path := c.cmdConfig.GetString("retry-queue-file"); path != "" {
+		return path
+	}
+	return filepath.Join(filepath.Dir(c.cmdConfig.ConfigFileUsed()), defaultRetryQueueFile)
+}
+
+// GetSecretsFile returns the path of the file holding the credentials.
+func (c Config) GetSecretsFile() string {
+	return secretsFilePath(&c.cmdConfig)
+}
+
+// secretsFilePath returns the `secrets-file` of a Viper configuration, or
+// `issue-sync-secrets.json` beside its configuration file.
+func secretsFilePath(v *viper.Viper) string {
+	if path := v.GetString("secrets-file"); path != "" {
+		return path
+	}
+	return DefaultSecretsFile(v.ConfigFileUsed())
+}
+
+// DefaultSecretsFile returns the path of the secrets file of a configuration
+// file if `secrets-file` isn't set: `issue-sync-secrets.json` beside it.
+func DefaultSecretsFile(configPath string) string {
+	return filepath.Join(filepath.Dir(configPath), defaultSecretsFile)
+}
+
+// GetRetryBackoff returns how long until an issue which failed to sync is
+// retried the first time.
+func (c Config) GetRetryBackoff() time.Duration {
+	if !c.cmdConfig.IsSet("retry-backoff") {
+		return defaultRetryBackoff
+	}
+	return c.cmdConfig.GetDuration("retry-backoff")
+}
+
+// GetRetryMaxBackoff returns the maximum time between two retries of an issue.
+func (c Config) GetRetryMaxBackoff() time.Duration {
+	if !c.cmdConfig.IsSet("retry-max-backoff") {
+		return defaultRetryMaxBackoff
+	}
+	return c.cmdConfig.GetDuration("retry-max-backoff")
+}
+
+// GetRetryMaxAttempts returns how many times an issue is retried before it's
+// left in the retry queue as a dead letter.
+func (c Config) GetRetryMaxAttempts() int {
+	if !c.cmdConfig.IsSet("retry-max-attempts") {
+		return defaultRetryMaxAttempts
+	}
+	return c.cmdConfig.GetInt("retry-max-attempts")
+}
+
+// GetSinceOverlap returns how far the `since` date is moved back from the last
+// GitHub update synced, so that issues GitHub updated concurrently are listed again.
+func (c Config) GetSinceOverlap() time.Duration {
+	if !c.cmdConfig.IsSet("since-overlap") {
+		return defaultSinceOverlap
+	}
+	return c.cmdConfig.GetDuration("since-overlap")
+}
+
 // GetTimeout returns the configured timeout on all API calls, parsed as a time.Duration.
 func (c Config) GetTimeout() time.Duration {
 	return c.cmdConfig.GetDuration("timeout")
@@ -228,6 +742,223 @@ func (c Config) GetFieldMapper() FieldMapper {
 	return c.fieldMapper
 }
 
+// usesJSONFieldMapper returns whether GitHub data is stored as JSON in a single
+// JIRA field, rather than in a field per value.
+func (c Config) usesJSONFieldMapper() bool {
+	return c.cmdConfig.GetString("github-to-jira-field-mapper") == FieldMapperJSON
+}
+
+// GetIssueTypeName returns the JIRA issue type the configured field mapper
+// creates issues with.
+func (c Config) GetIssueTypeName() string {
+	if c.usesJSONFieldMapper() {
+		return jsonFieldMapperIssueType
+	}
+	return defaultFieldMapperIssueType
+}
+
+// GetCustomFields returns the JIRA custom fields which the configured field mapper
+// stores GitHub data in.
+func (c Config) GetCustomFields() []CustomField {
+	if c.usesJSONFieldMapper() {
+		return []CustomField{
+			newCustomField(paragraphField, "GitHub Issue Data", true),
+		}
+	}
+
+	return []CustomField{
+		newCustomField(numberField, "GitHub ID", true),
+		newCustomField(numberField, "GitHub Number", true),
+		newCustomField(textField, "GitHub Labels", true),
+		newCustomField(textField, "GitHub Status", true),
+		newCustomField(textField, "GitHub Reporter", true),
+		newCustomField(dateTimeField, "Last Issue-Sync Update", true),
+		newCustomField(paragraphField, "GitHub Commits", false),
+	}
+}
+
+// GetMilestoneMapping returns how GitHub milestones are reflected in JIRA: either
+// MilestoneToFixVersion, MilestoneToSprint, or an empty string if milestones are ignored.
+func (c Config) GetMilestoneMapping() string {
+	return c.cmdConfig.GetString("milestone-mapping")
+}
+
+// CreateFixVersions returns whether a missing JIRA version should be created in the
+// project when an issue's milestone is mapped to a fixVersion.
+func (c Config) CreateFixVersions() bool {
+	return c.cmdConfig.GetBool("create-fix-versions")
+}
+
+// GetHierarchyMapping returns how GitHub parent/child relationships are reflected
+// in JIRA: HierarchyToEpicLink, HierarchyToParent, HierarchyToIssueLink, or an
+// empty string if they are ignored.
+func (c Config) GetHierarchyMapping() string {
+	return c.cmdConfig.GetString("hierarchy-mapping")
+}
+
+// GetHierarchyLinkType returns the name of the JIRA issue link type used to link
+// children to their parents when the hierarchy is mapped to issue links.
+func (c Config) GetHierarchyLinkType() string {
+	return c.cmdConfig.GetString("hierarchy-link-type")
+}
+
+// GetReferenceRewriting returns how references to synced GitHub issues in bodies
+// and comments are rewritten: ReferencesToKey, ReferencesToSmartLink, or an empty
+// string if references are left untouched.
+func (c Config) GetReferenceRewriting() string {
+	return c.cmdConfig.GetString("rewrite-references")
+}
+
+// GetReferenceLinkType returns the name of the JIRA issue link type used to link
+// issues which GitHub reports as cross-referenced, or an empty string if they are
+// not linked.
+func (c Config) GetReferenceLinkType() string {
+	return c.cmdConfig.GetString("reference-link-type")
+}
+
+// SyncRemoteLinks returns whether JIRA issues should get remote links to their
+// GitHub issue and to the pull requests and commits which reference it.
+func (c Config) SyncRemoteLinks() bool {
+	return c.cmdConfig.GetBool("remote-links")
+}
+
+// AddDevelopmentSection returns whether JIRA descriptions should end with a section
+// listing the commits and pull requests which reference the GitHub issue.
+func (c Config) AddDevelopmentSection() bool {
+	return c.cmdConfig.GetBool("development-section")
+}
+
+// GetReverseSyncFields returns the fields which are synced from JIRA back to GitHub;
+// each is one of ReverseSyncStatus, ReverseSyncPriority, ReverseSyncFixVersion or
+// ReverseSyncComments.
+func (c Config) GetReverseSyncFields() []string {
+	return c.cmdConfig.GetStringSlice("reverse-sync")
+}
+
+// ReverseSyncs returns whether the given field is synced from JIRA back to GitHub.
+func (c Config) ReverseSyncs(field string) bool {
+	for _, f := range c.GetReverseSyncFields() {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPriorityLabelPrefix returns the prefix of the GitHub labels which JIRA
+// priorities are synced to.
+func (c Config) GetPriorityLabelPrefix() string {
+	if prefix := c.cmdConfig.GetString("priority-label-prefix"); prefix != "" {
+		return prefix
+	}
+	return defaultPriorityLabelPrefix
+}
+
+// GetPublicCommentPrefix returns the prefix which marks a JIRA comment as public;
+// only comments starting with it are copied to GitHub.
+func (c Config) GetPublicCommentPrefix() string {
+	if prefix := c.cmdConfig.GetString("public-comment-prefix"); prefix != "" {
+		return prefix
+	}
+	return defaultPublicCommentPrefix
+}
+
+// GetConflictPolicy returns how a change made in JIRA to the given field is
+// handled when the GitHub value has also changed since the last sync. It
+// defaults to ConflictGitHubWins, which overwrites the JIRA value.
+func (c Config) GetConflictPolicy(field string) string {
+	if policy := c.cmdConfig.GetStringMapString("conflict-policy")[field]; policy != "" {
+		return policy
+	}
+	return ConflictGitHubWins
+}
+
+// DetectsConflicts returns whether any field has a conflict policy other than
+// ConflictGitHubWins, in which case issue-sync records what it writes to JIRA.
+func (c Config) DetectsConflicts() bool {
+	for _, f := range ConflictFields {
+		if c.GetConflictPolicy(f) != ConflictGitHubWins {
+			return true
+		}
+	}
+	return false
+}
+
+// GetConflictLabel returns the JIRA label added to issues with a conflict which
+// was skipped and flagged.
+func (c Config) GetConflictLabel() string {
+	if label := c.cmdConfig.GetString("conflict-label"); label != "" {
+		return label
+	}
+	return defaultConflictLabel
+}
+
+// GetIncludeLabels returns the GitHub labels an issue must have at least one of
+// to be synced. If empty, issues aren't filtered by label.
+func (c Config) GetIncludeLabels() []string {
+	return c.cmdConfig.GetStringSlice("include-labels")
+}
+
+// GetExcludeLabels returns the GitHub labels which prevent an issue from being synced.
+func (c Config) GetExcludeLabels() []string {
+	return c.cmdConfig.GetStringSlice("exclude-labels")
+}
+
+// GetIssueState returns the state GitHub issues must be in to be synced: "open",
+// "closed", or "all".
+func (c Config) GetIssueState() string {
+	if state := c.cmdConfig.GetString("issue-state"); state != "" {
+		return state
+	}
+	return "all"
+}
+
+// GetExcludeAuthors returns the logins of the GitHub users whose issues aren't synced.
+func (c Config) GetExcludeAuthors() []string {
+	return c.cmdConfig.GetStringSlice("exclude-authors")
+}
+
+// ExcludeBots returns whether issues opened by bots aren't synced.
+func (c Config) ExcludeBots() bool {
+	return c.cmdConfig.GetBool("exclude-bots")
+}
+
+// GetIncludeMilestones returns the titles of the GitHub milestones an issue must
+// be in to be synced. If empty, issues aren't filtered by milestone.
+func (c Config) GetIncludeMilestones() []string {
+	return c.cmdConfig.GetStringSlice("include-milestones")
+}
+
+// GetSearchQuery returns a GitHub search query issues must match to be synced,
+// or an empty string if there is none.
+func (c Config) GetSearchQuery() string {
+	return c.cmdConfig.GetString("search-query")
+}
+
+// GetUnmatchedPolicy returns what happens to the JIRA issue of a GitHub issue which
+// stops matching the filters: UnmatchedLeave, UnmatchedClose or UnmatchedUnlink.
+func (c Config) GetUnmatchedPolicy() string {
+	if policy := c.cmdConfig.GetString("unmatched-policy"); policy != "" {
+		return policy
+	}
+	return UnmatchedLeave
+}
+
+// GetUnmatchedCloseStatus returns the JIRA status issues are moved to under the
+// UnmatchedClose policy.
+func (c Config) GetUnmatchedCloseStatus() string {
+	if status := c.cmdConfig.GetString("unmatched-close-status"); status != "" {
+		return status
+	}
+	return defaultUnmatchedCloseStatus
+}
+
+// GetJIRABoardID returns the ID of the JIRA Agile board whose sprints milestones are
+// mapped to.
+func (c Config) GetJIRABoardID() int {
+	return c.cmdConfig.GetInt("jira-board-id")
+}
+
 // SetJIRAToken adds the JIRA OAuth tokens in the Viper configuration, ensuring that they
 // are saved for future runs.
 func (c Config) SetJIRAToken(token *oauth1.Token) {
@@ -235,59 +966,109 @@ func (c Config) SetJIRAToken(token *oauth1.Token) {
 	c.cmdConfig.Set("jira-secret", token.TokenSecret)
 }
 
-// configFile is a serializable representation of the current Viper configuration.
-type configFile struct {
-	LogLevel                string        `json:"log-level" mapstructure:"log-level"`
-	GithubToken             string        `json:"github-token" mapstructure:"github-token"`
-	JIRAUser                string        `json:"jira-user,omitempty" mapstructure:"jira-user"`
-	JIRAPass                string        `json:"jira-pass,omitempty" mapstructure:"jira-pass"`
-	JIRAToken               string        `json:"jira-token,omitempty" mapstructure:"jira-token"`
-	JIRASecret              string        `json:"jira-secret,omitempty" mapstructure:"jira-secret"`
-	JIRAKey                 string        `json:"jira-private-key-path,omitempty" mapstructure:"jira-private-key-path"`
-	JIRACKey                string        `json:"jira-consumer-key,omitempty" mapstructure:"jira-consumer-key"`
-	RepoName                string        `json:"repo-name" mapstructure:"repo-name"`
-	JIRAURI                 string        `json:"jira-uri" mapstructure:"jira-uri"`
-	JIRAProject             string        `json:"jira-project" mapstructure:"jira-project"`
-	Since                   string        `json:"since" mapstructure:"since"`
-	Timeout                 time.Duration `json:"timeout" mapstructure:"timeout"`
-	FullSyncAlways          bool          `json:"full-sync-always" mapstructure:"full-sync-always"`
-	GitHubToJiraFieldMapper string        `json:"json-field-mapper" mapstructure:"json-field-mapper"`
-}
-
-// SaveConfig updates the `since` parameter to now, then saves the configuration file.
-func (c *Config) SaveConfig() error {
-	c.cmdConfig.Set("since", time.Now().Format(DateFormat))
+// SetJIRAOAuth2RefreshToken replaces the JIRA OAuth 2.0 refresh token in the Viper
+// configuration, and saves it at once with SaveSecret. Refresh tokens are rotated
+// on use, so the previous one stops working: the new one must be saved for future
+// runs, even if this one doesn't save its configuration.
+func (c Config) SetJIRAOAuth2RefreshToken(refreshToken string) error {
+	return c.SaveSecret("jira-oauth2-refresh-token", refreshToken)
+}
 
-	var cf configFile
-	var err error
-	err = c.cmdConfig.Unmarshal(&cf)
-	var b []byte
+// SetSinceParam moves the `since` date the next run lists GitHub issues from;
+// it's saved by SaveConfig.
+func (c *Config) SetSinceParam(since time.Time) {
+	c.cmdConfig.Set("since", since.Format(DateFormat))
+	c.since = since
+}
 
-	b, err = json.MarshalIndent(cf, "", "  ")
-	if err != nil {
-		return err
+// SaveConfig saves the configuration file, with the `since` date set by
+// SetSinceParam. The credentials are never saved to it, but to the secrets
+// file; see saveSecrets.
+func (c *Config) SaveConfig() error {
+	config := map[string]interface{}{}
+	for _, o := range Options {
+		if value, set := optionValue(&c.cmdConfig, o); set || o.Required {
+			config[o.Name] = value
+		}
 	}
 
-	f, err := os.OpenFile(c.cmdConfig.ConfigFileUsed(), os.O_RDWR|os.O_TRUNC|os.O_CREATE, 0644)
+	secrets := map[string]interface{}{}
+	save, err := c.saveSecrets(config, secrets)
 	if err != nil {
 		return err
 	}
-
-	defer func() {
-		err := f.Close()
-		if err != nil {
-			c.log.Error(err)
+	if save || c.cmdConfig.GetString("secrets-file") != "" {
+		if err := writeConfigFile(c.GetSecretsFile(), secrets, 0600); err != nil {
+			return err
 		}
-	}()
+	}
 
-	_, err = f.WriteString(string(b))
-	if err != nil {
+	if err := writeConfigFile(c.cmdConfig.ConfigFileUsed(), config, 0600); err != nil {
 		c.log.Error(err)
 	}
 
 	return nil
 }
 
+// WriteConfig writes a new configuration file holding the given settings, keyed
+// by their long option names. Like SaveConfig, it never writes the credentials
+// to the configuration file: they're written to `secretsPath`, which the
+// configuration file refers to, or, if it's empty, to the default secrets file.
+func WriteConfig(path string, settings map[string]interface{}, secretsPath string) error {
+	config := map[string]interface{}{}
+	secrets := map[string]interface{}{}
+	for key, value := range settings {
+		config[key] = value
+	}
+
+	if secretsPath == "" {
+		secretsPath = DefaultSecretsFile(path)
+	}
+	if filepath.Clean(secretsPath) != filepath.Clean(DefaultSecretsFile(path)) {
+		config["secrets-file"] = secretsPath
+	}
+	for _, key := range secretKeys {
+		if value, ok := config[key]; ok {
+			secrets[key] = value
+			delete(config, key)
+		}
+	}
+	if len(secrets) > 0 {
+		if err := writeConfigFile(secretsPath, secrets, 0600); err != nil {
+			return err
+		}
+	}
+
+	return writeConfigFile(path, config, 0600)
+}
+
+// writeConfigFile replaces the contents of a file with settings encoded in the
+// format of its extension (see configFileType), with the given permissions. The
+// file is replaced atomically, and its new contents are recorded so that Watch
+// doesn't reload the configuration because of it.
+func writeConfigFile(path string, settings map[string]interface{}, perm os.FileMode) error {
+	var b []byte
+	var err error
+	switch configFileType(path) {
+	case "yaml":
+		b, err = yaml.Marshal(settings)
+	case "toml":
+		var tree *toml.Tree
+		tree, err = toml.TreeFromMap(settings)
+		if err == nil {
+			b = []byte(tree.String())
+		}
+	default:
+		b, err = json.MarshalIndent(settings, "", "  ")
+	}
+	if err != nil {
+		return err
+	}
+
+	recordFileContents(path, b)
+	return utils.WriteFileAtomic(path, b, perm)
+}
+
 // newViper generates a viper configuration object which
 // merges (in order from highest to lowest priority) the
 // command line options, configuration file options, and
@@ -305,15 +1086,19 @@ func newViper(appName, cfgFile string) *viper.Viper {
 	v.AddConfigPath(".")
 	if cfgFile != "" {
 		v.SetConfigFile(cfgFile)
+		v.SetConfigType(configFileType(cfgFile))
 	}
-	v.SetConfigType("json")
 
 	if err := v.ReadInConfig(); err == nil {
+		if v.GetString("log-format") == LogFormatJSON {
+			log.Formatter = &logrus.JSONFormatter{}
+		}
 		log.WithField("file", v.ConfigFileUsed()).Infof("config file loaded")
-		v.WatchConfig()
-		v.OnConfigChange(func(e fsnotify.Event) {
-			log.WithField("file", e.Name).Info("config file changed")
-		})
+		v.SetConfigType(configFileType(v.ConfigFileUsed()))
+		secretsFile := secretsFilePath(v)
+		if err := mergeSecretsFile(v, secretsFile); err != nil && (v.GetString("secrets-file") != "" || !os.IsNotExist(err)) {
+			log.WithError(err).Warningf("Error reading secrets file: %v", secretsFile)
+		}
 	} else {
 		if cfgFile != "" {
 			log.WithError(err).Warningf("Error reading config file: %v", cfgFile)
@@ -347,8 +1132,17 @@ func parseLogLevel(level string) logrus.Level {
 // to create a new logrus logger and set fields on it to make
 // it easy to use.
 func NewLogger(app, level string) *logrus.Entry {
+	return NewLoggerWithFormat(app, level, LogFormatText)
+}
+
+// NewLoggerWithFormat creates a logger like NewLogger, which logs in the given
+// format: LogFormatText or LogFormatJSON.
+func NewLoggerWithFormat(app, level, format string) *logrus.Entry {
 	logger := logrus.New()
 	logger.Level = parseLogLevel(level)
+	if format == LogFormatJSON {
+		logger.Formatter = &logrus.JSONFormatter{}
+	}
 	logEntry := logrus.NewEntry(logger).WithFields(logrus.Fields{
 		"app": app,
 	})
@@ -361,104 +1155,318 @@ func NewLogger(app, level string) *logrus.Entry {
 // real URI, etc. This is the first level of checking. It does not confirm
 // if a JIRA cli is running at `jira-uri` for example; that is checked
 // in getJIRAClient when we actually make a call to the API.
-func (c *Config) validateConfig() error {
+func (c *Config) validateConfig() ConfigErrors {
 	// Log level and config file location are validated already
+	var errs ConfigErrors
 
 	c.log.Debug("Checking config variables...")
-	token := c.cmdConfig.GetString("github-token")
-	if token == "" {
-		return errors.New("GitHub token required")
+	if baseURL := c.GetGitHubBaseURL(); baseURL != "" {
+		if u, err := url.ParseRequestURI(baseURL); err != nil || u.Host == "" {
+			errs = append(errs, errors.New("GitHub base URL must be a valid URL"))
+		} else if _, err := url.ParseRequestURI(c.GetGitHubUploadURL()); err != nil {
+			errs = append(errs, errors.New("GitHub upload URL must be a valid URL"))
+		}
+	}
+	for _, backend := range []string{"github", "jira", "notify"} {
+		errs = append(errs, c.validateTransportOptions(backend)...)
+	}
+	if c.UsesGitHubApp() {
+		c.log.Debug("Using GitHub App authentication")
+
+		privateKey := c.cmdConfig.GetString("github-app-private-key-path")
+		if privateKey == "" {
+			errs = append(errs, errors.New("GitHub App private key required"))
+		} else if _, err := os.Stat(privateKey); err != nil {
+			errs = append(errs, errors.New("GitHub App private key must point to existing PEM file"))
+		}
+	} else {
+		token := c.cmdConfig.GetString("github-token")
+		if token == "" {
+			errs = append(errs, errors.New("GitHub token required"))
+		}
+	}
+
+	errs = append(errs, c.validateJIRAAuth()...)
+
+	repo := c.cmdConfig.GetString("repo-name")
+	if repo == "" {
+		errs = append(errs, errors.New("GitHub repository required"))
+	} else if !strings.Contains(repo, "/") || len(strings.Split(repo, "/")) != 2 {
+		errs = append(errs, errors.New("GitHub repository must be of form user/repo"))
+	}
+
+	uri := c.cmdConfig.GetString("jira-uri")
+	if uri == "" {
+		errs = append(errs, errors.New("JIRA URI required"))
+	} else if _, err := url.ParseRequestURI(uri); err != nil {
+		errs = append(errs, errors.New("JIRA URI must be valid URI"))
+	}
+
+	project := c.cmdConfig.GetString("jira-project")
+	if project == "" {
+		errs = append(errs, errors.New("JIRA project required"))
+	}
+
+	if c.GetFailureThreshold() < 0 {
+		errs = append(errs, errors.New("failure threshold can't be negative"))
+	}
+
+	for _, key := range []string{"notify-webhook-url", "notify-slack-url"} {
+		if u := c.cmdConfig.GetString(key); u != "" {
+			if parsed, err := url.ParseRequestURI(u); err != nil || parsed.Host == "" {
+				errs = append(errs, fmt.Errorf("%s must be a valid URL", key))
+			}
+		}
+	}
+	if c.GetRetryBackoff() <= 0 || c.GetRetryMaxBackoff() < c.GetRetryBackoff() {
+		errs = append(errs, errors.New("retry backoff must be positive, and at most the retry max backoff"))
+	}
+	if c.GetSinceOverlap() < 0 {
+		errs = append(errs, errors.New("since overlap can't be negative"))
+	}
+	if c.GetRetryMaxAttempts() < 0 {
+		errs = append(errs, errors.New("retry max attempts can't be negative"))
+	}
+	if c.GetNotifyIssueFailures() < 1 {
+		errs = append(errs, errors.New("notify issue failures must be at least 1"))
+	}
+	if c.GetNotifyMaxPerHour() < 1 {
+		errs = append(errs, errors.New("notify max per hour must be at least 1"))
+	}
+
+	switch c.GetLogFormat() {
+	case LogFormatText, LogFormatJSON:
+	default:
+		errs = append(errs, fmt.Errorf("log format must be one of '%s' or '%s'", LogFormatText, LogFormatJSON))
+	}
+
+	switch c.GetJIRAAPIVersion() {
+	case JIRAAPIVersionAuto, JIRAAPIVersion2, JIRAAPIVersion3:
+	default:
+		errs = append(errs, fmt.Errorf("JIRA API version must be one of '%s', '%s' or '%s'", JIRAAPIVersionAuto, JIRAAPIVersion2, JIRAAPIVersion3))
+	}
+
+	switch c.GetMilestoneMapping() {
+	case "", MilestoneToFixVersion:
+	case MilestoneToSprint:
+		if c.GetJIRABoardID() == 0 {
+			errs = append(errs, errors.New("JIRA board ID required to map milestones to sprints"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("milestone mapping must be one of '%s' or '%s'", MilestoneToFixVersion, MilestoneToSprint))
+	}
+
+	switch c.GetHierarchyMapping() {
+	case "", HierarchyToEpicLink, HierarchyToParent:
+	case HierarchyToIssueLink:
+		if c.GetHierarchyLinkType() == "" {
+			errs = append(errs, errors.New("JIRA issue link type required to map hierarchy to issue links"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("hierarchy mapping must be one of '%s', '%s' or '%s'", HierarchyToEpicLink, HierarchyToParent, HierarchyToIssueLink))
+	}
+
+	switch c.GetReferenceRewriting() {
+	case "", ReferencesToKey, ReferencesToSmartLink:
+	default:
+		errs = append(errs, fmt.Errorf("reference rewriting must be one of '%s' or '%s'", ReferencesToKey, ReferencesToSmartLink))
+	}
+
+	for _, f := range c.GetReverseSyncFields() {
+		switch f {
+		case ReverseSyncStatus, ReverseSyncPriority, ReverseSyncComments:
+		case ReverseSyncFixVersion:
+			if c.GetMilestoneMapping() == MilestoneToSprint {
+				errs = append(errs, errors.New("fixVersions can't be synced back to GitHub while milestones are mapped to sprints"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("reverse sync fields must be among '%s', '%s', '%s' and '%s'", ReverseSyncStatus, ReverseSyncPriority, ReverseSyncFixVersion, ReverseSyncComments))
+		}
+	}
+
+	for field, policy := range c.cmdConfig.GetStringMapString("conflict-policy") {
+		known := false
+		for _, f := range ConflictFields {
+			known = known || f == field
+		}
+		if !known {
+			errs = append(errs, fmt.Errorf("conflict policies can only be set for %s", strings.Join(ConflictFields, " and ")))
+			continue
+		}
+		switch policy {
+		case ConflictGitHubWins, ConflictJIRAWins, ConflictSkipAndFlag, ConflictMerge:
+		default:
+			errs = append(errs, fmt.Errorf("conflict policy must be one of '%s', '%s', '%s' or '%s'", ConflictGitHubWins, ConflictJIRAWins, ConflictSkipAndFlag, ConflictMerge))
+		}
+	}
+
+	switch c.GetIssueState() {
+	case "all", "open", "closed":
+	default:
+		errs = append(errs, errors.New("issue state must be one of 'all', 'open' or 'closed'"))
+	}
+
+	switch c.GetUnmatchedPolicy() {
+	case UnmatchedLeave, UnmatchedClose, UnmatchedUnlink:
+	default:
+		errs = append(errs, fmt.Errorf("unmatched policy must be one of '%s', '%s' or '%s'", UnmatchedLeave, UnmatchedClose, UnmatchedUnlink))
+	}
+
+	sinceStr := c.cmdConfig.GetString("since")
+	if sinceStr == "" {
+		sinceStr = "1970-01-01T00:00:00+0000"
+		c.cmdConfig.Set("since", sinceStr)
+	}
+
+	since, err := time.Parse(DateFormat, sinceStr)
+	if err != nil {
+		errs = append(errs, errors.New("Since date must be in ISO-8601 format"))
+	}
+	c.since = since
+
+	if len(errs) > 0 {
+		return errs
 	}
 
-	c.basicAuth = (c.cmdConfig.GetString("jira-user") != "") && (c.cmdConfig.GetString("jira-pass") != "")
+	c.log.Debug("All config variables are valid!")
 
-	if c.basicAuth {
+	return nil
+}
+
+// validateTransportOptions checks the network settings of a backend.
+func (c *Config) validateTransportOptions(backend string) ConfigErrors {
+	var errs ConfigErrors
+	options := c.getTransportOptions(backend)
+
+	if options.Proxy != "" {
+		if u, err := url.Parse(options.Proxy); err != nil || u.Host == "" {
+			errs = append(errs, fmt.Errorf("%s-proxy must be a valid URL", backend))
+		}
+	}
+	if options.ClientKey != "" && options.ClientCert == "" {
+		errs = append(errs, fmt.Errorf("%s-client-cert required with %s-client-key", backend, backend))
+	}
+	files := []struct{ key, path string }{
+		{"ca-bundle", options.CABundle},
+		{"client-cert", options.ClientCert},
+		{"client-key", options.ClientKey},
+	}
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s-%s must point to an existing PEM file", backend, f.key))
+		}
+	}
+	if options.InsecureSkipVerify {
+		c.log.Warnf("Not verifying the TLS certificates of %s; only use %s-insecure-skip-verify for testing", backend, backend)
+	}
+
+	return errs
+}
+
+// validateJIRAAuth checks that the credentials of the JIRA authentication
+// method are provided.
+func (c *Config) validateJIRAAuth() ConfigErrors {
+	var errs ConfigErrors
+
+	switch c.GetJIRAAuthMethod() {
+	case JIRAAuthBasic:
 		c.log.Debug("Using HTTP Basic Authentication")
 
 		jUser := c.cmdConfig.GetString("jira-user")
 		if jUser == "" {
-			return errors.New("Jira username required")
+			errs = append(errs, errors.New("Jira username required"))
 		}
 
 		jPass := c.cmdConfig.GetString("jira-pass")
-		if jPass == "" {
+		if jPass == "" && c.noPrompt {
+			errs = append(errs, errors.New("JIRA password required"))
+		} else if jPass == "" && !terminal.IsTerminal(int(syscall.Stdin)) {
+			errs = append(errs, errors.New("JIRA password required; set jira-pass, as it can't be prompted for without a terminal"))
+		} else if jPass == "" {
 			fmt.Print("Enter your JIRA password: ")
 			bytePass, err := terminal.ReadPassword(int(syscall.Stdin))
+			fmt.Println()
 			if err != nil {
-				return errors.New("JIRA password required")
+				errs = append(errs, errors.New("JIRA password required"))
+			} else {
+				c.cmdConfig.Set("jira-pass", string(bytePass))
+				c.jiraPassPrompted = true
+				// A password entered at the prompt isn't saved.
+				if s, ok := c.secrets["jira-pass"]; ok {
+					s.value = string(bytePass)
+					c.secrets["jira-pass"] = s
+				}
 			}
-			fmt.Println()
-			c.cmdConfig.Set("jira-pass", string(bytePass))
 		}
-	} else {
+	case JIRAAuthAPIToken:
+		c.log.Debug("Using JIRA Cloud API token authentication")
+
+		if c.cmdConfig.GetString("jira-user") == "" {
+			errs = append(errs, errors.New("JIRA user (the email address of the account) required for API token authentication"))
+		}
+		if c.cmdConfig.GetString("jira-api-token") == "" {
+			errs = append(errs, errors.New("JIRA API token required"))
+		}
+	case JIRAAuthPAT:
+		c.log.Debug("Using JIRA personal access token authentication")
+
+		if c.cmdConfig.GetString("jira-pat") == "" {
+			errs = append(errs, errors.New("JIRA personal access token required"))
+		}
+	case JIRAAuthOAuth1:
 		c.log.Debug("Using OAuth 1.0a authentication")
 
 		token := c.cmdConfig.GetString("jira-token")
 		if token == "" {
-			return errors.New("JIRA access token required")
+			errs = append(errs, errors.New("JIRA access token required"))
 		}
 
 		secret := c.cmdConfig.GetString("jira-secret")
 		if secret == "" {
-			return errors.New("JIRA access token secret required")
+			errs = append(errs, errors.New("JIRA access token secret required"))
 		}
 
 		consumerKey := c.cmdConfig.GetString("jira-consumer-key")
 		if consumerKey == "" {
-			return errors.New("JIRA consumer key required for OAuth handshake")
+			errs = append(errs, errors.New("JIRA consumer key required for OAuth handshake"))
 		}
 
 		privateKey := c.cmdConfig.GetString("jira-private-key-path")
 		if privateKey == "" {
-			return errors.New("JIRA private key required for OAuth handshake")
+			errs = append(errs, errors.New("JIRA private key required for OAuth handshake"))
+		} else if _, err := os.Stat(privateKey); err != nil {
+			errs = append(errs, errors.New("JIRA private key must point to existing PEM file"))
 		}
+	case JIRAAuthOAuth2:
+		c.log.Debug("Using OAuth 2.0 authentication")
 
-		_, err := os.Open(privateKey)
-		if err != nil {
-			return errors.New("JIRA private key must point to existing PEM file")
+		if c.cmdConfig.GetString("jira-oauth2-client-id") == "" {
+			errs = append(errs, errors.New("JIRA OAuth 2.0 client ID required"))
 		}
+		if c.cmdConfig.GetString("jira-oauth2-client-secret") == "" {
+			errs = append(errs, errors.New("JIRA OAuth 2.0 client secret required"))
+		}
+		if c.cmdConfig.GetString("jira-oauth2-refresh-token") == "" {
+			if c.noPrompt || !terminal.IsTerminal(int(syscall.Stdin)) {
+				errs = append(errs, errors.New("JIRA OAuth 2.0 refresh token required; run issue-sync in a terminal once to authorize it"))
+			} else if c.cmdConfig.GetString("jira-oauth2-redirect-url") == "" {
+				errs = append(errs, errors.New("JIRA OAuth 2.0 redirect URL required for the authorization"))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("JIRA auth method must be one of '%s', '%s', '%s', '%s' or '%s'",
+			JIRAAuthBasic, JIRAAuthAPIToken, JIRAAuthPAT, JIRAAuthOAuth1, JIRAAuthOAuth2))
 	}
 
-	repo := c.cmdConfig.GetString("repo-name")
-	if repo == "" {
-		return errors.New("GitHub repository required")
-	}
-	if !strings.Contains(repo, "/") || len(strings.Split(repo, "/")) != 2 {
-		return errors.New("GitHub repository must be of form user/repo")
-	}
-
-	uri := c.cmdConfig.GetString("jira-uri")
-	if uri == "" {
-		return errors.New("JIRA URI required")
-	}
-	if _, err := url.ParseRequestURI(uri); err != nil {
-		return errors.New("JIRA URI must be valid URI")
-	}
-
-	project := c.cmdConfig.GetString("jira-project")
-	if project == "" {
-		return errors.New("JIRA project required")
-	}
-
-	sinceStr := c.cmdConfig.GetString("since")
-	if sinceStr == "" {
-		c.cmdConfig.Set("since", "1970-01-01T00:00:00+0000")
-	}
-
-	since, err := time.Parse(DateFormat, sinceStr)
-	if err != nil {
-		return errors.New("Since date must be in ISO-8601 format")
-	}
-	c.since = since
-
-	c.log.Debug("All config variables are valid!")
-
-	return nil
+	return errs
 }
 
-// jiraField represents field metadata in JIRA. For an example of its
+// JIRAField represents field metadata in JIRA. For an example of its
 // structure, make a request to `${jira-uri}/rest/api/2/field`.
-type jiraField struct {
+type JIRAField struct {
 	ID          string   `json:"id"`
 	Key         string   `json:"key"`
 	Name        string   `json:"name"`
@@ -475,3 +1483,39 @@ type jiraField struct {
 		CustomID int    `json:"customId,omitempty"`
 	} `json:"schema,omitempty"`
 }
+
+// GetJIRAFields requests the metadata of every issue field in JIRA.
+func GetJIRAFields(client jira.Client) ([]JIRAField, error) {
+	req, err := client.NewRequest("GET", "/rest/api/2/field", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var jFields []JIRAField
+	_, err = client.Do(req, &jFields)
+	if err != nil {
+		return nil, err
+	}
+
+	return jFields, nil
+}
+
+// mergeSecretsFile adds the credentials of a `secrets-file`, which can be in any
+// of the formats of configuration files, to a Viper configuration.
+func mergeSecretsFile(v *viper.Viper, path string) error {
+	secrets, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(secrets.AllSettings())
+	if err != nil {
+		return err
+	}
+
+	// MergeConfig reads the format of the configuration file, which may not be
+	// that of the secrets file.
+	configType := configFileType(v.ConfigFileUsed())
+	defer v.SetConfigType(configType)
+	v.SetConfigType("json")
+	return v.MergeConfig(bytes.NewReader(b))
+}
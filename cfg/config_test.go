@@ -0,0 +1,54 @@
+package cfg
+
+import (
+	"testing"
+)
+
+func TestGetJIRAAuthMethod(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		expected string
+	}{
+		{name: "set", settings: map[string]interface{}{"jira-auth-method": JIRAAuthPAT, "jira-user": "u", "jira-pass": "p"}, expected: JIRAAuthPAT},
+		{name: "user and password", settings: map[string]interface{}{"jira-user": "u", "jira-pass": "p"}, expected: JIRAAuthBasic},
+		{name: "user only", settings: map[string]interface{}{"jira-user": "u"}, expected: JIRAAuthOAuth1},
+		{name: "nothing", settings: map[string]interface{}{}, expected: JIRAAuthOAuth1},
+	}
+
+	for _, test := range tests {
+		test.settings["log-level"] = "error"
+		config := NewConfigFromSettings(test.settings)
+		if method := config.GetJIRAAuthMethod(); method != test.expected {
+			t.Errorf("%s: Expected auth method %s; got %s", test.name, test.expected, method)
+		}
+	}
+}
+
+func TestValidateJIRAAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		errors   int
+	}{
+		{name: "basic", settings: map[string]interface{}{"jira-user": "u", "jira-pass": "p"}},
+		{name: "basic without password", settings: map[string]interface{}{"jira-auth-method": JIRAAuthBasic, "jira-user": "u"}, errors: 1},
+		{name: "api token", settings: map[string]interface{}{"jira-auth-method": JIRAAuthAPIToken, "jira-user": "u@example.com", "jira-api-token": "t"}},
+		{name: "api token without user", settings: map[string]interface{}{"jira-auth-method": JIRAAuthAPIToken, "jira-api-token": "t"}, errors: 1},
+		{name: "pat", settings: map[string]interface{}{"jira-auth-method": JIRAAuthPAT, "jira-pat": "t"}},
+		{name: "pat missing", settings: map[string]interface{}{"jira-auth-method": JIRAAuthPAT}, errors: 1},
+		{name: "oauth1 missing", settings: map[string]interface{}{"jira-auth-method": JIRAAuthOAuth1}, errors: 4},
+		{name: "oauth2", settings: map[string]interface{}{"jira-auth-method": JIRAAuthOAuth2, "jira-oauth2-client-id": "id", "jira-oauth2-client-secret": "s", "jira-oauth2-refresh-token": "r"}},
+		{name: "oauth2 without refresh token", settings: map[string]interface{}{"jira-auth-method": JIRAAuthOAuth2, "jira-oauth2-client-id": "id", "jira-oauth2-client-secret": "s"}, errors: 1},
+		{name: "unknown", settings: map[string]interface{}{"jira-auth-method": "kerberos"}, errors: 1},
+	}
+
+	for _, test := range tests {
+		test.settings["log-level"] = "error"
+		config := NewConfigFromSettings(test.settings)
+		config.noPrompt = true
+		if errs := config.validateJIRAAuth(); len(errs) != test.errors {
+			t.Errorf("%s: Expected %d errors; got %v", test.name, test.errors, errs)
+		}
+	}
+}
@@ -20,6 +20,12 @@ type FieldMapper interface {
 	GetFieldIDs(client jira.Client) (map[FieldKey]string, error)
 }
 
+// Issue types the field mappers create JIRA issues with.
+const (
+	defaultFieldMapperIssueType = "Improvement"
+	jsonFieldMapperIssueType    = "Task"
+)
+
 type DefaultFieldMapper struct {
 	Config *Config
 }
@@ -34,6 +40,55 @@ type TestFieldMapper struct {
 	HandleGetFieldIDs func(client jira.Client) (map[FieldKey]string, error)
 }
 
+// CustomField describes a JIRA custom field which a field mapper stores GitHub
+// data in.
+type CustomField struct {
+	// Name is the name of the field, which must match exactly.
+	Name string
+	// Type is the type of the field's values in its schema, e.g. "number".
+	Type string
+	// Custom is the key of the field's custom field type.
+	Custom string
+	// Searcher is the key of the search template used to search the field.
+	Searcher string
+	// Required is whether issue-sync refuses to run without the field.
+	Required bool
+}
+
+const (
+	customFieldTypePrefix = "com.atlassian.jira.plugin.system.customfieldtypes:"
+)
+
+var (
+	numberField = CustomField{
+		Type:     "number",
+		Custom:   customFieldTypePrefix + "float",
+		Searcher: customFieldTypePrefix + "exactnumber",
+	}
+	textField = CustomField{
+		Type:     "string",
+		Custom:   customFieldTypePrefix + "textfield",
+		Searcher: customFieldTypePrefix + "textsearcher",
+	}
+	paragraphField = CustomField{
+		Type:     "string",
+		Custom:   customFieldTypePrefix + "textarea",
+		Searcher: customFieldTypePrefix + "textsearcher",
+	}
+	dateTimeField = CustomField{
+		Type:     "datetime",
+		Custom:   customFieldTypePrefix + "datetime",
+		Searcher: customFieldTypePrefix + "datetimerange",
+	}
+)
+
+// newCustomField returns a custom field of the given kind with a name.
+func newCustomField(kind CustomField, name string, required bool) CustomField {
+	kind.Name = name
+	kind.Required = required
+	return kind
+}
+
 // Test Field mapper
 
 func (m TestFieldMapper) MapFields(issue *models.ExtendedGithubIssue) (jira.IssueFields, error) {
@@ -55,6 +110,26 @@ func (m DefaultFieldMapper) GetFieldValue(jIssue *jira.Issue, fieldKey FieldKey)
 		fallthrough
 	case GitHubNumber:
 		return jIssue.Fields.Unknowns.Int(m.Config.GetCompleteFieldKey(fieldKey))
+	case GitHubCommits:
+		// The GitHub Commits field is optional; without it, commits aren't tracked.
+		if m.Config.GetFieldID(GitHubCommits) == "" {
+			return nil, nil
+		}
+		commits := make([]interface{}, 0)
+		value, exists := jIssue.Fields.Unknowns.Value(m.Config.GetCompleteFieldKey(fieldKey))
+		if !exists || value == nil {
+			return commits, nil
+		}
+		str, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected GitHub Commits to be a string; got %T", value)
+		}
+		for _, c := range strings.Split(str, ",") {
+			if c != "" {
+				commits = append(commits, c)
+			}
+		}
+		return commits, nil
 	default:
 		result, exists := jIssue.Fields.Unknowns.Value(m.Config.GetCompleteFieldKey(fieldKey))
 		if !exists {
@@ -67,7 +142,7 @@ func (m DefaultFieldMapper) GetFieldValue(jIssue *jira.Issue, fieldKey FieldKey)
 func (m DefaultFieldMapper) MapFields(issue *models.ExtendedGithubIssue) (jira.IssueFields, error) {
 	fields := jira.IssueFields{
 		Type: jira.IssueType{
-			Name: "Improvement", // TODO: Decide on issue type
+			Name: defaultFieldMapperIssueType,
 		},
 		Project:     m.Config.GetProject(),
 		Summary:     issue.GetTitle(),
@@ -86,6 +161,10 @@ func (m DefaultFieldMapper) MapFields(issue *models.ExtendedGithubIssue) (jira.I
 	}
 	fields.Unknowns[m.Config.GetCompleteFieldKey(GitHubLabels)] = strings.Join(githubLabels, ",")
 
+	if m.Config.GetFieldID(GitHubCommits) != "" {
+		fields.Unknowns[m.Config.GetCompleteFieldKey(GitHubCommits)] = strings.Join(issue.CommitIds, ",")
+	}
+
 	fields.Unknowns[m.Config.GetCompleteFieldKey(LastISUpdate)] = time.Now().Format(DateFormat)
 
 	return fields, nil
@@ -95,20 +174,14 @@ func (m DefaultFieldMapper) GetFieldIDs(client jira.Client) (map[FieldKey]string
 	log := m.Config.log
 	log.Debug("Collecting field IDs.")
 
-	req, err := client.NewRequest("GET", "/rest/api/2/field", nil)
-	if err != nil {
-		return map[FieldKey]string{}, err
-	}
-	jFields := new([]jiraField)
-
-	_, err = client.Do(req, jFields)
+	jFields, err := GetJIRAFields(client)
 	if err != nil {
 		return map[FieldKey]string{}, err
 	}
 
 	fieldIDs := map[FieldKey]string{}
 
-	for _, field := range *jFields {
+	for _, field := range jFields {
 		switch field.Name {
 		case "GitHub ID":
 			fieldIDs[GitHubID] = fmt.Sprint(field.Schema.CustomID)
@@ -122,6 +195,10 @@ func (m DefaultFieldMapper) GetFieldIDs(client jira.Client) (map[FieldKey]string
 			fieldIDs[GitHubReporter] = fmt.Sprint(field.Schema.CustomID)
 		case "Last Issue-Sync Update":
 			fieldIDs[LastISUpdate] = fmt.Sprint(field.Schema.CustomID)
+		case "GitHub Commits":
+			fieldIDs[GitHubCommits] = fmt.Sprint(field.Schema.CustomID)
+		case "Epic Link":
+			fieldIDs[EpicLink] = fmt.Sprint(field.Schema.CustomID)
 		}
 	}
 
@@ -201,7 +278,7 @@ func (m JsonFieldMapper) GetFieldValue(jIssue *jira.Issue, fieldKey FieldKey) (i
 func (m JsonFieldMapper) MapFields(issue *models.ExtendedGithubIssue) (jira.IssueFields, error) {
 	fields := jira.IssueFields{
 		Type: jira.IssueType{
-			Name: "Task", // TODO: Determine issue type
+			Name: jsonFieldMapperIssueType,
 		},
 		Project:     m.Config.GetProject(),
 		Summary:     issue.GetTitle(),
@@ -236,23 +313,19 @@ func (m JsonFieldMapper) MapFields(issue *models.ExtendedGithubIssue) (jira.Issu
 func (m JsonFieldMapper) GetFieldIDs(client jira.Client) (map[FieldKey]string, error) {
 	log := m.Config.log
 	log.Debug("Collecting field IDs.")
-	req, err := client.NewRequest("GET", "/rest/api/2/field", nil)
-	if err != nil {
-		return map[FieldKey]string{}, err
-	}
-	jFields := new([]jiraField)
-
-	_, err = client.Do(req, jFields)
+	jFields, err := GetJIRAFields(client)
 	if err != nil {
 		return map[FieldKey]string{}, err
 	}
 
 	fieldIDs := map[FieldKey]string{}
 
-	for _, field := range *jFields {
+	for _, field := range jFields {
 		switch field.Name {
 		case "GitHub Issue Data":
 			fieldIDs[GitHubIssueData] = fmt.Sprint(field.Schema.CustomID)
+		case "Epic Link":
+			fieldIDs[EpicLink] = fmt.Sprint(field.Schema.CustomID)
 		}
 	}
 
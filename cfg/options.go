@@ -0,0 +1,398 @@
+package cfg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// OptionType is the type of the value of a configuration option.
+type OptionType string
+
+// Types of configuration options.
+const (
+	StringOption     OptionType = "string"
+	IntOption        OptionType = "int"
+	BoolOption       OptionType = "bool"
+	DurationOption   OptionType = "duration"
+	StringListOption OptionType = "[]string"
+	StringMapOption  OptionType = "map[string]string"
+)
+
+// Field mappers, used as values of the `github-to-jira-field-mapper` option.
+const (
+	FieldMapperDefault = "default-field-mapper"
+	FieldMapperJSON    = "json-field-mapper"
+)
+
+// Option is an option of the configuration file, which can also be set on the
+// command line, if it has a flag, or in an ISSUE_SYNC_ environment variable.
+type Option struct {
+	Name        string
+	Type        OptionType
+	Description string
+	// Default is the value used if the option isn't set, if it has one.
+	Default interface{}
+	// Enum are the values the option can be set to, if they're restricted.
+	Enum []string
+	// Required is whether the option must be set in every configuration.
+	Required bool
+	// Secret is whether the option holds a credential.
+	Secret bool
+}
+
+// Options are all the options of the configuration file. They're the single
+// definition from which the configuration file is written, unknown keys are
+// detected, and the JSON Schema of the configuration file is generated.
+var Options = []Option{
+	{Name: "log-level", Type: StringOption, Default: "info", Enum: []string{"panic", "fatal", "error", "warn", "info", "debug"},
+		Description: "Minimum level of the messages which are logged."},
+	{Name: "log-format", Type: StringOption, Default: LogFormatText, Enum: []string{LogFormatText, LogFormatJSON},
+		Description: "Format of the logs: text for people, or one JSON object per line for log pipelines."},
+	{Name: "github-token", Type: StringOption, Secret: true,
+		Description: "Personal access token used to access GitHub."},
+	{Name: "jira-user", Type: StringOption,
+		Description: "JIRA username, or the email address of the account for API token authentication."},
+	{Name: "jira-pass", Type: StringOption, Secret: true,
+		Description: "JIRA password."},
+	{Name: "jira-token", Type: StringOption, Secret: true,
+		Description: "JIRA OAuth 1.0a access token."},
+	{Name: "jira-secret", Type: StringOption, Secret: true,
+		Description: "JIRA OAuth 1.0a access token secret."},
+	{Name: "jira-consumer-key", Type: StringOption,
+		Description: "Consumer key of the JIRA OAuth 1.0a application link."},
+	{Name: "jira-private-key-path", Type: StringOption,
+		Description: "Path of the PEM private key of the JIRA OAuth 1.0a application link."},
+	{Name: "repo-name", Type: StringOption, Required: true,
+		Description: "GitHub repository to sync, in the form owner/repo."},
+	{Name: "jira-uri", Type: StringOption, Required: true,
+		Description: "Base URL of the JIRA server."},
+	{Name: "jira-project", Type: StringOption, Required: true,
+		Description: "Key of the JIRA project to sync to."},
+	{Name: "since", Type: StringOption, Default: "1970-01-01T00:00:00+0000",
+		Description: "Only sync GitHub issues updated since this ISO-8601 date; updated after each run."},
+	{Name: "since-overlap", Type: DurationOption, Default: "1m0s",
+		Description: "How far the since date is moved back from the last GitHub update synced, to list issues updated concurrently again."},
+	{Name: "timeout", Type: DurationOption, Default: "1m0s",
+		Description: "Maximum time spent retrying each API call."},
+	{Name: "period", Type: DurationOption, Default: "1h0m0s",
+		Description: "How often to sync; 0 runs once."},
+	{Name: "report-file", Type: StringOption,
+		Description: "Path of a file the JSON report of each run is written to."},
+	{Name: "failure-threshold", Type: IntOption, Default: 0,
+		Description: "How many issues can fail to sync before a one-shot run exits with an error."},
+	{Name: "notify-webhook-url", Type: StringOption,
+		Description: "URL failures and recoveries are posted to as JSON."},
+	{Name: "notify-slack-url", Type: StringOption,
+		Description: "URL of a Slack-compatible incoming webhook failures and recoveries are posted to."},
+	{Name: "notify-issue-failures", Type: IntOption, Default: 3,
+		Description: "How many runs in a row an issue must fail to sync in before it's notified."},
+	{Name: "notify-repeat-interval", Type: DurationOption, Default: "24h0m0s",
+		Description: "How long until a problem which was notified and persists is notified again."},
+	{Name: "notify-max-per-hour", Type: IntOption, Default: 4,
+		Description: "Maximum number of notifications sent per hour."},
+	{Name: "notify-state-file", Type: StringOption,
+		Description: "Path of a file the notified problems are kept in, so that one-shot runs don't repeat them."},
+	{Name: "retry-queue-file", Type: StringOption,
+		Description: "Path of the file GitHub issues which failed to sync are queued in; defaults to issue-sync-failures.json beside the configuration file."},
+	{Name: "retry-backoff", Type: DurationOption, Default: "5m0s",
+		Description: "How long until an issue which failed to sync is retried; doubled after each attempt."},
+	{Name: "retry-max-backoff", Type: DurationOption, Default: "24h0m0s",
+		Description: "Maximum time between two retries of an issue."},
+	{Name: "retry-max-attempts", Type: IntOption, Default: 10,
+		Description: "How many times an issue is retried before it's left in the queue as a dead letter."},
+	{Name: "full-sync-always", Type: BoolOption, Default: false,
+		Description: "Sync every GitHub issue on each run, instead of those updated since the last run."},
+	{Name: "github-to-jira-field-mapper", Type: StringOption, Default: FieldMapperDefault, Enum: []string{FieldMapperDefault, FieldMapperJSON},
+		Description: "How GitHub values are stored in JIRA fields: each in its own field, or all in one JSON field."},
+	{Name: "milestone-mapping", Type: StringOption, Enum: []string{MilestoneToFixVersion, MilestoneToSprint},
+		Description: "What GitHub milestones are synced to."},
+	{Name: "create-fix-versions", Type: BoolOption, Default: false,
+		Description: "Create missing JIRA versions for milestones."},
+	{Name: "jira-board-id", Type: IntOption,
+		Description: "ID of the JIRA board whose sprints milestones are synced to."},
+	{Name: "hierarchy-mapping", Type: StringOption, Enum: []string{HierarchyToEpicLink, HierarchyToParent, HierarchyToIssueLink},
+		Description: "What GitHub task-list hierarchies are synced to."},
+	{Name: "hierarchy-link-type", Type: StringOption,
+		Description: "JIRA issue link type of hierarchies synced to issue links."},
+	{Name: "rewrite-references", Type: StringOption, Enum: []string{ReferencesToKey, ReferencesToSmartLink},
+		Description: "How references to other GitHub issues are rewritten."},
+	{Name: "reference-link-type", Type: StringOption,
+		Description: "JIRA issue link type created for references to other GitHub issues."},
+	{Name: "remote-links", Type: BoolOption, Default: false,
+		Description: "Add a remote link to the GitHub issue to each JIRA issue."},
+	{Name: "development-section", Type: BoolOption, Default: false,
+		Description: "Add the pull requests and commits of each GitHub issue to its JIRA issue."},
+	{Name: "reverse-sync", Type: StringListOption, Enum: []string{ReverseSyncStatus, ReverseSyncPriority, ReverseSyncFixVersion, ReverseSyncComments},
+		Description: "JIRA fields synced back to GitHub."},
+	{Name: "priority-label-prefix", Type: StringOption, Default: defaultPriorityLabelPrefix,
+		Description: "Prefix of the GitHub labels JIRA priorities are synced to."},
+	{Name: "public-comment-prefix", Type: StringOption, Default: defaultPublicCommentPrefix,
+		Description: "Prefix which marks a JIRA comment as public, to copy it to GitHub."},
+	{Name: "conflict-policy", Type: StringMapOption, Enum: []string{ConflictGitHubWins, ConflictJIRAWins, ConflictSkipAndFlag, ConflictMerge},
+		Description: "How conflicting changes of each of the summary and description are resolved."},
+	{Name: "conflict-label", Type: StringOption, Default: defaultConflictLabel,
+		Description: "JIRA label added to issues with a conflict."},
+	{Name: "include-labels", Type: StringListOption,
+		Description: "Only sync GitHub issues with at least one of these labels."},
+	{Name: "exclude-labels", Type: StringListOption,
+		Description: "Don't sync GitHub issues with any of these labels."},
+	{Name: "issue-state", Type: StringOption, Default: "all", Enum: []string{"all", "open", "closed"},
+		Description: "Only sync GitHub issues in this state."},
+	{Name: "exclude-authors", Type: StringListOption,
+		Description: "Don't sync GitHub issues opened by these users."},
+	{Name: "exclude-bots", Type: BoolOption, Default: false,
+		Description: "Don't sync GitHub issues opened by bots."},
+	{Name: "include-milestones", Type: StringListOption,
+		Description: "Only sync GitHub issues in one of these milestones."},
+	{Name: "search-query", Type: StringOption,
+		Description: "Only sync GitHub issues matching this GitHub search query."},
+	{Name: "unmatched-policy", Type: StringOption, Default: UnmatchedLeave, Enum: []string{UnmatchedLeave, UnmatchedClose, UnmatchedUnlink},
+		Description: "What happens to the JIRA issues of GitHub issues which stop matching the filters."},
+	{Name: "unmatched-close-status", Type: StringOption, Default: defaultUnmatchedCloseStatus,
+		Description: "JIRA status issues are moved to by the close unmatched policy."},
+	{Name: "secrets-file", Type: StringOption,
+		Description: "Path of the file holding the credentials, which are never saved to the configuration file."},
+	{Name: "jira-auth-method", Type: StringOption, Enum: []string{JIRAAuthBasic, JIRAAuthAPIToken, JIRAAuthPAT, JIRAAuthOAuth1, JIRAAuthOAuth2},
+		Description: "How to authenticate to JIRA."},
+	{Name: "jira-api-token", Type: StringOption, Secret: true,
+		Description: "JIRA Cloud API token."},
+	{Name: "jira-pat", Type: StringOption, Secret: true,
+		Description: "JIRA Data Center personal access token."},
+	{Name: "jira-oauth2-client-id", Type: StringOption,
+		Description: "Client ID of the JIRA Cloud OAuth 2.0 app."},
+	{Name: "jira-oauth2-client-secret", Type: StringOption, Secret: true,
+		Description: "Client secret of the JIRA Cloud OAuth 2.0 app."},
+	{Name: "jira-oauth2-refresh-token", Type: StringOption, Secret: true,
+		Description: "Refresh token of the JIRA Cloud OAuth 2.0 app."},
+	{Name: "jira-oauth2-redirect-url", Type: StringOption,
+		Description: "Redirect URL of the JIRA Cloud OAuth 2.0 app."},
+	{Name: "jira-cloud-id", Type: StringOption,
+		Description: "ID of the JIRA Cloud site; found from jira-uri if not set."},
+	{Name: "jira-api-version", Type: StringOption, Default: JIRAAPIVersionAuto, Enum: []string{JIRAAPIVersionAuto, JIRAAPIVersion2, JIRAAPIVersion3},
+		Description: "Version of the JIRA REST API to use."},
+	{Name: "github-app-id", Type: IntOption,
+		Description: "ID of the GitHub App to authenticate as, instead of using github-token."},
+	{Name: "github-app-private-key-path", Type: StringOption,
+		Description: "Path of the PEM private key of the GitHub App."},
+	{Name: "github-app-installation-id", Type: IntOption,
+		Description: "ID of the installation of the GitHub App; found from the repository if not set."},
+	{Name: "github-base-url", Type: StringOption,
+		Description: "URL of the REST API of a GitHub Enterprise Server."},
+	{Name: "github-upload-url", Type: StringOption,
+		Description: "URL of the upload API of a GitHub Enterprise Server; defaults to github-base-url."},
+}
+
+// transportOptions are the network options each backend has, prefixed with its name.
+var transportOptions = []Option{
+	{Name: "proxy", Type: StringOption,
+		Description: "URL of the HTTP proxy to connect to %s through."},
+	{Name: "no-proxy", Type: StringListOption,
+		Description: "Hosts, domains and CIDR ranges of %s to connect to directly."},
+	{Name: "ca-bundle", Type: StringOption,
+		Description: "Path of a PEM bundle of certificates to trust for %s, besides the system's."},
+	{Name: "client-cert", Type: StringOption,
+		Description: "Path of the PEM certificate to authenticate to %s with."},
+	{Name: "client-key", Type: StringOption,
+		Description: "Path of the PEM key of the client certificate for %s; defaults to the certificate file."},
+	{Name: "insecure-skip-verify", Type: BoolOption, Default: false,
+		Description: "Don't verify the TLS certificate of %s; only for testing."},
+	{Name: "headers", Type: StringMapOption,
+		Description: "HTTP headers added to every request to %s."},
+}
+
+// deprecatedOptions are keys older versions wrote to the configuration file,
+// which are ignored.
+var deprecatedOptions = []string{"json-field-mapper"}
+
+func init() {
+	for _, backend := range []struct{ prefix, name string }{{"github", "GitHub"}, {"jira", "JIRA"}, {"notify", "the notification webhooks"}} {
+		for _, o := range transportOptions {
+			o.Name = backend.prefix + "-" + o.Name
+			o.Description = fmt.Sprintf(o.Description, backend.name)
+			Options = append(Options, o)
+		}
+	}
+
+	for _, o := range Options {
+		if o.Secret {
+			secretKeys = append(secretKeys, o.Name)
+		}
+	}
+}
+
+// getOption returns the option with a name.
+func getOption(name string) (Option, bool) {
+	for _, o := range Options {
+		if o.Name == name {
+			return o, true
+		}
+	}
+	return Option{}, false
+}
+
+// optionValue returns the value of an option in a Viper configuration, in the
+// form it's written to configuration files, and whether it's set: in the
+// configuration file, even to a zero value, or elsewhere to a non-empty value.
+// Zero values the user wrote must be saved, or their defaults would replace
+// them when the file is next read.
+func optionValue(v *viper.Viper, o Option) (interface{}, bool) {
+	value, nonEmpty := optionValueOf(v, o)
+	return value, nonEmpty || v.InConfig(o.Name)
+}
+
+// optionValueOf returns the value of an option in a Viper configuration, in the
+// form it's written to configuration files, and whether it's non-empty.
+func optionValueOf(v *viper.Viper, o Option) (interface{}, bool) {
+	switch o.Type {
+	case IntOption:
+		value := v.GetInt64(o.Name)
+		return value, value != 0
+	case BoolOption:
+		value := v.GetBool(o.Name)
+		return value, value
+	case DurationOption:
+		value := v.GetDuration(o.Name)
+		return value.String(), value != 0
+	case StringListOption:
+		value := v.GetStringSlice(o.Name)
+		return value, len(value) > 0
+	case StringMapOption:
+		value := v.GetStringMapString(o.Name)
+		return value, len(value) > 0
+	default:
+		value := v.GetString(o.Name)
+		return value, value != ""
+	}
+}
+
+// checkOptionType returns an error if a value set in a configuration file can't
+// be read as the type of its option.
+func checkOptionType(o Option, value interface{}) error {
+	var err error
+	switch o.Type {
+	case IntOption:
+		_, err = cast.ToInt64E(value)
+	case BoolOption:
+		_, err = cast.ToBoolE(value)
+	case DurationOption:
+		_, err = cast.ToDurationE(value)
+	case StringListOption:
+		_, err = cast.ToStringSliceE(value)
+	case StringMapOption:
+		_, err = cast.ToStringMapStringE(value)
+	default:
+		_, err = cast.ToStringE(value)
+	}
+	if err != nil {
+		return fmt.Errorf("%s must be a %s", o.Name, o.Type)
+	}
+	return nil
+}
+
+// unknownKeys returns the keys of a configuration file which aren't options.
+func unknownKeys(file *viper.Viper) []string {
+	known := map[string]bool{}
+	for _, o := range Options {
+		known[o.Name] = true
+	}
+	for _, name := range deprecatedOptions {
+		known[name] = true
+	}
+
+	seen := map[string]bool{}
+	var unknown []string
+	for _, key := range file.AllKeys() {
+		// Keys of maps, such as conflict-policy.description, are flattened.
+		key = strings.SplitN(key, ".", 2)[0]
+		if !known[key] && !seen[key] {
+			unknown = append(unknown, key)
+			seen[key] = true
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// configFileType returns the format of a configuration file according to its
+// extension: "yaml", "toml" or "json", which is used for any other extension.
+func configFileType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	default:
+		return "json"
+	}
+}
+
+// JSONSchema returns the JSON Schema of the configuration file, generated from
+// the options.
+func JSONSchema() ([]byte, error) {
+	properties := map[string]interface{}{}
+	var required []string
+
+	for _, o := range Options {
+		property := map[string]interface{}{"description": o.Description}
+
+		var enum interface{}
+		if len(o.Enum) > 0 {
+			enum = o.Enum
+		}
+		switch o.Type {
+		case IntOption:
+			property["type"] = "integer"
+		case BoolOption:
+			property["type"] = "boolean"
+		case DurationOption:
+			property["type"] = "string"
+			property["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$|^0$`
+		case StringListOption:
+			items := map[string]interface{}{"type": "string"}
+			if enum != nil {
+				items["enum"] = enum
+			}
+			property["type"] = "array"
+			property["items"] = items
+		case StringMapOption:
+			values := map[string]interface{}{"type": "string"}
+			if enum != nil {
+				values["enum"] = enum
+			}
+			property["type"] = "object"
+			property["additionalProperties"] = values
+		default:
+			property["type"] = "string"
+			if enum != nil {
+				property["enum"] = enum
+			}
+		}
+		if o.Default != nil {
+			property["default"] = o.Default
+		}
+		if o.Secret {
+			property["description"] = o.Description + " Can be a secret reference: env:VAR, file:/path or exec:command."
+		}
+
+		properties[o.Name] = property
+		if o.Required {
+			required = append(required, o.Name)
+		}
+	}
+
+	schema := map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"title":                "issue-sync configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
@@ -0,0 +1,153 @@
+package cfg
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestConfigFileFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	settings := map[string]interface{}{
+		"repo-name":       "o/r",
+		"jira-board-id":   int64(7),
+		"exclude-bots":    true,
+		"timeout":         "1m0s",
+		"include-labels":  []string{"bug", "help wanted"},
+		"conflict-policy": map[string]string{"description": ConflictMerge},
+	}
+
+	for _, name := range []string{"config.json", "config.yaml", "config.yml", "config.toml"} {
+		path := filepath.Join(dir, name)
+		if err := writeConfigFile(path, settings, 0600); err != nil {
+			t.Fatalf("Writing %s failed with error: %v", name, err)
+		}
+		file, err := readConfigFile(path)
+		if err != nil {
+			t.Fatalf("Reading %s failed with error: %v", name, err)
+		}
+
+		if repo := file.GetString("repo-name"); repo != "o/r" {
+			t.Errorf("%s: expected repo-name o/r; got %q", name, repo)
+		}
+		if id := file.GetInt("jira-board-id"); id != 7 {
+			t.Errorf("%s: expected jira-board-id 7; got %d", name, id)
+		}
+		if !file.GetBool("exclude-bots") {
+			t.Errorf("%s: expected exclude-bots to be true", name)
+		}
+		if timeout := file.GetDuration("timeout").String(); timeout != "1m0s" {
+			t.Errorf("%s: expected timeout 1m0s; got %s", name, timeout)
+		}
+		if labels := file.GetStringSlice("include-labels"); !reflect.DeepEqual(labels, []string{"bug", "help wanted"}) {
+			t.Errorf("%s: expected include-labels to be kept; got %v", name, labels)
+		}
+		if policy := file.GetStringMapString("conflict-policy")["description"]; policy != ConflictMerge {
+			t.Errorf("%s: expected the conflict policy of descriptions to be kept; got %q", name, policy)
+		}
+		if unknown := unknownKeys(file); len(unknown) > 0 {
+			t.Errorf("%s: expected no unknown keys; got %v", name, unknown)
+		}
+	}
+}
+
+func TestCheckConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+repo-name: o/r
+repo: o/r
+json-field-mapper: json-field-mapper
+jira-board-id: board
+conflict-policy:
+  description: merge
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := Config{
+		cmdFile:   path,
+		cmdConfig: *newViper("issue-sync", path),
+		log:       *NewLogger("test", "error"),
+	}
+	warnings, errs := config.checkConfigFile()
+
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings about repo and json-field-mapper; got %v", warnings)
+	}
+	if len(errs) != 1 {
+		t.Errorf("Expected an error about jira-board-id; got %v", errs)
+	}
+}
+
+func TestJSONSchemaUpToDate(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema failed with error: %v", err)
+	}
+	published, err := ioutil.ReadFile("../config-schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(bytes.TrimSpace(published), schema) {
+		t.Error("config-schema.json is out of date; regenerate it with `issue-sync config schema > config-schema.json`")
+	}
+}
+
+func TestSaveConfigKeepsZeroValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// These options default to non-zero values, so setting them to zero must
+	// survive a save.
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"repo-name": "o/r",
+		"since-overlap": "0s",
+		"notify-max-per-hour": 0,
+		"retry-max-attempts": 0
+	}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	load := func() Config {
+		return Config{
+			cmdConfig: *newViper("issue-sync", path),
+			log:       *NewLogger("test", "error"),
+		}
+	}
+	config := load()
+	if err := config.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig failed with error: %v", err)
+	}
+
+	saved := load()
+	if overlap := saved.GetSinceOverlap(); overlap != 0 {
+		t.Errorf("Expected since-overlap to stay 0s; got %v", overlap)
+	}
+	if max := saved.GetNotifyMaxPerHour(); max != 0 {
+		t.Errorf("Expected notify-max-per-hour to stay 0; got %d", max)
+	}
+	if attempts := saved.GetRetryMaxAttempts(); attempts != 0 {
+		t.Errorf("Expected retry-max-attempts to stay 0; got %d", attempts)
+	}
+}
@@ -0,0 +1,159 @@
+package cfg
+
+import (
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// fileHashes are the hashes of the contents of the configuration and secrets
+// files, as last seen by Watch or written by issue-sync itself.
+var fileHashes = struct {
+	sync.Mutex
+	hashes map[string][sha256.Size]byte
+}{hashes: map[string][sha256.Size]byte{}}
+
+// recordFileContents records the contents of a file issue-sync read or wrote.
+func recordFileContents(path string, b []byte) {
+	fileHashes.Lock()
+	defer fileHashes.Unlock()
+	fileHashes.hashes[filepath.Clean(path)] = sha256.Sum256(b)
+}
+
+// fileChanged returns whether the contents of a file changed since they were
+// last recorded, and records them.
+func fileChanged(path string) bool {
+	// A missing file has no contents.
+	b, _ := ioutil.ReadFile(path)
+	hash := sha256.Sum256(b)
+
+	fileHashes.Lock()
+	defer fileHashes.Unlock()
+	previous, ok := fileHashes.hashes[filepath.Clean(path)]
+	fileHashes.hashes[filepath.Clean(path)] = hash
+	return !ok || previous != hash
+}
+
+// Watch returns a channel which receives why the configuration should be
+// reloaded: whenever the contents of its configuration or secrets file change,
+// and whenever the process receives SIGHUP. Writes of the files by issue-sync
+// itself, such as SaveConfig's, are ignored. Changes are coalesced until they're
+// received, so that a configuration is reloaded once between sync cycles.
+func (c Config) Watch() (<-chan string, error) {
+	reloads := make(chan string, 1)
+	request := func(reason string) {
+		select {
+		case reloads <- reason:
+		default:
+		}
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			request("SIGHUP received")
+		}
+	}()
+
+	files := map[string]bool{}
+	for _, path := range []string{c.cmdFile, c.GetSecretsFile()} {
+		if path != "" {
+			files[filepath.Clean(path)] = true
+		}
+	}
+	if len(files) == 0 {
+		return reloads, nil
+	}
+	for path := range files {
+		fileChanged(path)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return reloads, err
+	}
+	// Directories are watched rather than the files themselves, as editors and
+	// Kubernetes replace files instead of writing them.
+	dirs := map[string]bool{}
+	for path := range files {
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return reloads, err
+		}
+		dirs[dir] = true
+	}
+
+	go func() {
+		for {
+			select {
+			case event := <-watcher.Events:
+				// Kubernetes swaps the ..data symlink of mounted ConfigMaps and Secrets.
+				if filepath.Base(event.Name) == "..data" {
+					for path := range files {
+						if fileChanged(path) {
+							request(path + " changed")
+						}
+					}
+				} else if files[filepath.Clean(event.Name)] && fileChanged(event.Name) {
+					request(event.Name + " changed")
+				}
+			case err := <-watcher.Errors:
+				c.log.WithError(err).Warn("Error watching the configuration file")
+			}
+		}
+	}()
+
+	return reloads, nil
+}
+
+// Reload loads the configuration again from the command line, the environment
+// and the configuration file, and validates it without prompting for
+// credentials. It returns whether the settings changed, and ConfigErrors if the
+// new configuration is invalid, in which case the current one should be kept.
+// Like NewConfig, the JIRA configuration isn't initialized.
+func (c Config) Reload(cmd *cobra.Command) (Config, bool, error) {
+	config, err := loadConfig(cmd)
+	if err != nil {
+		return Config{}, false, err
+	}
+	config.noPrompt = true
+
+	// A password entered at the prompt is kept, as it can't be prompted for again.
+	if c.jiraPassPrompted && config.cmdConfig.GetString("jira-pass") == "" {
+		config.cmdConfig.Set("jira-pass", c.cmdConfig.GetString("jira-pass"))
+		config.jiraPassPrompted = true
+		if s, ok := config.secrets["jira-pass"]; ok {
+			s.value = c.cmdConfig.GetString("jira-pass")
+			config.secrets["jira-pass"] = s
+		}
+	}
+
+	warnings, errs := config.problems()
+	for _, warning := range warnings {
+		config.log.Warn(warning)
+	}
+	if len(errs) > 0 {
+		return Config{}, false, errs
+	}
+
+	changed := false
+	for _, o := range Options {
+		value, _ := optionValue(&config.cmdConfig, o)
+		current, _ := optionValue(&c.cmdConfig, o)
+		changed = changed || !reflect.DeepEqual(value, current)
+	}
+	return config, changed, nil
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	write := func(period string) {
+		err := ioutil.WriteFile(path, []byte(`
+github-token: token
+jira-user: user
+jira-pass: pass
+repo-name: o/r
+jira-uri: https://jira.example.com
+jira-project: SYNC
+period: `+period+`
+`), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1h")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", path, "")
+	config, err := NewConfig(cmd)
+	if err != nil {
+		t.Fatalf("NewConfig failed with error: %v", err)
+	}
+
+	if _, changed, err := config.Reload(cmd); err != nil || changed {
+		t.Errorf("Expected an unchanged configuration; got changed %t, error %v", changed, err)
+	}
+
+	write("5m")
+	reloaded, changed, err := config.Reload(cmd)
+	if err != nil || !changed {
+		t.Fatalf("Expected a changed configuration; got changed %t, error %v", changed, err)
+	}
+	if period := reloaded.GetDaemonPeriod(); period != 5*time.Minute {
+		t.Errorf("Expected the new period 5m; got %v", period)
+	}
+
+	write("invalid")
+	if _, _, err := reloaded.Reload(cmd); err == nil {
+		t.Error("Expected an invalid configuration to be rejected")
+	}
+}
+
+func TestWatchIgnoresOwnWrites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yaml")
+	err = ioutil.WriteFile(path, []byte(`
+github-token: token
+jira-user: user
+jira-pass: pass
+repo-name: o/r
+jira-uri: https://jira.example.com
+jira-project: SYNC
+period: 1h
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("config", path, "")
+	config, err := NewConfig(cmd)
+	if err != nil {
+		t.Fatalf("NewConfig failed with error: %v", err)
+	}
+
+	reloads, err := config.Watch()
+	if err != nil {
+		t.Fatalf("Watch failed with error: %v", err)
+	}
+
+	config.SetSinceParam(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err := config.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig failed with error: %v", err)
+	}
+	select {
+	case reason := <-reloads:
+		t.Fatalf("Expected SaveConfig not to trigger a reload; got %q", reason)
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, append(b, []byte("# edited\n")...), 0600); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-reloads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected an edit of the configuration file to trigger a reload")
+	}
+}
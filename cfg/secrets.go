@@ -0,0 +1,228 @@
+package cfg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Prefixes of the secret references which credentials can be set to, instead
+// of their value.
+const (
+	// secretRefEnv reads a secret from an environment variable, e.g. "env:JIRA_PASS".
+	secretRefEnv = "env:"
+	// secretRefFile reads a secret from a file, e.g. "file:/run/secrets/jira-pass".
+	secretRefFile = "file:"
+	// secretRefExec reads a secret from the output of a shell command, e.g.
+	// "exec:pass show jira".
+	secretRefExec = "exec:"
+)
+
+// secretCommandTimeout is how long the command of an `exec:` secret reference
+// can run.
+const secretCommandTimeout = time.Minute
+
+// secret is a credential, and where it was set.
+type secret struct {
+	// ref is the secret reference the credential was set to, if any.
+	ref string
+	// file is the secrets or configuration file the credential is set in, if
+	// any, and stored the value it's set to there, which may be a reference.
+	file   string
+	stored string
+	// overridden is whether the credential was set on the command line or in
+	// the environment, overriding the file.
+	overridden bool
+	// value is the credential as it was loaded, after resolving its reference.
+	value string
+}
+
+// isSecretRef returns whether a value is a secret reference.
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, secretRefEnv) ||
+		strings.HasPrefix(value, secretRefFile) ||
+		strings.HasPrefix(value, secretRefExec)
+}
+
+// resolveSecretRef returns the value of the secret a reference points to.
+func resolveSecretRef(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, secretRefEnv):
+		name := strings.TrimPrefix(ref, secretRefEnv)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s isn't set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, secretRefFile):
+		b, err := ioutil.ReadFile(strings.TrimPrefix(ref, secretRefFile))
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(b)), nil
+	case strings.HasPrefix(ref, secretRefExec):
+		ctx, cancel := context.WithTimeout(context.Background(), secretCommandTimeout)
+		defer cancel()
+
+		var stderr bytes.Buffer
+		cmd := exec.CommandContext(ctx, "sh", "-c", strings.TrimPrefix(ref, secretRefExec))
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("command failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return ref, nil
+}
+
+// loadSecrets resolves the secret references among the credentials of a Viper
+// configuration, replacing them with their values, and returns where each
+// credential was set. Credentials are looked up in the secrets file first, then
+// in the configuration file; either path may be empty.
+func loadSecrets(v *viper.Viper, configPath string, secretsPath string) (map[string]secret, error) {
+	type file struct {
+		path   string
+		values *viper.Viper
+	}
+	var files []file
+	for _, path := range []string{secretsPath, configPath} {
+		if path == "" {
+			continue
+		}
+		// Files which can't be read hold no credentials.
+		if values, err := readConfigFile(path); err == nil {
+			files = append(files, file{path, values})
+		}
+	}
+
+	secrets := map[string]secret{}
+	for _, key := range secretKeys {
+		raw := v.GetString(key)
+		s := secret{value: raw}
+		for _, f := range files {
+			if f.values.IsSet(key) {
+				s.file, s.stored = f.path, f.values.GetString(key)
+				s.overridden = s.stored != raw
+				break
+			}
+		}
+
+		if isSecretRef(raw) {
+			value, err := resolveSecretRef(raw)
+			if err != nil {
+				return nil, fmt.Errorf("unable to resolve %s: %v", key, err)
+			}
+			s.ref, s.value = raw, value
+			v.Set(key, value)
+		}
+
+		secrets[key] = s
+	}
+	return secrets, nil
+}
+
+// readConfigFile reads the settings of a configuration file alone, in the format
+// of its extension.
+func readConfigFile(path string) (*viper.Viper, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType(configFileType(path))
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// saveSecrets adds the credentials SaveConfig writes to the settings of the
+// secrets file; they're never written to the configuration file, not even as
+// secret references. Credentials set on the command line, in the environment
+// or with a secret reference are never written: the files keep what they were
+// set to, which moves to the secrets file if it was in the configuration file,
+// and only credentials obtained during this run, such as a rotated OAuth 2.0
+// refresh token, are added. If the value of a `file:` reference changed, the
+// file it points to is updated instead. It returns whether there are any
+// credentials to write to the secrets file.
+func (c Config) saveSecrets(config map[string]interface{}, secrets map[string]interface{}) (bool, error) {
+	for _, key := range secretKeys {
+		delete(config, key)
+
+		s := c.secrets[key]
+		value := c.cmdConfig.GetString(key)
+		changed := value != s.value
+
+		if changed && s.ref != "" {
+			if err := c.saveSecretRef(key, s.ref, value); err != nil {
+				return false, err
+			}
+		}
+
+		switch {
+		case s.file != "" && (s.overridden || isSecretRef(s.stored)):
+			secrets[key] = s.stored
+		case s.file != "":
+			secrets[key] = value
+		case changed && s.ref == "":
+			secrets[key] = value
+		}
+	}
+
+	return len(secrets) > 0, nil
+}
+
+// SaveSecret sets a credential obtained during the run, such as a rotated OAuth
+// 2.0 refresh token, and saves it at once where SaveConfig would: to the file a
+// `file:` reference points to, or to the secrets file.
+func (c Config) SaveSecret(key string, value string) error {
+	c.cmdConfig.Set(key, value)
+	s := c.secrets[key]
+
+	if s.ref != "" {
+		if err := c.saveSecretRef(key, s.ref, value); err != nil {
+			return err
+		}
+	} else {
+		path := c.GetSecretsFile()
+		secrets := map[string]interface{}{}
+		if file, err := readConfigFile(path); err == nil {
+			secrets = file.AllSettings()
+		} else if !os.IsNotExist(err) {
+			return fmt.Errorf("unable to save %s to %s: %v", key, path, err)
+		}
+		secrets[key] = value
+		if err := writeConfigFile(path, secrets, 0600); err != nil {
+			return fmt.Errorf("unable to save %s to %s: %v", key, path, err)
+		}
+		s.file, s.stored, s.overridden = path, value, false
+	}
+
+	// It's saved, so SaveConfig keeps it where it is.
+	s.value = value
+	if c.secrets != nil {
+		c.secrets[key] = s
+	}
+	return nil
+}
+
+// saveSecretRef saves the new value of a credential set with a secret reference,
+// which is only possible for a `file:` reference; for the others, a warning is
+// logged.
+func (c Config) saveSecretRef(key string, ref string, value string) error {
+	if !strings.HasPrefix(ref, secretRefFile) {
+		c.log.Warnf("%s changed, but can't be saved to %s; update it for the next run", key, ref)
+		return nil
+	}
+
+	path := strings.TrimPrefix(ref, secretRefFile)
+	if err := ioutil.WriteFile(path, []byte(value+"\n"), 0600); err != nil {
+		return fmt.Errorf("unable to save %s to %s: %v", key, path, err)
+	}
+	return nil
+}
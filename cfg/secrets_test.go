@@ -0,0 +1,187 @@
+package cfg
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecretRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ISSUE_SYNC_TEST_SECRET", "from-env")
+	defer os.Unsetenv("ISSUE_SYNC_TEST_SECRET")
+
+	tests := map[string]string{
+		"env:ISSUE_SYNC_TEST_SECRET": "from-env",
+		"file:" + path:               "from-file",
+		"exec:echo from-exec":        "from-exec",
+		"plain":                      "plain",
+	}
+	for ref, expected := range tests {
+		value, err := resolveSecretRef(ref)
+		if err != nil {
+			t.Errorf("Resolving %q failed with error: %v", ref, err)
+		} else if value != expected {
+			t.Errorf("Expected %q to resolve to %q; got %q", ref, expected, value)
+		}
+	}
+
+	for _, ref := range []string{"env:ISSUE_SYNC_TEST_UNSET", "file:" + filepath.Join(dir, "missing"), "exec:exit 1"} {
+		if _, err := resolveSecretRef(ref); err == nil {
+			t.Errorf("Expected resolving %q to fail", ref)
+		}
+	}
+}
+
+func TestSaveConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"github-token": "env:ISSUE_SYNC_TEST_GITHUB_TOKEN",
+		"jira-pass": "in-file",
+		"repo-name": "o/r"
+	}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("ISSUE_SYNC_TEST_GITHUB_TOKEN", "resolved")
+	defer os.Unsetenv("ISSUE_SYNC_TEST_GITHUB_TOKEN")
+	os.Setenv("ISSUE_SYNC_JIRA_SECRET", "from-env")
+	defer os.Unsetenv("ISSUE_SYNC_JIRA_SECRET")
+
+	config := Config{
+		cmdConfig: *newViper("issue-sync", path),
+		log:       *NewLogger("test", "error"),
+	}
+	config.secrets, err = loadSecrets(&config.cmdConfig, path, "")
+	if err != nil {
+		t.Fatalf("loadSecrets failed with error: %v", err)
+	}
+
+	if token := config.GetConfigString("github-token"); token != "resolved" {
+		t.Errorf("Expected the GitHub token to be resolved; got %q", token)
+	}
+
+	if err := config.SaveConfig(); err != nil {
+		t.Fatalf("SaveConfig failed with error: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the configuration file to have mode 0600; got %v", info.Mode().Perm())
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]interface{}
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range secretKeys {
+		if value, ok := saved[key]; ok {
+			t.Errorf("Expected %s not to be saved to the configuration file; got %v", key, value)
+		}
+	}
+
+	secretsPath := filepath.Join(dir, defaultSecretsFile)
+	info, err = os.Stat(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the secrets file to have mode 0600; got %v", info.Mode().Perm())
+	}
+
+	b, err = ioutil.ReadFile(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secrets map[string]interface{}
+	if err := json.Unmarshal(b, &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets["github-token"] != "env:ISSUE_SYNC_TEST_GITHUB_TOKEN" {
+		t.Errorf("Expected the GitHub token reference to be kept; got %v", secrets["github-token"])
+	}
+	if secrets["jira-pass"] != "in-file" {
+		t.Errorf("Expected the JIRA password to move to the secrets file; got %v", secrets["jira-pass"])
+	}
+	if secret, ok := secrets["jira-secret"]; ok {
+		t.Errorf("Expected the JIRA secret set in the environment not to be saved; got %v", secret)
+	}
+}
+
+func TestSaveSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretsPath := filepath.Join(dir, "secrets.json")
+	if err := ioutil.WriteFile(secretsPath, []byte(`{"github-token": "gh"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	refPath := filepath.Join(dir, "client-secret")
+	if err := ioutil.WriteFile(refPath, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := NewConfigFromSettings(map[string]interface{}{
+		"log-level":                 "error",
+		"secrets-file":              secretsPath,
+		"jira-oauth2-client-secret": "file:" + refPath,
+	})
+
+	if err := config.SetJIRAOAuth2RefreshToken("rotated"); err != nil {
+		t.Fatalf("SetJIRAOAuth2RefreshToken failed with error: %v", err)
+	}
+	if token := config.GetConfigString("jira-oauth2-refresh-token"); token != "rotated" {
+		t.Errorf("Expected the refresh token to be set; got %q", token)
+	}
+
+	b, err := ioutil.ReadFile(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var secrets map[string]interface{}
+	if err := json.Unmarshal(b, &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets["jira-oauth2-refresh-token"] != "rotated" || secrets["github-token"] != "gh" {
+		t.Errorf("Expected the refresh token to be added to the secrets file; got %v", secrets)
+	}
+
+	if err := config.SaveSecret("jira-oauth2-client-secret", "new"); err != nil {
+		t.Fatalf("SaveSecret failed with error: %v", err)
+	}
+	b, err = ioutil.ReadFile(refPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new\n" {
+		t.Errorf("Expected the file of the secret reference to be updated; got %q", b)
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the commands which check the configuration file.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Check the configuration file",
+}
+
+// configValidateCmd reports every problem of the configuration.
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Report every problem of the configuration",
+	Long:  "Load the configuration file, flags and environment like a sync would, without prompting for credentials, then print every unknown key, value of the wrong type and invalid setting, rather than only the first one",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		warnings, errs := cfg.CheckConfig(cmd)
+		for _, warning := range warnings {
+			fmt.Fprintf(os.Stdout, "WARN  %s\n", warning)
+		}
+		for _, err := range errs {
+			fmt.Fprintf(os.Stdout, "ERROR %v\n", err)
+		}
+
+		if len(errs) > 0 {
+			return fmt.Errorf("%d configuration problems found", len(errs))
+		}
+		if len(warnings) == 0 {
+			fmt.Fprintln(os.Stdout, "The configuration is valid")
+		}
+		return nil
+	},
+}
+
+// configSchemaCmd prints the JSON Schema of the configuration file.
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := cfg.JSONSchema()
+		if err != nil {
+			return errors.New("unable to generate the JSON Schema")
+		}
+		fmt.Fprintln(os.Stdout, string(schema))
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configSchemaCmd)
+	RootCmd.AddCommand(configCmd)
+}
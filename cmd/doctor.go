@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/spf13/cobra"
+)
+
+// doctorCmd checks the GitHub and JIRA setup, and reports how to fix any problem.
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check that GitHub and JIRA are set up correctly",
+	Long:  "Check the GitHub token and repository, the JIRA credentials and project, the custom fields and their screens, and the configured statuses and link types, then print how to fix each problem",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		jiraClient, err := issuesyncjira.NewAPIClient(config)
+		if err != nil {
+			return err
+		}
+		ghClient, err := issuesyncgithub.NewAPIClient(config)
+		if err != nil {
+			return err
+		}
+
+		report := lib.RunDoctor(config, ghClient, jiraClient)
+		report.Print(os.Stdout)
+
+		if report.Failed() {
+			return errors.New("some checks failed")
+		}
+		return nil
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib"
+	"github.com/spf13/cobra"
+)
+
+// failuresCmd lists the GitHub issues in the retry queue.
+var failuresCmd = &cobra.Command{
+	Use:   "failures",
+	Short: "List the GitHub issues which failed to sync",
+	Long:  "List the GitHub issues in the retry queue, with their last error, how many times they failed, and when they are retried next; dead letters, which reached retry-max-attempts, are no longer retried",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.LoadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		queue, err := lib.LoadRetryQueue(config.GetRetryQueueFile())
+		if err != nil {
+			return err
+		}
+
+		failures := queue.Failures()
+		if len(failures) == 0 {
+			fmt.Println("No GitHub issues failed to sync")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ISSUE\tJIRA\tACTION\tATTEMPTS\tNEXT RETRY\tERROR")
+		for _, f := range failures {
+			next := f.NextRetry.Format(time.RFC3339)
+			if f.Attempts >= config.GetRetryMaxAttempts() {
+				next = "never (dead letter)"
+			}
+			fmt.Fprintf(w, "#%d\t%s\t%s\t%d\t%s\t%s\n", f.GitHubNumber, f.JIRAKey, f.Action, f.Attempts, next, f.Error)
+		}
+		return w.Flush()
+	},
+}
+
+// failuresPurgeCmd removes GitHub issues from the retry queue.
+var failuresPurgeCmd = &cobra.Command{
+	Use:   "purge [number...]",
+	Short: "Remove GitHub issues from the retry queue",
+	Long:  "Remove the given GitHub issues from the retry queue, or every issue if none is given, so that they are no longer retried",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := cfg.LoadConfig(cmd)
+		if err != nil {
+			return err
+		}
+		queue, err := lib.LoadRetryQueue(config.GetRetryQueueFile())
+		if err != nil {
+			return err
+		}
+
+		var numbers []int
+		for _, arg := range args {
+			number, err := strconv.Atoi(arg)
+			if err != nil {
+				return fmt.Errorf("%q isn't a GitHub issue number", arg)
+			}
+			numbers = append(numbers, number)
+		}
+		if len(args) == 0 {
+			for _, f := range queue.Failures() {
+				numbers = append(numbers, f.GitHubNumber)
+			}
+		}
+
+		for _, number := range numbers {
+			if queue.Remove(number) {
+				fmt.Printf("Removed GitHub #%d\n", number)
+			} else {
+				fmt.Printf("GitHub #%d isn't in the retry queue\n", number)
+			}
+		}
+		return queue.Save()
+	},
+}
+
+func init() {
+	failuresCmd.AddCommand(failuresPurgeCmd)
+	RootCmd.AddCommand(failuresCmd)
+}
@@ -0,0 +1,367 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// defaultConfigFile is the configuration file issue-sync reads if `--config`
+// isn't given.
+const defaultConfigFile = "config-issue-sync.json"
+
+// Authentication methods offered by the init wizard, besides those of
+// `jira-auth-method`.
+const (
+	authOAuth = "oauth"
+	authToken = "token"
+)
+
+// initCmd interactively creates a configuration file.
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Interactively create a configuration file",
+	Long:  "Prompt for the GitHub repository, the JIRA server, project and credentials, and the field mapper, test each of them, then write a validated configuration file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := cmd.Flags().GetString("config")
+		if err != nil || path == "" {
+			path = defaultConfigFile
+		}
+
+		p := prompter{
+			in:       bufio.NewReader(os.Stdin),
+			terminal: terminal.IsTerminal(int(syscall.Stdin)),
+		}
+		err = runWizard(p, path, map[string]interface{}{
+			"since": "1970-01-01T00:00:00+0000",
+		})
+		if err == io.EOF {
+			fmt.Println()
+			return errors.New("the input ended before the configuration was complete")
+		}
+		return err
+	},
+}
+
+// runWizard prompts for the rest of the configuration, and writes it to path
+// with the given settings.
+func runWizard(p prompter, path string, settings map[string]interface{}) error {
+	if _, err := os.Stat(path); err == nil {
+		overwrite, err := p.confirm(fmt.Sprintf("%s already exists. Overwrite it?", path), false)
+		if err != nil {
+			return err
+		}
+		if !overwrite {
+			return errors.New("not overwriting the existing configuration file")
+		}
+	}
+
+	if err := promptGitHub(p, settings); err != nil {
+		return err
+	}
+	if err := promptJIRA(p, settings); err != nil {
+		return err
+	}
+
+	mapper, err := p.choose("Field mapper (default stores each GitHub value in its own JIRA field, json stores them all in a \"GitHub Issue Data\" field)",
+		[]string{"default", "json"}, "default")
+	if err != nil {
+		return err
+	}
+	if mapper == "json" {
+		settings["github-to-jira-field-mapper"] = cfg.FieldMapperJSON
+	}
+
+	config := wizardConfig(settings)
+	if err := config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration: %v", err)
+	}
+
+	// The credentials are never stored in the configuration file.
+	secretsPath, err := p.ask("File to store the credentials in", cfg.DefaultSecretsFile(path))
+	if err != nil {
+		return err
+	}
+
+	if err := cfg.WriteConfig(path, settings, secretsPath); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s\n", path)
+	fmt.Printf("Wrote the credentials to %s\n", secretsPath)
+	fmt.Println("Run `issue-sync doctor` to check the JIRA project, or `issue-sync setup-jira` to create its custom fields")
+	return nil
+}
+
+// promptGitHub prompts for the GitHub token and repository until the repository
+// can be accessed with the token.
+func promptGitHub(p prompter, settings map[string]interface{}) error {
+	for {
+		token, err := p.askSecret("GitHub token")
+		if err != nil {
+			return err
+		}
+		repo, err := p.ask("GitHub repository (owner/repo)", "")
+		if err != nil {
+			return err
+		}
+		settings["github-token"] = token
+		settings["repo-name"] = repo
+
+		config := wizardConfig(settings)
+		owner, repoName := "", ""
+		if parts := strings.Split(repo, "/"); len(parts) == 2 {
+			owner, repoName = parts[0], parts[1]
+		} else {
+			fmt.Println("The repository must be of the form owner/repo")
+			continue
+		}
+
+		ghClient, err := issuesyncgithub.NewAPIClient(config)
+		if err != nil {
+			fmt.Printf("Unable to create a GitHub client: %v\n", err)
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+		found, _, err := ghClient.Repositories.Get(ctx, owner, repoName)
+		cancel()
+		if err != nil {
+			fmt.Printf("Unable to access %s/%s: %v\n", owner, repoName, err)
+			continue
+		}
+
+		fmt.Printf("Found %s\n\n", found.GetFullName())
+		return nil
+	}
+}
+
+// promptJIRA prompts for the JIRA server, credentials and project until the
+// project can be accessed.
+func promptJIRA(p prompter, settings map[string]interface{}) error {
+	for {
+		uri, err := p.ask("JIRA URI (e.g. https://jira.example.com/)", "")
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(uri, "/") {
+			uri += "/"
+		}
+		settings["jira-uri"] = uri
+
+		for _, key := range []string{"jira-auth-method", "jira-user", "jira-pass", "jira-api-token", "jira-pat", "jira-token", "jira-secret", "jira-consumer-key", "jira-private-key-path"} {
+			delete(settings, key)
+		}
+
+		method, err := p.choose("JIRA authentication", []string{cfg.JIRAAuthBasic, cfg.JIRAAuthAPIToken, cfg.JIRAAuthPAT, authOAuth, authToken}, cfg.JIRAAuthBasic)
+		if err != nil {
+			return err
+		}
+		switch method {
+		case cfg.JIRAAuthBasic:
+			settings["jira-auth-method"] = cfg.JIRAAuthBasic
+			err = p.askAll(settings, []settingQuestion{
+				{key: "jira-user", text: "JIRA username"},
+				{key: "jira-pass", text: "JIRA password", secret: true},
+			})
+		case cfg.JIRAAuthAPIToken:
+			settings["jira-auth-method"] = cfg.JIRAAuthAPIToken
+			err = p.askAll(settings, []settingQuestion{
+				{key: "jira-user", text: "JIRA email address"},
+				{key: "jira-api-token", text: "JIRA API token", secret: true},
+			})
+		case cfg.JIRAAuthPAT:
+			settings["jira-auth-method"] = cfg.JIRAAuthPAT
+			err = p.askAll(settings, []settingQuestion{
+				{key: "jira-pat", text: "JIRA personal access token", secret: true},
+			})
+		case authOAuth:
+			err = p.askAll(settings, []settingQuestion{
+				{key: "jira-consumer-key", text: "OAuth consumer key"},
+				{key: "jira-private-key-path", text: "Path of the OAuth private key (PEM)"},
+			})
+			if err != nil {
+				return err
+			}
+			token, err := issuesyncjira.JIRATokenFromWeb(wizardConfig(settings))
+			if err != nil {
+				fmt.Printf("OAuth handshake failed: %v\n", err)
+				continue
+			}
+			settings["jira-token"] = token.Token
+			settings["jira-secret"] = token.TokenSecret
+		case authToken:
+			err = p.askAll(settings, []settingQuestion{
+				{key: "jira-consumer-key", text: "OAuth consumer key"},
+				{key: "jira-private-key-path", text: "Path of the OAuth private key (PEM)"},
+				{key: "jira-token", text: "OAuth access token", secret: true},
+				{key: "jira-secret", text: "OAuth access token secret", secret: true},
+			})
+		}
+		if err != nil {
+			return err
+		}
+
+		jClient, err := issuesyncjira.NewAPIClient(wizardConfig(settings))
+		if err != nil {
+			fmt.Printf("Unable to create a JIRA client: %v\n", err)
+			continue
+		}
+		user, _, err := jClient.User.GetSelf()
+		if err != nil {
+			fmt.Printf("Unable to authenticate with JIRA: %v\n", err)
+			continue
+		}
+		fmt.Printf("Authenticated as %s\n\n", user.DisplayName)
+
+		for {
+			key, err := p.ask("JIRA project key", "")
+			if err != nil {
+				return err
+			}
+			project, _, err := jClient.Project.Get(key)
+			if err != nil {
+				fmt.Printf("Unable to access project %s: %v\n", key, err)
+				continue
+			}
+			settings["jira-project"] = project.Key
+			fmt.Printf("Found %s\n\n", project.Name)
+			return nil
+		}
+	}
+}
+
+// wizardConfig returns a configuration holding the settings entered so far, which
+// logs only warnings and errors so that it doesn't clutter the prompts.
+func wizardConfig(settings map[string]interface{}) cfg.Config {
+	withDefaults := map[string]interface{}{
+		"log-level": "warn",
+		"timeout":   time.Minute,
+	}
+	for key, value := range settings {
+		withDefaults[key] = value
+	}
+	return cfg.NewConfigFromSettings(withDefaults)
+}
+
+// prompter asks the user questions on the terminal. Its methods return io.EOF
+// if the input ends before they're answered.
+type prompter struct {
+	in *bufio.Reader
+	// terminal is whether the input is a terminal, so that secrets can be read
+	// without echoing them.
+	terminal bool
+}
+
+// settingQuestion is a question whose answer is saved as a setting.
+type settingQuestion struct {
+	key    string
+	text   string
+	secret bool
+}
+
+// ask prompts for a value, returning the default if the answer is empty. It
+// prompts again until the answer isn't empty.
+func (p prompter) ask(question string, def string) (string, error) {
+	for {
+		if def != "" {
+			fmt.Printf("%s [%s]: ", question, def)
+		} else {
+			fmt.Printf("%s: ", question)
+		}
+
+		answer, err := p.in.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if answer == "" {
+			answer = def
+		}
+		if answer != "" {
+			return answer, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+}
+
+// askSecret prompts for a value without echoing it, if the input is a terminal.
+func (p prompter) askSecret(question string) (string, error) {
+	if !p.terminal {
+		return p.ask(question, "")
+	}
+
+	for {
+		fmt.Printf("%s: ", question)
+		b, err := terminal.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		if answer := strings.TrimSpace(string(b)); answer != "" {
+			return answer, nil
+		}
+	}
+}
+
+// askAll prompts for each of the questions in turn, and saves the answers in
+// the settings.
+func (p prompter) askAll(settings map[string]interface{}, questions []settingQuestion) error {
+	for _, q := range questions {
+		var answer string
+		var err error
+		if q.secret {
+			answer, err = p.askSecret(q.text)
+		} else {
+			answer, err = p.ask(q.text, "")
+		}
+		if err != nil {
+			return err
+		}
+		settings[q.key] = answer
+	}
+	return nil
+}
+
+// choose prompts for one of several options.
+func (p prompter) choose(question string, options []string, def string) (string, error) {
+	for {
+		answer, err := p.ask(fmt.Sprintf("%s (%s)", question, strings.Join(options, "/")), def)
+		if err != nil {
+			return "", err
+		}
+		for _, o := range options {
+			if strings.EqualFold(answer, o) {
+				return o, nil
+			}
+		}
+		fmt.Printf("Please answer one of: %s\n", strings.Join(options, ", "))
+	}
+}
+
+// confirm prompts for a yes or no answer.
+func (p prompter) confirm(question string, def bool) (bool, error) {
+	defAnswer := "n"
+	if def {
+		defAnswer = "y"
+	}
+	answer, err := p.choose(question, []string{"y", "n"}, defAnswer)
+	if err != nil {
+		return false, err
+	}
+	return answer == "y", nil
+}
+
+func init() {
+	RootCmd.AddCommand(initCmd)
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// scriptedPrompter returns a prompter which reads the given answers, one per line.
+func scriptedPrompter(answers ...string) prompter {
+	return prompter{in: bufio.NewReader(strings.NewReader(strings.Join(answers, "\n") + "\n"))}
+}
+
+// newGitHubStub serves the repository o/r to the token good-token.
+func newGitHubStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer good-token" || r.URL.Path != "/repos/o/r" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Not Found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"full_name": "o/r"}`)
+	}))
+}
+
+// newJIRAStub serves the project SYNC to the user me@example.com, whose
+// password or API token is api-token.
+func newJIRAStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "me@example.com" || pass != "api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/rest/api/2/myself":
+			fmt.Fprint(w, `{"displayName": "Me"}`)
+		case "/rest/api/2/project/SYNC":
+			fmt.Fprint(w, `{"key": "SYNC", "name": "Sync"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestPromptGitHub(t *testing.T) {
+	server := newGitHubStub()
+	defer server.Close()
+
+	settings := map[string]interface{}{"github-base-url": server.URL + "/"}
+	// The repository is asked again until it's of the right form and can be
+	// accessed with the token.
+	p := scriptedPrompter("good-token", "not-a-repo", "bad-token", "o/r", "good-token", "o/r")
+	if err := promptGitHub(p, settings); err != nil {
+		t.Fatalf("promptGitHub failed with error: %v", err)
+	}
+
+	if settings["github-token"] != "good-token" || settings["repo-name"] != "o/r" {
+		t.Errorf("Expected the working token and repository to be set; got %v", settings)
+	}
+	if _, err := p.ask("Unexpected question", ""); err != io.EOF {
+		t.Errorf("Expected every answer to be used; got %v", err)
+	}
+}
+
+func TestPromptJIRA(t *testing.T) {
+	server := newJIRAStub()
+	defer server.Close()
+
+	settings := map[string]interface{}{}
+	// The credentials are asked again until they work, and the project key until
+	// the project exists.
+	p := scriptedPrompter(
+		server.URL, "basic", "me", "wrong",
+		server.URL, "api-token", "me@example.com", "api-token",
+		"NOPE", "SYNC",
+	)
+	if err := promptJIRA(p, settings); err != nil {
+		t.Fatalf("promptJIRA failed with error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"jira-uri":         server.URL + "/",
+		"jira-auth-method": "api-token",
+		"jira-user":        "me@example.com",
+		"jira-api-token":   "api-token",
+		"jira-project":     "SYNC",
+	}
+	if len(settings) != len(expected) {
+		t.Errorf("Expected the settings %v; got %v", expected, settings)
+	}
+	for key, value := range expected {
+		if settings[key] != value {
+			t.Errorf("Expected %s to be %v; got %v", key, value, settings[key])
+		}
+	}
+}
+
+func TestPromptEndOfInput(t *testing.T) {
+	settings := map[string]interface{}{}
+	if err := promptGitHub(scriptedPrompter("token"), settings); err != io.EOF {
+		t.Errorf("Expected promptGitHub to return io.EOF when the input ends; got %v", err)
+	}
+	if err := promptJIRA(scriptedPrompter("https://jira.example.com/", "basic", "me"), settings); err != io.EOF {
+		t.Errorf("Expected promptJIRA to return io.EOF when the input ends; got %v", err)
+	}
+}
+
+func TestRunWizardKeepsSecretsOutOfConfig(t *testing.T) {
+	github := newGitHubStub()
+	defer github.Close()
+	jira := newJIRAStub()
+	defer jira.Close()
+
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	// Every question with a default is answered with it.
+	p := scriptedPrompter(
+		"good-token", "o/r",
+		jira.URL, "api-token", "me@example.com", "api-token", "SYNC",
+		"", "",
+	)
+	settings := map[string]interface{}{
+		"since":           "1970-01-01T00:00:00+0000",
+		"github-base-url": github.URL + "/",
+	}
+	if err := runWizard(p, path, settings); err != nil {
+		t.Fatalf("runWizard failed with error: %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(b, &config); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"github-token", "jira-api-token"} {
+		if value, ok := config[key]; ok {
+			t.Errorf("Expected %s not to be in the configuration file; got %v", key, value)
+		}
+	}
+
+	secretsPath := filepath.Join(dir, "issue-sync-secrets.json")
+	info, err := os.Stat(secretsPath)
+	if err != nil {
+		t.Fatalf("Expected the credentials to be written to %s: %v", secretsPath, err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the secrets file to have mode 0600; got %v", info.Mode().Perm())
+	}
+	var secrets map[string]string
+	b, err = ioutil.ReadFile(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &secrets); err != nil {
+		t.Fatal(err)
+	}
+	if secrets["github-token"] != "good-token" || secrets["jira-api-token"] != "api-token" {
+		t.Errorf("Expected the credentials in the secrets file; got %v", secrets)
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
@@ -43,25 +44,98 @@ var RootCmd = &cobra.Command{
 			return err
 		}
 
+		var reloads <-chan string
+		if config.IsDaemon() {
+			reloads, err = config.Watch()
+			if err != nil {
+				log.WithError(err).Warn("Not reloading the configuration when its file changes")
+			}
+		}
+
+		notifier := lib.NewNotifier()
+
 		for {
-			if err := lib.CompareIssues(config, ghClient, jiraClient); err != nil {
+			report, err := lib.CompareIssues(config, ghClient, jiraClient)
+			if err != nil {
 				log.Error(err)
 			}
+			report.Log(log)
+			if err := notifier.Notify(config, report); err != nil {
+				log.WithError(err).Error("Error sending notifications")
+			}
+			if path := config.GetReportFile(); path != "" {
+				if err := report.WriteFile(path); err != nil {
+					log.WithError(err).Error("Error writing the run report")
+				}
+			}
 			if !config.IsDryRun() && !config.FullSyncAlways() {
+				if report.Since != nil {
+					config.SetSinceParam(*report.Since)
+				}
 				if err := config.SaveConfig(); err != nil {
 					log.Error(err)
 				}
 			}
 			if !config.IsDaemon() {
+				// Exit with an error, so that cron and CI notice partial failures.
+				if report.Error != "" {
+					return fmt.Errorf("the run stopped early: %s", report.Error)
+				}
+				if report.Failed(config.GetFailureThreshold()) {
+					return fmt.Errorf("%d issues failed to sync, more than the failure threshold of %d", len(report.Failures), config.GetFailureThreshold())
+				}
 				return nil
 			}
-			<-time.After(config.GetDaemonPeriod())
+
+			// Reloads are applied between sync cycles, and a new period
+			// applies from the end of the last one.
+			last := time.Now()
+			for waiting := true; waiting; {
+				select {
+				case <-time.After(time.Until(last.Add(config.GetDaemonPeriod()))):
+					waiting = false
+				case reason := <-reloads:
+					log.WithField("reason", reason).Info("Reloading the configuration")
+					newConfig, newJIRAClient, newGHClient, err := reload(cmd, config)
+					if err != nil {
+						log.WithError(err).Error("Keeping the current configuration, as the new one is invalid")
+						continue
+					}
+					if newJIRAClient == nil {
+						log.Info("The configuration is unchanged")
+						continue
+					}
+					config, jiraClient, ghClient = newConfig, newJIRAClient, newGHClient
+					log = config.GetLogger()
+					log.Info("Configuration reloaded")
+				}
+			}
 		}
 	},
 }
 
+// reload loads and validates the configuration again, then creates new clients
+// with it. The clients are nil if the configuration is unchanged.
+func reload(cmd *cobra.Command, current cfg.Config) (cfg.Config, issuesyncjira.Client, issuesyncgithub.Client, error) {
+	config, changed, err := current.Reload(cmd)
+	if err != nil || !changed {
+		return current, nil, nil, err
+	}
+
+	jiraClient, err := issuesyncjira.NewClient(&config)
+	if err != nil {
+		return current, nil, nil, err
+	}
+	ghClient, err := issuesyncgithub.NewClient(config)
+	if err != nil {
+		return current, nil, nil, err
+	}
+	return config, jiraClient, ghClient, nil
+}
+
 func init() {
 	RootCmd.PersistentFlags().String("log-level", logrus.InfoLevel.String(), "Set the global log level")
+	RootCmd.PersistentFlags().String("log-format", cfg.LogFormatText, "Set the log format: text or json")
 	RootCmd.PersistentFlags().String("config", "", "Config file (default is $HOME/.issue-sync.json)")
 	RootCmd.PersistentFlags().StringP("github-token", "t", "", "Set the API Token used to access the GitHub repo")
 	RootCmd.PersistentFlags().StringP("jira-user", "u", "", "Set the JIRA username to authenticate with")
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/spf13/cobra"
+)
+
+// setupJIRACmd creates the JIRA custom fields issue-sync needs.
+var setupJIRACmd = &cobra.Command{
+	Use:   "setup-jira",
+	Short: "Create the JIRA custom fields issue-sync needs",
+	Long:  "Create the JIRA custom fields needed by the configured field mapper and add them to the given screens, or to the create and edit screens of the project; requires JIRA administrator credentials, and can safely be run again",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		screenIDs, err := cmd.Flags().GetIntSlice("screen-id")
+		if err != nil {
+			return err
+		}
+
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		jiraClient, err := issuesyncjira.NewAPIClient(config)
+		if err != nil {
+			return err
+		}
+
+		actions, err := lib.SetupJIRA(config, jiraClient, screenIDs)
+		for _, a := range actions {
+			fmt.Println(a)
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Run `issue-sync doctor` to check that the fields are on the screens of your project")
+		return nil
+	},
+}
+
+func init() {
+	setupJIRACmd.Flags().IntSlice("screen-id", nil, "ID of a JIRA screen to add the fields to; repeat for several screens (default: the create and edit screens of the project, from its issue type screen scheme)")
+	RootCmd.AddCommand(setupJIRACmd)
+}
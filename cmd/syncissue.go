@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/spf13/cobra"
+)
+
+// syncIssueCmd syncs a single GitHub issue, identified either by its number or
+// by the key of its JIRA issue.
+var syncIssueCmd = &cobra.Command{
+	Use:   "sync-issue [number]",
+	Short: "Synchronize a single GitHub issue",
+	Long:  "Synchronize a single GitHub issue and its comments, regardless of the since date, then print its JIRA key and what changed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		jiraKey, err := cmd.Flags().GetString("jira-key")
+		if err != nil {
+			return err
+		}
+		if (len(args) == 1) == (jiraKey != "") || len(args) > 1 {
+			return errors.New("either a GitHub issue number or --jira-key is required")
+		}
+
+		config, err := cfg.NewConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		jiraClient, err := issuesyncjira.NewClient(&config)
+		if err != nil {
+			return err
+		}
+		ghClient, err := issuesyncgithub.NewClient(config)
+		if err != nil {
+			return err
+		}
+
+		var number int
+		if jiraKey != "" {
+			number, err = lib.FindGitHubNumber(config, jiraKey, jiraClient)
+		} else {
+			number, err = strconv.Atoi(args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := lib.SyncIssue(config, number, ghClient, jiraClient)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case result.Key == "":
+			fmt.Printf("GitHub #%d: no JIRA issue (dry run)\n", result.Number)
+		case result.Created:
+			fmt.Printf("GitHub #%d: created %s\n", result.Number, result.Key)
+		case len(result.Changes) == 0:
+			fmt.Printf("GitHub #%d: %s is up to date\n", result.Number, result.Key)
+		case config.IsDryRun():
+			fmt.Printf("GitHub #%d: would update %s\n", result.Number, result.Key)
+			for _, c := range result.Changes {
+				fmt.Printf("  %s\n", c)
+			}
+		default:
+			fmt.Printf("GitHub #%d: updated %s\n", result.Number, result.Key)
+			for _, c := range result.Changes {
+				fmt.Printf("  %s\n", c)
+			}
+		}
+		if config.IsDryRun() {
+			fmt.Println("Dry run: no changes were made; see the log for the actions that would be taken")
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	syncIssueCmd.Flags().String("jira-key", "", "Synchronize the GitHub issue of this JIRA issue (e.g. PROJ-13)")
+	RootCmd.AddCommand(syncIssueCmd)
+}
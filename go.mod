@@ -17,9 +17,9 @@ require (
 	github.com/kr/pretty v0.1.0 // indirect
 	github.com/magiconair/properties v1.7.3 // indirect
 	github.com/mitchellh/mapstructure v0.0.0-20170523030023-d0303fe80992 // indirect
-	github.com/pelletier/go-toml v0.0.0-20170628012637-69d355db5304 // indirect
+	github.com/pelletier/go-toml v0.0.0-20170628012637-69d355db5304
 	github.com/spf13/afero v0.0.0-20170217164146-9be650865eab // indirect
-	github.com/spf13/cast v1.1.0 // indirect
+	github.com/spf13/cast v1.1.0
 	github.com/spf13/cobra v0.0.0-20170716104802-d994347edadc
 	github.com/spf13/jwalterweatherman v0.0.0-20170523133247-0efa5202c046 // indirect
 	github.com/spf13/pflag v1.0.0 // indirect
@@ -29,4 +29,5 @@ require (
 	golang.org/x/oauth2 v0.0.0-20180821212333-d2e6202438be
 	golang.org/x/tools/gopls v0.1.5 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
+	gopkg.in/yaml.v2 v2.2.2
 )
@@ -23,9 +23,10 @@ var jCommentIDRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|")
 
 // CompareComments takes a GitHub issue, and retrieves all of its comments. It then
 // matches each one to a comment in `existing`. If it finds a match, it calls
-// UpdateComment; if it doesn't, it calls CreateComment.
-func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
-	log := config.GetLogger()
+// UpdateComment; if it doesn't, it calls CreateComment. References to other issues
+// are rewritten by the run's resolver.
+func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
+	log := actionLogger(config, "compare_comments")
 	user, repoName := config.GetRepo()
 
 	if ghIssue.GetComments() == 0 {
@@ -38,6 +39,22 @@ func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue,
 		return err
 	}
 
+	var rewrittenComments []*github.IssueComment
+	for _, ghComment := range ghComments {
+		// Comments issue-sync copied from JIRA must not be copied back.
+		if ghCommentMarkerRegex.MatchString(ghComment.GetBody()) {
+			continue
+		}
+		if ghComment.Body != nil {
+			rewritten := *ghComment
+			body := refs.rewrite(ghComment.GetBody())
+			rewritten.Body = &body
+			ghComment = &rewritten
+		}
+		rewrittenComments = append(rewrittenComments, ghComment)
+	}
+	ghComments = rewrittenComments
+
 	var jComments []jira.Comment
 	if jIssue.Fields.Comments == nil {
 		log.Debugf("JIRA issue %s has no comments.", jIssue.Key)
@@ -97,13 +114,13 @@ func CompareComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue,
 // UpdateComment compares the body of a GitHub comment with the body (minus header)
 // of the JIRA comment, and updates the JIRA comment if necessary.
 func UpdateComment(config cfg.Config, ghComment github.IssueComment, jComment jira.Comment, jIssue jira.Issue, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
-	log := config.GetLogger()
+	log := actionLogger(config, "update_comment")
 
 	// fields[0] is the whole body, 1 is the ID, 2 is the username, 3 is the real name (or "" if none)
 	// 4 is the date, and 5 is the real body
 	fields := jCommentRegex.FindStringSubmatch(jComment.Body)
 
-	if fields[5] == ghComment.GetBody() {
+	if sameText(fields[5], ghComment.GetBody()) {
 		return nil
 	}
 
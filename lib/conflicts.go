@@ -0,0 +1,269 @@
+package lib
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// syncRecordProperty is the key of the JIRA issue property in which issue-sync
+// records what it last wrote to each field, in order to detect JIRA edits.
+const syncRecordProperty = "issue-sync"
+
+// syncRecord is the value of the syncRecordProperty of a JIRA issue.
+type syncRecord struct {
+	Fields map[string]fieldRecord `json:"fields"`
+	// Status is the state of the issue on both sides as of the last sync, if the
+	// status is synced back to GitHub.
+	Status *statusRecord `json:"status,omitempty"`
+}
+
+// statusRecord is what issue-sync knows of the state of an issue as of the last
+// sync, to tell whether it changed in JIRA or on GitHub since.
+type statusRecord struct {
+	GitHubState string `json:"github_state"`
+	JIRADone    bool   `json:"jira_done"`
+}
+
+// fieldRecord is what issue-sync knows of a JIRA field as of the last sync.
+type fieldRecord struct {
+	// GitHub is the hash of the GitHub value at the last sync.
+	GitHub string `json:"github"`
+	// JIRA is the hash of the value issue-sync last wrote to JIRA.
+	JIRA string `json:"jira"`
+	// Base is the GitHub value at the last sync, kept for three-way merges.
+	Base string `json:"base,omitempty"`
+	// Flagged is whether the field has a conflict which was skipped and flagged.
+	Flagged bool `json:"flagged,omitempty"`
+}
+
+// conflictResolution is the outcome of resolveConflicts: the record to store
+// once the JIRA issue is updated, and the fields whose conflict must be flagged.
+type conflictResolution struct {
+	record    syncRecord
+	changed   bool
+	conflicts []string
+	// written are the fields issue-sync writes to JIRA, whose JIRA hash is that
+	// of the value JIRA stores once the issue is updated.
+	written map[string]bool
+}
+
+// hashValue returns the hash stored in a fieldRecord for a field value.
+func hashValue(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))
+}
+
+// newFieldRecord returns the record of a field whose JIRA value was just set to
+// its GitHub value.
+func newFieldRecord(config cfg.Config, field string, value string) fieldRecord {
+	r := fieldRecord{GitHub: hashValue(value), JIRA: hashValue(value)}
+	if config.GetConflictPolicy(field) == cfg.ConflictMerge {
+		r.Base = value
+	}
+	return r
+}
+
+// fieldValues returns the GitHub and JIRA values of a field subject to conflict
+// detection.
+func fieldValues(field string, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue) (string, string) {
+	switch field {
+	case "summary":
+		return ghIssue.GetTitle(), jIssue.Fields.Summary
+	default:
+		return ghIssue.GetBody(), jIssue.Fields.Description
+	}
+}
+
+// setFieldValue sets the value of a field subject to conflict detection on the
+// GitHub issue, so that it's the one written to JIRA.
+func setFieldValue(field string, ghIssue *models.ExtendedGithubIssue, value string) {
+	switch field {
+	case "summary":
+		ghIssue.Title = &value
+	default:
+		ghIssue.Body = &value
+	}
+}
+
+// loadSyncRecord reads the sync record of a JIRA issue, if issue-sync keeps
+// one: when conflicts are detected, or when the status is synced back to
+// GitHub. The returned bool is false if the issue has none yet.
+func loadSyncRecord(config cfg.Config, jIssue jira.Issue, jClient issuesyncjira.Client) (syncRecord, bool, error) {
+	var stored syncRecord
+	if !config.DetectsConflicts() && !config.ReverseSyncs(cfg.ReverseSyncStatus) {
+		return stored, false, nil
+	}
+
+	found, err := issuesyncjira.GetIssueProperty(jClient, config.GetTimeout(), jIssue.Key, syncRecordProperty, &stored)
+	return stored, found, err
+}
+
+// resolveConflicts compares the JIRA value of each field subject to conflict
+// detection with what issue-sync last wrote to it, as stored in the sync record
+// of the JIRA issue. If both the JIRA and the GitHub values changed since, the
+// field's `conflict-policy` decides which value is kept. `ghIssue` is updated
+// with the values to write to JIRA.
+func resolveConflicts(config cfg.Config, ghIssue *models.ExtendedGithubIssue, jIssue jira.Issue, stored syncRecord, found bool) conflictResolution {
+	log := config.GetLogger()
+
+	resolution := conflictResolution{
+		record:  syncRecord{Fields: map[string]fieldRecord{}, Status: stored.Status},
+		changed: !found && config.DetectsConflicts(),
+		written: map[string]bool{},
+	}
+
+	if !config.DetectsConflicts() {
+		return resolution
+	}
+
+	labeled := false
+	for _, l := range jIssue.Fields.Labels {
+		labeled = labeled || l == config.GetConflictLabel()
+	}
+
+	for _, field := range cfg.ConflictFields {
+		ghValue, jValue := fieldValues(field, *ghIssue, jIssue)
+		policy := config.GetConflictPolicy(field)
+
+		record, ok := stored.Fields[field]
+		jiraChanged := ok && hashValue(jValue) != record.JIRA
+		githubChanged := !ok || hashValue(ghValue) != record.GitHub
+
+		value := ghValue
+		next := newFieldRecord(config, field, ghValue)
+		written := true
+
+		switch {
+		case policy == cfg.ConflictGitHubWins || !jiraChanged:
+			// Nothing to protect; GitHub's value is written.
+		case !githubChanged:
+			// Only JIRA changed, so there is nothing to write.
+			value, next, written = jValue, record, false
+		case policy == cfg.ConflictJIRAWins:
+			value = jValue
+			next.JIRA = hashValue(jValue)
+		default:
+			if policy == cfg.ConflictMerge {
+				if merged, ok := merge3(record.Base, ghValue, jValue); ok {
+					log.Debugf("Merged JIRA and GitHub changes to the %s of %s", field, jIssue.Key)
+					value = merged
+					next.JIRA = hashValue(merged)
+					break
+				}
+			}
+
+			value = jValue
+			if record.Flagged && !labeled {
+				// The conflict label was removed, which resolves the conflict in
+				// favor of the JIRA value.
+				log.Debugf("Conflict on the %s of %s was resolved in JIRA", field, jIssue.Key)
+				next.JIRA = hashValue(jValue)
+				break
+			}
+
+			log.Warnf("Conflicting changes to the %s of %s in JIRA and GitHub #%d; not updating it", field, jIssue.Key, ghIssue.GetNumber())
+			next, written = record, false
+			next.Flagged = true
+			resolution.conflicts = append(resolution.conflicts, field)
+		}
+
+		setFieldValue(field, ghIssue, value)
+		resolution.record.Fields[field] = next
+		resolution.written[field] = written
+		resolution.changed = resolution.changed || next != record
+	}
+
+	return resolution
+}
+
+// newConflictResolution returns the resolution for a JIRA issue which was just
+// created from a GitHub issue, which records its initial values.
+func newConflictResolution(config cfg.Config, ghIssue models.ExtendedGithubIssue) conflictResolution {
+	resolution := conflictResolution{
+		record:  syncRecord{Fields: map[string]fieldRecord{}},
+		changed: config.DetectsConflicts(),
+		written: map[string]bool{},
+	}
+	if !config.DetectsConflicts() {
+		return resolution
+	}
+
+	for _, field := range cfg.ConflictFields {
+		value, _ := fieldValues(field, ghIssue, jira.Issue{Fields: &jira.IssueFields{}})
+		resolution.record.Fields[field] = newFieldRecord(config, field, value)
+		resolution.written[field] = true
+	}
+
+	return resolution
+}
+
+// recordStatus records the state of a GitHub issue, and whether its JIRA issue is
+// done, once they are synced. Nothing is recorded unless the status is synced
+// back to GitHub.
+func (r *conflictResolution) recordStatus(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue) {
+	if !config.ReverseSyncs(cfg.ReverseSyncStatus) || jIssue.Fields.Status == nil {
+		return
+	}
+
+	status := statusRecord{GitHubState: ghIssue.GetState(), JIRADone: jiraDone(jIssue)}
+	if r.record.Status == nil || *r.record.Status != status {
+		r.record.Status = &status
+		r.changed = true
+	}
+}
+
+// applyConflictResolution stores the sync record of a JIRA issue once it has been
+// updated, then flags any new conflict with the `conflict-label` label and a
+// comment explaining it. jIssue must be read back from JIRA after the update:
+// JIRA normalizes what it's sent, e.g. line endings, so the record holds the
+// hash of the value JIRA stored.
+func applyConflictResolution(config cfg.Config, resolution conflictResolution, jIssue jira.Issue, jClient issuesyncjira.Client) error {
+	for field, written := range resolution.written {
+		if !written {
+			continue
+		}
+		_, jValue := fieldValues(field, models.ExtendedGithubIssue{}, jIssue)
+		if r := resolution.record.Fields[field]; r.JIRA != hashValue(jValue) {
+			r.JIRA = hashValue(jValue)
+			resolution.record.Fields[field] = r
+			resolution.changed = true
+		}
+	}
+
+	if resolution.changed {
+		if err := issuesyncjira.SetIssueProperty(jClient, config.GetTimeout(), jIssue.Key, syncRecordProperty, resolution.record); err != nil {
+			return err
+		}
+	}
+
+	if len(resolution.conflicts) == 0 {
+		return nil
+	}
+
+	label := config.GetConflictLabel()
+	for _, l := range jIssue.Fields.Labels {
+		if l == label {
+			return nil
+		}
+	}
+
+	labels := append(append([]string{}, jIssue.Fields.Labels...), label)
+	if err := issuesyncjira.UpdateIssueFields(jClient, config.GetTimeout(), jIssue.Key, map[string]interface{}{
+		"labels": labels,
+	}); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf(
+		"The %s of this issue changed both in JIRA and on GitHub since the last sync, so issue-sync left it as it is. "+
+			"Resolve the conflict by editing this issue, then remove the %s label.",
+		strings.Join(resolution.conflicts, " and "),
+		label,
+	)
+	return issuesyncjira.CreateNote(jClient, config.GetTimeout(), jIssue.Key, body)
+}
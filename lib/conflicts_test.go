@@ -0,0 +1,85 @@
+package lib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// newTestPropertyClient returns a JIRA client which keeps issue properties in
+// memory.
+func newTestPropertyClient(t *testing.T, config cfg.Config) issuesyncjira.Client {
+	properties := map[string]json.RawMessage{}
+
+	client := issuesyncjira.NewTestClient()
+	client.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	client.HandleDo = func(method string, url string, body interface{}, out interface{}) (*jira.Response, error) {
+		parts := strings.Split(url, "/properties")
+		var response interface{}
+		switch {
+		case method == "PUT":
+			b, err := json.Marshal(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			properties[strings.TrimPrefix(parts[1], "/")] = b
+			return &jira.Response{}, nil
+		case parts[1] == "":
+			var keys []map[string]string
+			for key := range properties {
+				keys = append(keys, map[string]string{"key": key})
+			}
+			response = map[string]interface{}{"keys": keys}
+		default:
+			response = map[string]interface{}{"value": properties[strings.TrimPrefix(parts[1], "/")]}
+		}
+		b, err := json.Marshal(response)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &jira.Response{}, json.Unmarshal(b, out)
+	}
+	return client
+}
+
+func TestConflictRecordHashesStoredValue(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":       "error",
+		"conflict-policy": map[string]interface{}{"description": cfg.ConflictSkipAndFlag},
+	})
+	client := newTestPropertyClient(t, config)
+
+	ghIssue := models.ExtendedGithubIssue{Issue: github.Issue{
+		Number: github.Int(1),
+		Title:  github.String("Crash"),
+		Body:   github.String("Steps:\r\n1. Start it  \r\n"),
+	}}
+	// JIRA normalizes line endings and trailing whitespace.
+	stored := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{Summary: "Crash", Description: "Steps:\n1. Start it"}}
+
+	if err := applyConflictResolution(config, newConflictResolution(config, ghIssue), stored, client); err != nil {
+		t.Fatalf("applyConflictResolution failed with error: %v", err)
+	}
+
+	ghIssue.Body = github.String("Steps:\r\n1. Start it  \r\n2. Crash\r\n")
+	record, found, err := loadSyncRecord(config, stored, client)
+	if err != nil {
+		t.Fatalf("loadSyncRecord failed with error: %v", err)
+	}
+	resolution := resolveConflicts(config, &ghIssue, stored, record, found)
+	if len(resolution.conflicts) != 0 {
+		t.Errorf("Expected a GitHub edit of an issue JIRA normalized not to conflict; Got conflicts on %v", resolution.conflicts)
+	}
+	if ghIssue.GetBody() != "Steps:\r\n1. Start it  \r\n2. Crash\r\n" {
+		t.Errorf("Expected the GitHub description to be written; Got %q", ghIssue.GetBody())
+	}
+}
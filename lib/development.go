@@ -0,0 +1,161 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// repositoryURLRegex matches the API URL of a GitHub repository. It has matching
+// groups for the owner (\1) and the repository name (\2).
+var repositoryURLRegex = regexp.MustCompile(`/repos/([^/]+)/([^/]+)$`)
+
+// commitURLRegex matches the API URL of a GitHub commit. It has matching groups
+// for the owner (\1), the repository name (\2), and the commit SHA (\3).
+var commitURLRegex = regexp.MustCompile(`/repos/([^/]+)/([^/]+)/commits/([0-9a-fA-F]+)$`)
+
+// loadDevelopment resolves the commits and pull requests referencing a GitHub
+// issue from its timeline, and stores them on the issue. It does nothing unless
+// they are needed, either for the development section or for remote links.
+func loadDevelopment(config cfg.Config, ghIssue *models.ExtendedGithubIssue, ghClient issuesyncgithub.Client) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	if !config.AddDevelopmentSection() && !config.SyncRemoteLinks() {
+		return nil
+	}
+
+	events, err := issuesyncgithub.ListTimeline(ghClient, config.GetTimeout(), user, repoName, ghIssue.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	ghIssue.Commits = nil
+	ghIssue.PullRequests = nil
+
+	for _, e := range events {
+		if e.Source != nil && e.Source.Issue != nil && e.Source.Issue.PullRequestLinks != nil {
+			m := repositoryURLRegex.FindStringSubmatch(e.Source.Issue.GetRepositoryURL())
+			if m == nil {
+				continue
+			}
+			repo := fmt.Sprintf("%s/%s", m[1], m[2])
+			key := fmt.Sprintf("%s#%d", repo, e.Source.Issue.GetNumber())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			pr, err := issuesyncgithub.GetPullRequest(ghClient, config.GetTimeout(), m[1], m[2], e.Source.Issue.GetNumber())
+			if err != nil {
+				log.Errorf("Error retrieving pull request referencing GitHub #%d. Error: %v", ghIssue.GetNumber(), err)
+				continue
+			}
+			ghIssue.PullRequests = append(ghIssue.PullRequests, models.PullRequest{
+				Repo:   repo,
+				Number: pr.GetNumber(),
+				Title:  pr.GetTitle(),
+				URL:    pr.GetHTMLURL(),
+				State:  pr.GetState(),
+				Merged: pr.GetMerged(),
+			})
+		} else if e.CommitID != nil {
+			m := commitURLRegex.FindStringSubmatch(e.GetCommitURL())
+			if m == nil {
+				continue
+			}
+			repo := fmt.Sprintf("%s/%s", m[1], m[2])
+			key := fmt.Sprintf("%s@%s", repo, m[3])
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			commit := models.Commit{
+				Repo: repo,
+				SHA:  m[3],
+				URL:  fmt.Sprintf("%s/%s/commit/%s", config.GetGitHubHTMLURL(), repo, m[3]),
+			}
+			rc, err := issuesyncgithub.GetCommit(ghClient, config.GetTimeout(), m[1], m[2], m[3])
+			if err != nil {
+				// The commit is still worth linking without its details.
+				log.Errorf("Error retrieving commit referencing GitHub #%d. Error: %v", ghIssue.GetNumber(), err)
+			} else {
+				commit.Message = rc.GetCommit().GetMessage()
+				commit.Author = rc.GetCommit().GetAuthor().GetName()
+				if rc.GetAuthor().GetLogin() != "" {
+					commit.Author = rc.GetAuthor().GetLogin()
+				}
+				if rc.GetHTMLURL() != "" {
+					commit.URL = rc.GetHTMLURL()
+				}
+			}
+			ghIssue.Commits = append(ghIssue.Commits, commit)
+		}
+	}
+
+	return nil
+}
+
+// shortSHA returns the abbreviated form of a commit SHA, as GitHub displays it.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// formatDevelopmentSection renders the commits and pull requests of a GitHub
+// issue as a "Development" section in JIRA markup. It returns an empty string if
+// there are none.
+func formatDevelopmentSection(ghIssue models.ExtendedGithubIssue) string {
+	if len(ghIssue.Commits) == 0 && len(ghIssue.PullRequests) == 0 {
+		return ""
+	}
+
+	lines := []string{"----", "h4. Development"}
+	for _, pr := range ghIssue.PullRequests {
+		state := strings.Title(pr.State)
+		if pr.Merged {
+			state = "Merged"
+		}
+		lines = append(lines, fmt.Sprintf("* Pull request [%s#%d|%s]: %s (%s)", pr.Repo, pr.Number, pr.URL, pr.Title, state))
+	}
+	for _, c := range ghIssue.Commits {
+		line := fmt.Sprintf("* Commit [%s@%s|%s]", c.Repo, shortSHA(c.SHA), c.URL)
+		if c.Message != "" {
+			line = fmt.Sprintf("%s: %s", line, strings.SplitN(c.Message, "\n", 2)[0])
+		}
+		if c.Author != "" {
+			line = fmt.Sprintf("%s (%s)", line, c.Author)
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// withDevelopmentSection returns a copy of the GitHub issue whose body ends with
+// its development section, if `development-section` is set.
+func withDevelopmentSection(config cfg.Config, ghIssue models.ExtendedGithubIssue) models.ExtendedGithubIssue {
+	if !config.AddDevelopmentSection() {
+		return ghIssue
+	}
+
+	section := formatDevelopmentSection(ghIssue)
+	if section == "" {
+		return ghIssue
+	}
+
+	body := section
+	if ghIssue.GetBody() != "" {
+		body = fmt.Sprintf("%s\n\n%s", ghIssue.GetBody(), section)
+	}
+	ghIssue.Body = &body
+	return ghIssue
+}
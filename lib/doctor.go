@@ -0,0 +1,550 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+// CheckStatus is the outcome of a single doctor check.
+type CheckStatus int
+
+const (
+	// CheckPassed means nothing needs to be done.
+	CheckPassed CheckStatus = iota
+	// CheckWarning means issue-sync works, but some features may not.
+	CheckWarning
+	// CheckFailed means issue-sync won't work until the problem is fixed.
+	CheckFailed
+)
+
+// String returns the label of the status in a doctor report.
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckPassed:
+		return "OK"
+	case CheckWarning:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// DoctorCheck is the result of checking one part of the setup.
+type DoctorCheck struct {
+	Name    string
+	Status  CheckStatus
+	Message string
+	// Fix describes how to fix a failure or warning.
+	Fix string
+}
+
+// DoctorReport is the result of every check made by RunDoctor.
+type DoctorReport struct {
+	Checks []DoctorCheck
+}
+
+// Failed returns whether any check failed.
+func (r DoctorReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == CheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the report in a human-readable format.
+func (r DoctorReport) Print(w io.Writer) {
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "[%-4s] %s: %s\n", c.Status, c.Name, c.Message)
+		if c.Fix != "" && c.Status != CheckPassed {
+			fmt.Fprintf(w, "       Fix: %s\n", c.Fix)
+		}
+	}
+}
+
+func (r *DoctorReport) add(status CheckStatus, name string, message string, fix string) {
+	r.Checks = append(r.Checks, DoctorCheck{Name: name, Status: status, Message: message, Fix: fix})
+}
+
+// RunDoctor checks that GitHub and JIRA are set up as issue-sync needs them to
+// be: that the GitHub token can access the repository, that the JIRA project
+// exists and has the custom fields of the field mapper on its screens, and that
+// the configured statuses and issue link types exist. It uses the API clients
+// directly, so it works when the regular clients can't be created.
+func RunDoctor(config cfg.Config, ghClient *github.Client, jClient *jira.Client) DoctorReport {
+	var report DoctorReport
+
+	checkGitHub(config, ghClient, &report)
+	statuses := checkJIRA(config, jClient, &report)
+	if statuses != nil {
+		checkProjectColumns(config, ghClient, statuses, &report)
+	}
+
+	return report
+}
+
+// checkGitHub checks the GitHub token and its access to the repository.
+func checkGitHub(config cfg.Config, ghClient *github.Client, report *DoctorReport) {
+	owner, repoName := config.GetRepo()
+	fullName := fmt.Sprintf("%s/%s", owner, repoName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+	defer cancel()
+
+	if config.UsesGitHubApp() {
+		checkGitHubApp(config, ghClient, report)
+		return
+	}
+
+	user, res, err := ghClient.Users.Get(ctx, "")
+	if err != nil {
+		report.add(CheckFailed, "GitHub authentication", err.Error(),
+			"check that github-token is a valid, unexpired GitHub token")
+		return
+	}
+	report.add(CheckPassed, "GitHub authentication", fmt.Sprintf("authenticated as %s", user.GetLogin()), "")
+
+	repo, _, err := ghClient.Repositories.Get(ctx, owner, repoName)
+	if err != nil {
+		report.add(CheckFailed, "GitHub repository", err.Error(),
+			fmt.Sprintf("check that repo-name is correct and that %s can access %s", user.GetLogin(), fullName))
+		return
+	}
+
+	var scopes []string
+	_, hasScopes := res.Header[http.CanonicalHeaderKey("X-OAuth-Scopes")]
+	for _, s := range strings.Split(res.Header.Get("X-OAuth-Scopes"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	writes := len(config.GetReverseSyncFields()) > 0
+	switch {
+	case !hasScopes:
+		report.add(CheckWarning, "GitHub token scopes", "the token's scopes can't be listed, as for fine-grained tokens",
+			fmt.Sprintf("make sure the token can read issues and projects of %s", fullName))
+	case containsFold(scopes, "repo"):
+		report.add(CheckPassed, "GitHub token scopes", strings.Join(scopes, ", "), "")
+	case repo.GetPrivate():
+		report.add(CheckFailed, "GitHub token scopes", fmt.Sprintf("%s is private, but the token only has scopes: %s", fullName, strings.Join(scopes, ", ")),
+			"create a token with the repo scope")
+	case writes && !containsFold(scopes, "public_repo"):
+		report.add(CheckFailed, "GitHub token scopes", "reverse-sync is enabled, but the token can't write to public repositories",
+			"create a token with the public_repo scope")
+	default:
+		report.add(CheckPassed, "GitHub token scopes", strings.Join(scopes, ", "), "")
+	}
+
+	var permissions map[string]bool
+	if repo.Permissions != nil {
+		permissions = *repo.Permissions
+	}
+	switch {
+	case !repo.GetHasIssues():
+		report.add(CheckFailed, "GitHub repository", fmt.Sprintf("%s has issues disabled", fullName),
+			"enable issues in the repository settings")
+	case writes && !permissions["push"]:
+		report.add(CheckFailed, "GitHub repository", fmt.Sprintf("reverse-sync is enabled, but %s can't edit issues of %s", user.GetLogin(), fullName),
+			"give the user write access to the repository, or disable reverse-sync")
+	default:
+		report.add(CheckPassed, "GitHub repository", fmt.Sprintf("%s is accessible", fullName), "")
+	}
+}
+
+// checkGitHubApp checks that the GitHub App is installed on the repository. Apps
+// have permissions rather than scopes, which can't be checked with an
+// installation token.
+func checkGitHubApp(config cfg.Config, ghClient *github.Client, report *DoctorReport) {
+	owner, repoName := config.GetRepo()
+	fullName := fmt.Sprintf("%s/%s", owner, repoName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+	defer cancel()
+
+	repo, _, err := ghClient.Repositories.Get(ctx, owner, repoName)
+	if err != nil {
+		report.add(CheckFailed, "GitHub App authentication", err.Error(),
+			fmt.Sprintf("check github-app-id and github-app-private-key-path, and that the app is installed on %s", fullName))
+		return
+	}
+	report.add(CheckPassed, "GitHub App authentication", fmt.Sprintf("authenticated as app %d", config.GetGitHubAppID()), "")
+
+	if !repo.GetHasIssues() {
+		report.add(CheckFailed, "GitHub repository", fmt.Sprintf("%s has issues disabled", fullName),
+			"enable issues in the repository settings")
+		return
+	}
+	report.add(CheckPassed, "GitHub repository", fmt.Sprintf("%s is accessible", fullName), "")
+}
+
+// workflowTransition is a transition of a JIRA workflow, to the status with the
+// ID To. Initial transitions only create issues, so they can't be applied.
+type workflowTransition struct {
+	Name string `json:"name"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// workflow is a JIRA workflow, with its statuses and transitions.
+type workflow struct {
+	Statuses []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"statuses"`
+	Transitions []workflowTransition `json:"transitions"`
+}
+
+// checkJIRA checks the JIRA credentials, project, custom fields and configured
+// issue link types and statuses. It returns the names of the statuses the
+// project's issues can be transitioned to, or nil if they couldn't be found.
+func checkJIRA(config cfg.Config, jClient *jira.Client, report *DoctorReport) map[string]bool {
+	user, _, err := jClient.User.GetSelf()
+	if err != nil {
+		report.add(CheckFailed, "JIRA authentication", err.Error(),
+			fmt.Sprintf("check jira-uri and the credentials of the %s authentication method", config.GetJIRAAuthMethod()))
+		return nil
+	}
+	report.add(CheckPassed, "JIRA authentication", fmt.Sprintf("authenticated as %s", user.DisplayName), "")
+
+	key := config.GetConfigString("jira-project")
+	project, _, err := jClient.Project.Get(key)
+	if err != nil {
+		report.add(CheckFailed, "JIRA project", fmt.Sprintf("%s: %v", key, err),
+			fmt.Sprintf("check that jira-project is correct and that %s can browse it", user.DisplayName))
+		return nil
+	}
+	report.add(CheckPassed, "JIRA project", fmt.Sprintf("%s (%s)", project.Key, project.Name), "")
+
+	checkCustomFields(config, jClient, project.Key, report)
+	checkLinkTypes(config, jClient, report)
+
+	statuses, err := getReachableStatuses(config, jClient, project)
+	if err != nil {
+		report.add(CheckWarning, "JIRA workflow", fmt.Sprintf("unable to read the workflow of %s issues, so only the names of statuses are checked: %v", config.GetIssueTypeName(), err),
+			"give the JIRA user the Administer Jira permission to check the transitions")
+		statuses, err = getStatusNames(jClient, project.Key, config.GetIssueTypeName())
+		if err != nil {
+			report.add(CheckWarning, "JIRA statuses", err.Error(), "")
+			return nil
+		}
+	}
+	if config.GetUnmatchedPolicy() == cfg.UnmatchedClose {
+		status := config.GetUnmatchedCloseStatus()
+		if statuses[strings.ToLower(status)] {
+			report.add(CheckPassed, "JIRA statuses", fmt.Sprintf("status %q can be transitioned to", status), "")
+		} else {
+			report.add(CheckFailed, "JIRA statuses", fmt.Sprintf("no transition of the workflow of %s issues leads to status %q of unmatched-close-status", config.GetIssueTypeName(), status),
+				"set unmatched-close-status to the name of a status a transition leads to, or add such a transition to the workflow")
+		}
+	}
+
+	if config.GetJIRABoardID() != 0 {
+		if board, _, err := jClient.Board.GetBoard(config.GetJIRABoardID()); err != nil {
+			report.add(CheckFailed, "JIRA board", fmt.Sprintf("board %d: %v", config.GetJIRABoardID(), err),
+				"check that jira-board-id is the ID of a board the JIRA user can see")
+		} else {
+			report.add(CheckPassed, "JIRA board", board.Name, "")
+		}
+	}
+
+	return statuses
+}
+
+// checkCustomFields checks that each custom field of the field mapper exists with
+// the right type, and is on the create and edit screens of the project's issues
+// of the field mapper's issue type.
+func checkCustomFields(config cfg.Config, jClient *jira.Client, projectKey string, report *DoctorReport) {
+	jFields, err := cfg.GetJIRAFields(*jClient)
+	if err != nil {
+		report.add(CheckFailed, "JIRA custom fields", err.Error(), "check that the JIRA user can list fields")
+		return
+	}
+	ids := map[string]cfg.JIRAField{}
+	for _, f := range jFields {
+		ids[f.Name] = f
+	}
+
+	issueType := config.GetIssueTypeName()
+	createFields, err := getCreateFields(jClient, projectKey, issueType)
+	if err != nil {
+		report.add(CheckWarning, "JIRA create screen", err.Error(), "")
+	}
+	editFields, editKey, err := getEditFields(jClient, projectKey, issueType)
+	if err != nil {
+		report.add(CheckWarning, "JIRA edit screen", err.Error(), "")
+	}
+
+	for _, want := range config.GetCustomFields() {
+		name := fmt.Sprintf("JIRA field %q", want.Name)
+		failure := CheckFailed
+		if !want.Required {
+			failure = CheckWarning
+		}
+
+		field, ok := ids[want.Name]
+		if !ok {
+			report.add(failure, name, "doesn't exist",
+				fmt.Sprintf("create a %s custom field named exactly %q, or run `issue-sync setup-jira`", want.Type, want.Name))
+			continue
+		}
+		if field.Schema.Type != want.Type {
+			report.add(failure, name, fmt.Sprintf("has type %s, but must be a %s field", field.Schema.Type, want.Type),
+				fmt.Sprintf("replace it with a %s custom field of the same name", want.Type))
+			continue
+		}
+
+		var missing []string
+		if createFields != nil && !createFields[field.ID] {
+			missing = append(missing, "create")
+		}
+		if editFields != nil && !editFields[field.ID] {
+			missing = append(missing, fmt.Sprintf("edit (checked on %s)", editKey))
+		}
+		if len(missing) > 0 {
+			report.add(failure, name, fmt.Sprintf("%s isn't on the %s screen of %s issues in %s", field.ID, strings.Join(missing, " and "), issueType, projectKey),
+				"add the field to the screens of the project's screen scheme")
+			continue
+		}
+
+		report.add(CheckPassed, name, field.ID, "")
+	}
+
+	if config.GetHierarchyMapping() == cfg.HierarchyToEpicLink {
+		if _, ok := ids["Epic Link"]; ok {
+			report.add(CheckPassed, `JIRA field "Epic Link"`, ids["Epic Link"].ID, "")
+		} else {
+			report.add(CheckFailed, `JIRA field "Epic Link"`, "doesn't exist, but hierarchy-mapping is epic-link",
+				"install JIRA Software, or use another hierarchy-mapping")
+		}
+	}
+}
+
+// getCreateFields returns the IDs of the fields on the create screen of issues of
+// a type in a JIRA project.
+func getCreateFields(jClient *jira.Client, projectKey string, issueTypeName string) (map[string]bool, error) {
+	meta, _, err := jClient.Issue.GetCreateMeta(projectKey)
+	if err != nil {
+		return nil, err
+	}
+	project := meta.GetProjectWithKey(projectKey)
+	if project == nil {
+		return nil, fmt.Errorf("the JIRA user can't create issues in %s", projectKey)
+	}
+	issueType := project.GetIssueTypeWithName(issueTypeName)
+	if issueType == nil {
+		return nil, fmt.Errorf("%s has no %s issue type", projectKey, issueTypeName)
+	}
+
+	fields := map[string]bool{}
+	for id := range issueType.Fields {
+		fields[id] = true
+	}
+	return fields, nil
+}
+
+// getEditFields returns the IDs of the fields on the edit screen of the latest
+// issue of a type in a JIRA project, and its key. The edit screen can only be
+// checked on an existing issue, so nil is returned if there's none.
+func getEditFields(jClient *jira.Client, projectKey string, issueTypeName string) (map[string]bool, string, error) {
+	jql := fmt.Sprintf("project = '%s' AND issuetype = '%s' ORDER BY created DESC", projectKey, issueTypeName)
+	issues, _, err := jClient.Issue.Search(jql, &jira.SearchOptions{MaxResults: 1, Fields: []string{"key"}})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(issues) == 0 {
+		return nil, "", nil
+	}
+	key := issues[0].Key
+
+	req, err := jClient.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/editmeta", key), nil)
+	if err != nil {
+		return nil, key, err
+	}
+	var meta struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if _, err := jClient.Do(req, &meta); err != nil {
+		return nil, key, err
+	}
+
+	fields := map[string]bool{}
+	for id := range meta.Fields {
+		fields[id] = true
+	}
+	return fields, key, nil
+}
+
+// checkLinkTypes checks that the configured issue link types exist.
+func checkLinkTypes(config cfg.Config, jClient *jira.Client, report *DoctorReport) {
+	configured := map[string]string{}
+	if config.GetHierarchyMapping() == cfg.HierarchyToIssueLink {
+		configured["hierarchy-link-type"] = config.GetHierarchyLinkType()
+	}
+	if t := config.GetReferenceLinkType(); t != "" {
+		configured["reference-link-type"] = t
+	}
+	if len(configured) == 0 {
+		return
+	}
+
+	req, err := jClient.NewRequest("GET", "rest/api/2/issueLinkType", nil)
+	if err != nil {
+		report.add(CheckWarning, "JIRA issue link types", err.Error(), "")
+		return
+	}
+	var linkTypes struct {
+		IssueLinkTypes []jira.IssueLinkType `json:"issueLinkTypes"`
+	}
+	if _, err := jClient.Do(req, &linkTypes); err != nil {
+		report.add(CheckWarning, "JIRA issue link types", err.Error(), "")
+		return
+	}
+
+	for option, name := range configured {
+		found := false
+		for _, t := range linkTypes.IssueLinkTypes {
+			found = found || t.Name == name
+		}
+		if found {
+			report.add(CheckPassed, "JIRA issue link types", fmt.Sprintf("%s %q exists", option, name), "")
+		} else {
+			report.add(CheckFailed, "JIRA issue link types", fmt.Sprintf("%s %q doesn't exist", option, name),
+				fmt.Sprintf("set %s to the name of an existing issue link type", option))
+		}
+	}
+}
+
+// getReachableStatuses returns the lowercase names of the statuses which a
+// transition of the workflow of the field mapper's issue type in a JIRA project
+// leads to, as issues are only ever transitioned to them. The workflow is the
+// one the project's workflow scheme maps the issue type to.
+func getReachableStatuses(config cfg.Config, jClient *jira.Client, project *jira.Project) (map[string]bool, error) {
+	issueTypeID := ""
+	for _, t := range project.IssueTypes {
+		if t.Name == config.GetIssueTypeName() {
+			issueTypeID = t.ID
+		}
+	}
+	if issueTypeID == "" {
+		return nil, fmt.Errorf("%s has no %s issue type", project.Key, config.GetIssueTypeName())
+	}
+
+	req, err := jClient.NewRequest("GET", "rest/api/2/workflowscheme/project?projectId="+project.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	var schemes struct {
+		Values []struct {
+			WorkflowScheme struct {
+				DefaultWorkflow   string            `json:"defaultWorkflow"`
+				IssueTypeMappings map[string]string `json:"issueTypeMappings"`
+			} `json:"workflowScheme"`
+		} `json:"values"`
+	}
+	if res, err := jClient.Do(req, &schemes); err != nil {
+		return nil, jira.NewJiraError(res, err)
+	}
+	if len(schemes.Values) == 0 {
+		return nil, fmt.Errorf("%s has no workflow scheme", project.Key)
+	}
+	scheme := schemes.Values[0].WorkflowScheme
+	workflowName, ok := scheme.IssueTypeMappings[issueTypeID]
+	if !ok {
+		workflowName = scheme.DefaultWorkflow
+	}
+
+	var workflows []workflow
+	if err := getPagedValues(jClient, "rest/api/2/workflow/search?expand=statuses,transitions&workflowName="+url.QueryEscape(workflowName), &workflows); err != nil {
+		return nil, err
+	}
+	if len(workflows) == 0 {
+		return nil, fmt.Errorf("workflow %q doesn't exist", workflowName)
+	}
+
+	names := map[string]string{}
+	for _, s := range workflows[0].Statuses {
+		names[s.ID] = s.Name
+	}
+	statuses := map[string]bool{}
+	for _, t := range workflows[0].Transitions {
+		if t.Type != "initial" && names[t.To] != "" {
+			statuses[strings.ToLower(names[t.To])] = true
+		}
+	}
+	return statuses, nil
+}
+
+// getStatusNames returns the lowercase names of the statuses issues of a type can
+// have in a JIRA project.
+func getStatusNames(jClient *jira.Client, projectKey string, issueTypeName string) (map[string]bool, error) {
+	req, err := jClient.NewRequest("GET", fmt.Sprintf("rest/api/2/project/%s/statuses", projectKey), nil)
+	if err != nil {
+		return nil, err
+	}
+	var issueTypes []struct {
+		Name     string        `json:"name"`
+		Statuses []jira.Status `json:"statuses"`
+	}
+	if _, err := jClient.Do(req, &issueTypes); err != nil {
+		return nil, err
+	}
+
+	statuses := map[string]bool{}
+	for _, t := range issueTypes {
+		if !strings.EqualFold(t.Name, issueTypeName) {
+			continue
+		}
+		for _, s := range t.Statuses {
+			statuses[strings.ToLower(s.Name)] = true
+		}
+	}
+	return statuses, nil
+}
+
+// checkProjectColumns checks that each column of the repository's GitHub projects
+// has a JIRA status of the same name which can be transitioned to, since issues
+// are transitioned to the status named after the column of their project card.
+func checkProjectColumns(config cfg.Config, ghClient *github.Client, statuses map[string]bool, report *DoctorReport) {
+	owner, repoName := config.GetRepo()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+	defer cancel()
+
+	projects, _, err := ghClient.Repositories.ListProjects(ctx, owner, repoName, &github.ProjectListOptions{State: "open"})
+	if err != nil {
+		// Repositories with projects disabled return an error; nothing to check.
+		return
+	}
+
+	for _, p := range projects {
+		columns, _, err := ghClient.Projects.ListProjectColumns(ctx, p.GetID(), nil)
+		if err != nil {
+			report.add(CheckWarning, "JIRA transitions", fmt.Sprintf("columns of GitHub project %q: %v", p.GetName(), err), "")
+			continue
+		}
+
+		var missing []string
+		for _, c := range columns {
+			if !statuses[strings.ToLower(c.GetName())] {
+				missing = append(missing, fmt.Sprintf("%q", c.GetName()))
+			}
+		}
+
+		if len(missing) == 0 {
+			report.add(CheckPassed, "JIRA transitions", fmt.Sprintf("every column of GitHub project %q has a status which can be transitioned to", p.GetName()), "")
+		} else {
+			report.add(CheckWarning, "JIRA transitions", fmt.Sprintf("no JIRA transition leads to a status named after columns %s of GitHub project %q", strings.Join(missing, ", "), p.GetName()),
+				"add the statuses and transitions to them to the project's workflow, or rename the columns; issues in these columns won't be transitioned")
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package lib
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+func TestDoctorReport(t *testing.T) {
+	var report DoctorReport
+	report.add(CheckPassed, "GitHub authentication", "authenticated as octocat", "")
+	report.add(CheckWarning, "JIRA transitions", "missing statuses", "add them")
+
+	if report.Failed() {
+		t.Error("Expected a report without failures not to have failed")
+	}
+
+	report.add(CheckFailed, `JIRA field "GitHub ID"`, "doesn't exist", "create it")
+	if !report.Failed() {
+		t.Error("Expected a report with a failure to have failed")
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	expected := "[OK  ] GitHub authentication: authenticated as octocat\n" +
+		"[WARN] JIRA transitions: missing statuses\n" +
+		"       Fix: add them\n" +
+		"[FAIL] JIRA field \"GitHub ID\": doesn't exist\n" +
+		"       Fix: create it\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected report:\n%s", out.String())
+	}
+}
+
+func TestGetReachableStatuses(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/workflowscheme/project", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("projectId") != "10000" {
+			t.Errorf("Unexpected project ID %q", r.URL.Query().Get("projectId"))
+		}
+		fmt.Fprint(w, `{"values": [{"projectIds": ["10000"], "workflowScheme": {
+			"defaultWorkflow": "jira",
+			"issueTypeMappings": {"3": "Task workflow"}
+		}}]}`)
+	})
+	mux.HandleFunc("/rest/api/2/workflow/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("workflowName") != "Task workflow" {
+			t.Errorf("Unexpected workflow %q", r.URL.Query().Get("workflowName"))
+		}
+		// Backlog is a status of the workflow, but only the initial transition
+		// leads to it.
+		fmt.Fprint(w, `{"isLast": true, "values": [{
+			"statuses": [{"id": "1", "name": "Backlog"}, {"id": "2", "name": "In Progress"}, {"id": "3", "name": "Done"}],
+			"transitions": [
+				{"name": "Create", "from": [], "to": "1", "type": "initial"},
+				{"name": "Start", "from": ["1"], "to": "2", "type": "directed"},
+				{"name": "Finish", "from": [], "to": "3", "type": "global"}
+			]
+		}]}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	jClient, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":                   "error",
+		"github-to-jira-field-mapper": cfg.FieldMapperJSON,
+	})
+	project := &jira.Project{ID: "10000", Key: "SYNC", IssueTypes: []jira.IssueType{
+		{ID: "1", Name: "Bug"},
+		{ID: "3", Name: "Task"},
+	}}
+
+	statuses, err := getReachableStatuses(config, jClient, project)
+	if err != nil {
+		t.Fatalf("getReachableStatuses failed with error: %v", err)
+	}
+	if expected := map[string]bool{"in progress": true, "done": true}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected the statuses %v; got %v", expected, statuses)
+	}
+
+	project.IssueTypes = project.IssueTypes[:1]
+	if _, err := getReachableStatuses(config, jClient, project); err == nil {
+		t.Error("Expected getReachableStatuses to fail for a project without the issue type")
+	}
+}
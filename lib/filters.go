@@ -0,0 +1,178 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// issueFilter decides which GitHub issues are synced, based on their fields.
+// Empty lists don't filter anything.
+type issueFilter struct {
+	includeLabels  []string
+	excludeLabels  []string
+	state          string
+	excludeAuthors []string
+	excludeBots    bool
+	milestones     []string
+}
+
+// newIssueFilter creates the issueFilter described by the configuration.
+func newIssueFilter(config cfg.Config) issueFilter {
+	return issueFilter{
+		includeLabels:  config.GetIncludeLabels(),
+		excludeLabels:  config.GetExcludeLabels(),
+		state:          config.GetIssueState(),
+		excludeAuthors: config.GetExcludeAuthors(),
+		excludeBots:    config.ExcludeBots(),
+		milestones:     config.GetIncludeMilestones(),
+	}
+}
+
+// containsFold returns whether the list contains the string, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns whether a GitHub issue passes the filter.
+func (f issueFilter) matches(ghIssue models.ExtendedGithubIssue) bool {
+	if f.state != "" && f.state != "all" && ghIssue.GetState() != f.state {
+		return false
+	}
+
+	login := ghIssue.GetUser().GetLogin()
+	if containsFold(f.excludeAuthors, login) {
+		return false
+	}
+	if f.excludeBots && (ghIssue.GetUser().GetType() == "Bot" || strings.HasSuffix(login, "[bot]")) {
+		return false
+	}
+
+	if len(f.milestones) > 0 && (ghIssue.Milestone == nil || !containsFold(f.milestones, ghIssue.Milestone.GetTitle())) {
+		return false
+	}
+
+	included := len(f.includeLabels) == 0
+	for _, l := range ghIssue.Labels {
+		if containsFold(f.excludeLabels, l.GetName()) {
+			return false
+		}
+		included = included || containsFold(f.includeLabels, l.GetName())
+	}
+
+	return included
+}
+
+// filterIssues splits GitHub issues into those which pass the configured filters,
+// including the `search-query`, and those which don't.
+func filterIssues(config cfg.Config, ghIssues []models.ExtendedGithubIssue, ghClient issuesyncgithub.Client) ([]models.ExtendedGithubIssue, []models.ExtendedGithubIssue, error) {
+	user, repoName := config.GetRepo()
+	filter := newIssueFilter(config)
+
+	if len(ghIssues) == 0 {
+		return nil, nil, nil
+	}
+
+	var candidates, unmatched []models.ExtendedGithubIssue
+	for _, ghIssue := range ghIssues {
+		if filter.matches(ghIssue) {
+			candidates = append(candidates, ghIssue)
+		} else {
+			unmatched = append(unmatched, ghIssue)
+		}
+	}
+
+	query := config.GetSearchQuery()
+	if query == "" || len(candidates) == 0 {
+		return candidates, unmatched, nil
+	}
+
+	// Only the issues being synced are searched for, so that what isn't found
+	// really doesn't match.
+	numbers := make([]int, len(candidates))
+	for i, ghIssue := range candidates {
+		numbers[i] = ghIssue.GetNumber()
+	}
+	found, err := issuesyncgithub.SearchIssueNumbers(ghClient, config.GetTimeout(), user, repoName, query, numbers)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var matching []models.ExtendedGithubIssue
+	for _, ghIssue := range candidates {
+		if found[ghIssue.GetNumber()] {
+			matching = append(matching, ghIssue)
+		} else {
+			unmatched = append(unmatched, ghIssue)
+		}
+	}
+
+	return matching, unmatched, nil
+}
+
+// handleUnmatchedIssues applies the `unmatched-policy` to the JIRA issues of GitHub
+// issues which don't pass the filters: they are either left alone, moved to the
+// `unmatched-close-status`, or unlinked from GitHub so they are never synced again.
+// The outcome for each issue is recorded in the run report.
+func handleUnmatchedIssues(config cfg.Config, ghIssues []models.ExtendedGithubIssue, jClient issuesyncjira.Client, report *RunReport) error {
+	log := config.GetLogger()
+
+	policy := config.GetUnmatchedPolicy()
+	if policy == cfg.UnmatchedLeave || len(ghIssues) == 0 {
+		return nil
+	}
+
+	byNumber := map[int]models.ExtendedGithubIssue{}
+	for _, ghIssue := range ghIssues {
+		byNumber[ghIssue.GetNumber()] = ghIssue
+	}
+
+	jIssues, err := jiraIssuesByNumber(config, byNumber, jClient)
+	if err != nil {
+		return err
+	}
+
+	for number, jIssue := range jIssues {
+		log.Debugf("GitHub #%d no longer matches the filters; applying policy %s to JIRA issue %s", number, policy, jIssue.Key)
+
+		var err error
+		switch policy {
+		case cfg.UnmatchedClose:
+			err = issuesyncjira.TryApplyTransitionWithStatusName(jClient, jIssue, config.GetUnmatchedCloseStatus())
+		case cfg.UnmatchedUnlink:
+			err = unlinkIssue(config, jIssue, jClient)
+		}
+		action := policy + "_unmatched"
+		ghIssue := byNumber[number]
+		if err != nil {
+			log.Errorf("Error applying policy %s to JIRA issue %s. Error: %v", policy, jIssue.Key, err)
+			report.failed(action, &ghIssue, jIssue.Key, err)
+		} else {
+			report.succeeded(action, &ghIssue)
+		}
+	}
+
+	return nil
+}
+
+// unlinkIssue clears the fields which tie a JIRA issue to its GitHub issue, so that
+// it isn't found by later syncs.
+func unlinkIssue(config cfg.Config, jIssue jira.Issue, jClient issuesyncjira.Client) error {
+	fields := map[string]interface{}{}
+	for _, key := range []cfg.FieldKey{cfg.GitHubID, cfg.GitHubNumber, cfg.GitHubIssueData} {
+		if config.GetFieldID(key) != "" {
+			fields[config.GetCompleteFieldKey(key)] = nil
+		}
+	}
+
+	return issuesyncjira.UpdateIssueFields(jClient, config.GetTimeout(), jIssue.Key, fields)
+}
@@ -0,0 +1,132 @@
+package lib
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func newTestFilterIssue(login string, userType string, labels ...string) models.ExtendedGithubIssue {
+	state := "open"
+	ghLabels := make([]github.Label, len(labels))
+	for i := range labels {
+		ghLabels[i] = github.Label{Name: &labels[i]}
+	}
+	return models.ExtendedGithubIssue{Issue: github.Issue{
+		State:  &state,
+		User:   &github.User{Login: &login, Type: &userType},
+		Labels: ghLabels,
+	}}
+}
+
+func TestIssueFilterLabels(t *testing.T) {
+	f := issueFilter{includeLabels: []string{"customer-reported"}, excludeLabels: []string{"question"}}
+
+	if !f.matches(newTestFilterIssue("octocat", "User", "bug", "Customer-Reported")) {
+		t.Fatalf("Expected issue with an included label to match")
+	}
+	if f.matches(newTestFilterIssue("octocat", "User", "bug")) {
+		t.Fatalf("Expected issue without an included label not to match")
+	}
+	if f.matches(newTestFilterIssue("octocat", "User", "customer-reported", "question")) {
+		t.Fatalf("Expected issue with an excluded label not to match")
+	}
+}
+
+func TestIssueFilterAuthors(t *testing.T) {
+	f := issueFilter{excludeAuthors: []string{"spammer"}, excludeBots: true}
+
+	if !f.matches(newTestFilterIssue("octocat", "User")) {
+		t.Fatalf("Expected issue from a user to match")
+	}
+	if f.matches(newTestFilterIssue("dependabot[bot]", "Bot")) {
+		t.Fatalf("Expected issue from a bot not to match")
+	}
+	if f.matches(newTestFilterIssue("Spammer", "User")) {
+		t.Fatalf("Expected issue from an excluded author not to match")
+	}
+}
+
+func TestIssueFilterStateAndMilestone(t *testing.T) {
+	title := "v1.0"
+	ghIssue := newTestFilterIssue("octocat", "User")
+	ghIssue.Milestone = &github.Milestone{Title: &title}
+
+	if !(issueFilter{state: "open", milestones: []string{"v1.0"}}).matches(ghIssue) {
+		t.Fatalf("Expected open issue in milestone v1.0 to match")
+	}
+	if (issueFilter{state: "closed"}).matches(ghIssue) {
+		t.Fatalf("Expected open issue not to match closed state")
+	}
+	if (issueFilter{milestones: []string{"v2.0"}}).matches(ghIssue) {
+		t.Fatalf("Expected issue in milestone v1.0 not to match milestone v2.0")
+	}
+}
+
+func newTestSearchClient(config cfg.Config, incomplete bool, queries *[]string) issuesyncgithub.Client {
+	client := issuesyncgithub.NewTestClient()
+	client.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	client.HandleSearchIssues = func(ctx context.Context, query string, page int) (*github.IssuesSearchResult, *github.Response, error) {
+		*queries = append(*queries, query)
+		// Even-numbered issues match, as does issue 100, whose text mentions the
+		// numbers.
+		issues := []github.Issue{{Number: github.Int(100)}}
+		for _, term := range strings.FieldsFunc(query, func(r rune) bool { return r == ' ' || r == '(' || r == ')' }) {
+			if n, err := strconv.Atoi(term); err == nil && n%2 == 0 {
+				issues = append(issues, github.Issue{Number: github.Int(n)})
+			}
+		}
+		return &github.IssuesSearchResult{
+			Total:             github.Int(len(issues)),
+			IncompleteResults: github.Bool(incomplete),
+			Issues:            issues,
+		}, &github.Response{}, nil
+	}
+	return client
+}
+
+func TestFilterIssuesSearchesSyncedNumbers(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":    "error",
+		"repo-name":    "owner/repo",
+		"timeout":      "1m",
+		"search-query": "label:bug",
+	})
+
+	var ghIssues []models.ExtendedGithubIssue
+	for n := 1; n <= 8; n++ {
+		ghIssue := newTestFilterIssue("octocat", "User")
+		ghIssue.Number = github.Int(n)
+		ghIssues = append(ghIssues, ghIssue)
+	}
+
+	var queries []string
+	matching, unmatched, err := filterIssues(config, ghIssues, newTestSearchClient(config, false, &queries))
+	if err != nil {
+		t.Fatalf("filterIssues failed with error: %v", err)
+	}
+	if len(matching) != 4 || len(unmatched) != 4 {
+		t.Fatalf("Expected 4 matching and 4 unmatched issues; Got %d and %d", len(matching), len(unmatched))
+	}
+	expected := []string{
+		"repo:owner/repo is:issue (label:bug) AND (1 OR 2 OR 3 OR 4 OR 5)",
+		"repo:owner/repo is:issue (label:bug) AND (6 OR 7 OR 8)",
+	}
+	if strings.Join(queries, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("Expected queries:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(queries, "\n"))
+	}
+
+	queries = nil
+	if _, _, err := filterIssues(config, ghIssues, newTestSearchClient(config, true, &queries)); err == nil {
+		t.Fatalf("Expected incomplete search results to fail the filtering")
+	}
+}
@@ -0,0 +1,268 @@
+package lib
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// taskListRegex matches an item of a GitHub task list which references an issue. It
+// has matching groups for the repository (\1 or \3, empty for "#123") and for the
+// issue number (\2 or \4). For example, it matches "- [ ] #123", "- [x] owner/repo#123"
+// and "- [ ] https://github.com/owner/repo/issues/123".
+var taskListRegex = regexp.MustCompile(`(?m)^\s*[-*+]\s+\[[ xX]\]\s+(?:([\w.-]+/[\w.-]+)?#(\d+)|https?://[^\s/]+/([\w.-]+/[\w.-]+)/issues/(\d+))`)
+
+// parseTaskListReferences returns the numbers of the issues of the given repository
+// which are referenced by task list items in an issue body.
+func parseTaskListReferences(body string, user string, repoName string) []int {
+	fullName := strings.ToLower(user + "/" + repoName)
+
+	var numbers []int
+	for _, m := range taskListRegex.FindAllStringSubmatch(body, -1) {
+		repo, number := m[1], m[2]
+		if number == "" {
+			repo, number = m[3], m[4]
+		}
+		if repo != "" && strings.ToLower(repo) != fullName {
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, n)
+	}
+
+	return numbers
+}
+
+// findParents returns a map from the number of a GitHub issue to the number of its
+// parent, for every child of the given issues. Children are read both from the
+// sub-issues API and from task lists in the issue bodies; if both disagree, the
+// sub-issue relationship wins. It returns an empty map if hierarchy mapping is off.
+func findParents(config cfg.Config, ghIssues []models.ExtendedGithubIssue, ghClient issuesyncgithub.Client) (map[int]int, error) {
+	log := config.GetLogger()
+	parents := map[int]int{}
+
+	if config.GetHierarchyMapping() == "" {
+		return parents, nil
+	}
+
+	user, repoName := config.GetRepo()
+	repoURLSuffix := strings.ToLower("/repos/" + user + "/" + repoName)
+
+	for _, ghIssue := range ghIssues {
+		subIssues, err := issuesyncgithub.ListSubIssues(ghClient, config.GetTimeout(), user, repoName, ghIssue.GetNumber())
+		if issuesyncgithub.IsNotFound(err) {
+			// Fall back to task lists where the sub-issues API isn't available;
+			// it won't be for the other issues either.
+			log.Warnf("The GitHub sub-issues API isn't available; only reading task lists: %v", err)
+			break
+		} else if err != nil {
+			log.Warnf("Could not list sub-issues of GitHub #%d: %v", ghIssue.GetNumber(), err)
+			continue
+		}
+		for _, sub := range subIssues {
+			// Sub-issues may live in other repositories, which we don't sync.
+			if !strings.HasSuffix(strings.ToLower(sub.GetRepositoryURL()), repoURLSuffix) {
+				continue
+			}
+			parents[sub.GetNumber()] = ghIssue.GetNumber()
+		}
+	}
+
+	for _, ghIssue := range ghIssues {
+		for _, n := range parseTaskListReferences(ghIssue.GetBody(), user, repoName) {
+			if _, ok := parents[n]; ok || n == ghIssue.GetNumber() {
+				continue
+			}
+			parents[n] = ghIssue.GetNumber()
+		}
+	}
+
+	return parents, nil
+}
+
+// sortParentsFirst orders the GitHub issues so that each parent comes before all of
+// its children, keeping the original order otherwise.
+func sortParentsFirst(ghIssues []models.ExtendedGithubIssue, parents map[int]int) []models.ExtendedGithubIssue {
+	depth := func(number int) int {
+		d := 0
+		// Bound the walk so a cycle in task lists can't loop forever.
+		for p, ok := parents[number]; ok && d < len(parents); p, ok = parents[p] {
+			d++
+		}
+		return d
+	}
+
+	sorted := make([]models.ExtendedGithubIssue, len(ghIssues))
+	copy(sorted, ghIssues)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return depth(sorted[i].GetNumber()) < depth(sorted[j].GetNumber())
+	})
+
+	return sorted
+}
+
+// syncHierarchy reflects the parent/child relationships found by findParents in
+// JIRA, according to the `hierarchy-mapping` option. Children that are not part of
+// this run are synced first if they don't exist in JIRA yet, and recorded in the
+// run's report.
+func syncHierarchy(config cfg.Config, ghIssues []models.ExtendedGithubIssue, parents map[int]int, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client, report *RunReport) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	if len(parents) == 0 {
+		return nil
+	}
+
+	byNumber := map[int]models.ExtendedGithubIssue{}
+	for _, ghIssue := range ghIssues {
+		byNumber[ghIssue.GetNumber()] = ghIssue
+	}
+
+	var fetched []models.ExtendedGithubIssue
+	for child := range parents {
+		if _, ok := byNumber[child]; ok {
+			continue
+		}
+		ghIssue, err := issuesyncgithub.GetIssue(ghClient, config.GetTimeout(), user, repoName, child)
+		if err != nil {
+			log.Errorf("Error retrieving child issue #%d. Error: %v", child, err)
+			delete(parents, child)
+			continue
+		}
+		// Pull requests can be tracked in task lists, but are never synced.
+		if ghIssue.PullRequestLinks != nil {
+			delete(parents, child)
+			continue
+		}
+		byNumber[child] = ghIssue
+		fetched = append(fetched, ghIssue)
+	}
+
+	// Children fetched here must pass the same filters as the issues of the run.
+	_, unmatched, err := filterIssues(config, fetched, ghClient)
+	if err != nil {
+		return err
+	}
+	for _, ghIssue := range unmatched {
+		delete(parents, ghIssue.GetNumber())
+		delete(byNumber, ghIssue.GetNumber())
+	}
+
+	jIssues, err := jiraIssuesByNumber(config, byNumber, jClient)
+	if err != nil {
+		return err
+	}
+
+	for child := range parents {
+		if _, ok := jIssues[child]; ok {
+			continue
+		}
+		ghIssue := byNumber[child]
+		if err := CreateIssue(config, ghIssue, refs, ghClient, jClient); err != nil {
+			log.Errorf("Error creating issue for #%d. Error: %v", child, err)
+			report.failed(actionCreateIssue, &ghIssue, "", err)
+		} else {
+			report.succeeded(actionCreateIssue, &ghIssue)
+		}
+	}
+
+	jIssues, err = jiraIssuesByNumber(config, byNumber, jClient)
+	if err != nil {
+		return err
+	}
+
+	for child, parent := range parents {
+		jChild, ok := jIssues[child]
+		if !ok {
+			log.Debugf("GitHub #%d has no JIRA issue; not linking it to its parent", child)
+			continue
+		}
+		jParent, ok := jIssues[parent]
+		if !ok {
+			log.Debugf("GitHub #%d has no JIRA issue; not linking its children", parent)
+			continue
+		}
+		if err := linkToParent(config, jChild, jParent, jClient); err != nil {
+			log.Errorf("Error linking JIRA issue %s to parent %s. Error: %v", jChild.Key, jParent.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// jiraIssuesByNumber returns the JIRA issues of the given GitHub issues, keyed by
+// GitHub issue number. GitHub issues without a JIRA issue are left out.
+func jiraIssuesByNumber(config cfg.Config, ghIssues map[int]models.ExtendedGithubIssue, jClient issuesyncjira.Client) (map[int]jira.Issue, error) {
+	ids := make([]int64, 0, len(ghIssues))
+	numbers := map[int64]int{}
+	for number, ghIssue := range ghIssues {
+		ids = append(ids, ghIssue.GetID())
+		numbers[ghIssue.GetID()] = number
+	}
+
+	jiraIssues, err := issuesyncjira.ListIssues(jClient, config.GetTimeout(), config.GetProjectKey(), config.GetFieldID(cfg.GitHubID), ids)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[int]jira.Issue{}
+	for _, jIssue := range jiraIssues {
+		id, err := config.GetFieldMapper().GetFieldValue(&jIssue, cfg.GitHubID)
+		if err != nil {
+			continue
+		}
+		if number, ok := numbers[id.(int64)]; ok {
+			result[number] = jIssue
+		}
+	}
+
+	return result, nil
+}
+
+// linkToParent makes the JIRA issue `parent` the parent of `child`, using the Epic
+// Link field, the parent field, or an issue link depending on `hierarchy-mapping`.
+// Nothing is changed if the link already exists.
+func linkToParent(config cfg.Config, child jira.Issue, parent jira.Issue, jClient issuesyncjira.Client) error {
+	log := config.GetLogger()
+
+	switch config.GetHierarchyMapping() {
+	case cfg.HierarchyToEpicLink:
+		key := config.GetCompleteFieldKey(cfg.EpicLink)
+		if current, ok := child.Fields.Unknowns.Value(key); ok && current == parent.Key {
+			return nil
+		}
+		log.Debugf("Setting Epic Link of JIRA issue %s to %s", child.Key, parent.Key)
+		return issuesyncjira.UpdateIssueFields(jClient, config.GetTimeout(), child.Key, map[string]interface{}{
+			key: parent.Key,
+		})
+	case cfg.HierarchyToParent:
+		if child.Fields.Parent != nil && child.Fields.Parent.Key == parent.Key {
+			return nil
+		}
+		log.Debugf("Setting parent of JIRA issue %s to %s", child.Key, parent.Key)
+		return issuesyncjira.UpdateIssueFields(jClient, config.GetTimeout(), child.Key, map[string]interface{}{
+			"parent": map[string]string{"key": parent.Key},
+		})
+	case cfg.HierarchyToIssueLink:
+		linkType := config.GetHierarchyLinkType()
+		for _, l := range child.Fields.IssueLinks {
+			if strings.EqualFold(l.Type.Name, linkType) && l.OutwardIssue != nil && l.OutwardIssue.Key == parent.Key {
+				return nil
+			}
+		}
+		log.Debugf("Linking JIRA issue %s to parent %s", child.Key, parent.Key)
+		return issuesyncjira.CreateIssueLink(jClient, config.GetTimeout(), linkType, child.Key, parent.Key)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+var taskListBody = `Tracking issue for the release.
+
+- [ ] #12
+- [x] Owner/Repo#13
+* [ ] https://github.com/owner/repo/issues/14
+- [ ] other/repo#15
+- [ ] Not an issue reference #16
+Mentions #17 outside of a task list.`
+
+func TestParseTaskListReferences(t *testing.T) {
+	numbers := parseTaskListReferences(taskListBody, "owner", "repo")
+
+	expected := []int{12, 13, 14}
+	if len(numbers) != len(expected) {
+		t.Fatalf("Expected numbers = %v; Got numbers = %v", expected, numbers)
+	}
+	for i := range expected {
+		if numbers[i] != expected[i] {
+			t.Fatalf("Expected numbers = %v; Got numbers = %v", expected, numbers)
+		}
+	}
+}
+
+func TestSortParentsFirst(t *testing.T) {
+	issues := make([]models.ExtendedGithubIssue, 4)
+	for i := range issues {
+		number := i + 1
+		issues[i] = models.ExtendedGithubIssue{Issue: github.Issue{Number: &number}}
+	}
+
+	// 1 is a child of 2, which is a child of 4; 3 has no parent.
+	parents := map[int]int{1: 2, 2: 4}
+
+	sorted := sortParentsFirst(issues, parents)
+
+	expected := []int{3, 4, 2, 1}
+	for i := range expected {
+		if sorted[i].GetNumber() != expected[i] {
+			t.Fatalf("Expected sorted[%d] = #%d; Got sorted[%d] = #%d", i, expected[i], i, sorted[i].GetNumber())
+		}
+	}
+}
+
+func TestFindParentsWithoutSubIssuesAPI(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":         "error",
+		"repo-name":         "owner/repo",
+		"hierarchy-mapping": cfg.HierarchyToIssueLink,
+	})
+
+	calls := 0
+	client := issuesyncgithub.NewTestClient()
+	client.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	client.HandleListSubIssues = func(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Issue, *github.Response, error) {
+		calls++
+		res := &http.Response{StatusCode: http.StatusNotFound, Request: &http.Request{Method: "GET", URL: &url.URL{}}}
+		return nil, &github.Response{Response: res}, &github.ErrorResponse{Response: res, Message: "Not Found"}
+	}
+
+	issues := make([]models.ExtendedGithubIssue, 3)
+	for i := range issues {
+		issues[i] = models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(i + 1)}}
+	}
+	issues[0].Body = github.String("- [ ] #2")
+
+	parents, err := findParents(config, issues, client)
+	if err != nil {
+		t.Fatalf("findParents failed with error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the sub-issues API to be called once after a 404; Got %d calls", calls)
+	}
+	if len(parents) != 1 || parents[2] != 1 {
+		t.Errorf("Expected #2 to be a child of #1 from its task list; Got parents = %v", parents)
+	}
+}
+
+func TestSyncHierarchyReportsChildFailures(t *testing.T) {
+	config := newTestFieldConfig(t, map[string]interface{}{
+		"timeout":           "1ms",
+		"hierarchy-mapping": cfg.HierarchyToIssueLink,
+	})
+
+	jClient := issuesyncjira.NewTestClient()
+	jClient.HandleGetLogger = config.GetLogger
+	jClient.HandleGetFieldMapper = config.GetFieldMapper
+	jClient.HandleSearchIssues = func(jql string) (interface{}, *jira.Response, error) {
+		return []jira.Issue{}, &jira.Response{}, nil
+	}
+	jClient.HandleCreateIssue = func(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
+		res := &http.Response{StatusCode: http.StatusBadRequest, Body: ioutil.NopCloser(strings.NewReader(`{"errorMessages": ["field not on screen"]}`))}
+		return nil, &jira.Response{Response: res}, errors.New("400 Bad Request")
+	}
+
+	ghClient := issuesyncgithub.NewTestClient()
+	ghClient.HandleGetLogger = config.GetLogger
+	ghClient.HandleGetRepository = func(ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error) {
+		return &github.Repository{}, &github.Response{}, nil
+	}
+	ghClient.HandleGetIssue = func(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error) {
+		return &github.Issue{
+			ID:     github.Int64(1002),
+			Number: github.Int(number),
+			Title:  github.String("Child"),
+			State:  github.String("open"),
+			User:   &github.User{Login: github.String("octocat")},
+		}, &github.Response{}, nil
+	}
+
+	// #2 is a child of #1, but isn't part of the run, so it's created first.
+	parent := models.ExtendedGithubIssue{Issue: github.Issue{ID: github.Int64(1001), Number: github.Int(1)}}
+	report := newRunReport("run", "owner/repo")
+	err := syncHierarchy(config, []models.ExtendedGithubIssue{parent}, map[int]int{2: 1}, newTestReferenceResolver(""), ghClient, jClient, &report)
+	if err != nil {
+		t.Fatalf("syncHierarchy failed with error: %v", err)
+	}
+
+	if len(report.Failures) != 1 || report.Failures[0].GitHubNumber != 2 || report.Failures[0].Action != actionCreateIssue {
+		t.Errorf("Expected creating #2 to be recorded as a failure; Got failures = %+v", report.Failures)
+	}
+}
@@ -1,23 +1,74 @@
 package lib
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
 	"github.com/indeedeng/issue-sync/cfg"
 	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
 	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
 	"github.com/indeedeng/issue-sync/lib/models"
 	"github.com/indeedeng/issue-sync/lib/utils"
 	"strings"
+	"time"
+)
+
+// Actions of a run which are retried if they fail, as logged in the `action`
+// field and counted in run reports.
+const (
+	actionCreateIssue = "create_issue"
+	actionUpdateIssue = "update_issue"
+	actionRetryIssue  = "retry_issue"
 )
 
 // CompareIssues gets the list of GitHub issues updated since the `since` date,
 // gets the list of JIRA issues which have GitHub ID custom fields in that list,
 // then matches each one. If a JIRA issue already exists for a given GitHub issue,
 // it calls UpdateIssue; if no JIRA issue already exists, it calls CreateIssue.
-func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClient issuesyncjira.Client) error {
-	log := config.GetLogger()
+// The report of the run counts what was done, and lists every issue which failed
+// to sync; an error is returned only if the run stopped early.
+func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClient issuesyncjira.Client) (RunReport, error) {
 	user, repoName := config.GetRepo()
+	report := newRunReport(newRunID(), user+"/"+repoName)
+
+	queue, err := LoadRetryQueue(config.GetRetryQueueFile())
+	if err != nil {
+		report.finish(err)
+		return report, err
+	}
+
+	err = compareIssues(config, ghClient, jiraClient, queue, &report)
+	report.finish(err)
+
+	// Nothing is synced in a dry run, so nothing is retried.
+	if !config.IsDryRun() {
+		updateRetryQueue(config, queue, report)
+		queued := true
+		if err := queue.Save(); err != nil {
+			log := config.GetLogger()
+			log.Errorf("Error saving the retry queue. Error: %v", err)
+			queued = false
+		}
+		if since := nextSince(config, report, queued); !since.IsZero() {
+			report.Since = &since
+		}
+	}
+
+	return report, err
+}
+
+func compareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClient issuesyncjira.Client, queue *RetryQueue, report *RunReport) error {
+	user, repoName := config.GetRepo()
+	config = config.WithLogFields(logrus.Fields{
+		"run_id": report.RunID,
+		"repo":   report.Repo,
+	})
+	log := config.GetLogger()
+	ghClient = issuesyncgithub.WithLogger(ghClient, log)
+	jiraClient = issuesyncjira.WithLogger(jiraClient, log)
 
 	log.Debug("Collecting issues")
 
@@ -25,6 +76,20 @@ func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClien
 	if err != nil {
 		return err
 	}
+	report.listed = ghIssues
+
+	ghIssues = withRetries(config, ghIssues, queue, ghClient, report)
+
+	ghIssues, unmatched, err := filterIssues(config, ghIssues, ghClient)
+	if err != nil {
+		return err
+	}
+	removeUnmatchedRetries(config, queue, unmatched)
+
+	if err := handleUnmatchedIssues(config, unmatched, jiraClient, report); err != nil {
+		log.Errorf("Error handling issues which no longer match the filters. Error: %v", err)
+		report.failed("handle_unmatched", nil, "", err)
+	}
 
 	if len(ghIssues) == 0 {
 		log.Info("There are no GitHub issues; exiting")
@@ -36,6 +101,12 @@ func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClien
 		ids[i] = v.GetID()
 	}
 
+	parents, err := findParents(config, ghIssues, ghClient)
+	if err != nil {
+		return err
+	}
+	ghIssues = sortParentsFirst(ghIssues, parents)
+
 	jiraIssues, err := issuesyncjira.ListIssues(jiraClient, config.GetTimeout(), config.GetProjectKey(), config.GetFieldID(cfg.GitHubID), ids)
 	if err != nil {
 		return err
@@ -43,6 +114,20 @@ func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClien
 
 	log.Debug("Collected all JIRA issues")
 
+	// References to the issues of the run are resolved without looking them up.
+	refs := newReferenceResolver(config, ghClient, jiraClient)
+	numbers := map[int64]int{}
+	for _, ghIssue := range ghIssues {
+		numbers[ghIssue.GetID()] = ghIssue.GetNumber()
+	}
+	for _, jIssue := range jiraIssues {
+		if id, err := config.GetFieldMapper().GetFieldValue(&jIssue, cfg.GitHubID); err == nil {
+			if number, ok := numbers[id.(int64)]; ok {
+				refs.add(number, jIssue.Key)
+			}
+		}
+	}
+
 	for _, ghIssue := range ghIssues {
 		found := false
 		for _, jIssue := range jiraIssues {
@@ -56,22 +141,138 @@ func CompareIssues(config cfg.Config, ghClient issuesyncgithub.Client, jiraClien
 
 			if int64(*ghIssue.ID) == id.(int64) {
 				found = true
-				if err := UpdateIssue(config, ghIssue, jIssue, ghClient, jiraClient,); err != nil {
-					log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
+				issueConfig, issueGHClient, issueJIRAClient := withIssueFields(config, ghIssue, jIssue.Key, ghClient, jiraClient)
+				start := time.Now()
+				err := UpdateIssue(issueConfig, ghIssue, jIssue, refs, issueGHClient, issueJIRAClient)
+				issueLog := actionLogger(issueConfig.WithLogFields(logrus.Fields{"duration_ms": utils.DurationMillis(time.Since(start))}), actionUpdateIssue)
+				if err != nil {
+					issueLog.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
+					report.failed(actionUpdateIssue, &ghIssue, jIssue.Key, err)
+				} else {
+					issueLog.Debug("Issue synced")
+					report.succeeded(actionUpdateIssue, &ghIssue)
 				}
 				break
 			}
 		}
 		if !found {
-			if err := CreateIssue(config, ghIssue, ghClient, jiraClient); err != nil {
-				log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
+			issueConfig, issueGHClient, issueJIRAClient := withIssueFields(config, ghIssue, "", ghClient, jiraClient)
+			start := time.Now()
+			err := CreateIssue(issueConfig, ghIssue, refs, issueGHClient, issueJIRAClient)
+			issueLog := actionLogger(issueConfig.WithLogFields(logrus.Fields{"duration_ms": utils.DurationMillis(time.Since(start))}), actionCreateIssue)
+			if err != nil {
+				issueLog.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
+				report.failed(actionCreateIssue, &ghIssue, "", err)
+			} else {
+				issueLog.Debug("Issue synced")
+				report.succeeded(actionCreateIssue, &ghIssue)
 			}
 		}
 	}
 
+	if err := syncHierarchy(config, ghIssues, parents, refs, ghClient, jiraClient, report); err != nil {
+		log.Errorf("Error syncing issue hierarchy. Error: %v", err)
+		report.failed("sync_hierarchy", nil, "", err)
+	}
+
 	return nil
 }
 
+// withRetries adds the GitHub issues of the retry queue which are due to be
+// retried to the issues to sync, unless they're already among them.
+func withRetries(config cfg.Config, ghIssues []models.ExtendedGithubIssue, queue *RetryQueue, ghClient issuesyncgithub.Client, report *RunReport) []models.ExtendedGithubIssue {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	listed := map[int]bool{}
+	for _, ghIssue := range ghIssues {
+		listed[ghIssue.GetNumber()] = true
+	}
+
+	for _, number := range queue.Due(time.Now(), config.GetRetryMaxAttempts()) {
+		if listed[number] {
+			continue
+		}
+		ghIssue, err := issuesyncgithub.GetIssue(ghClient, config.GetTimeout(), user, repoName, number)
+		if err != nil {
+			log.Errorf("Error getting GitHub #%d to retry it. Error: %v", number, err)
+			report.failed(actionRetryIssue, &models.ExtendedGithubIssue{Issue: github.Issue{Number: &number}}, "", err)
+			continue
+		}
+		log.Infof("Retrying GitHub #%d, which failed to sync before", number)
+		ghIssues = append(ghIssues, ghIssue)
+	}
+
+	return ghIssues
+}
+
+// removeUnmatchedRetries removes the GitHub issues which no longer match the
+// filters from the retry queue: they aren't synced, so they would otherwise be
+// retried forever.
+func removeUnmatchedRetries(config cfg.Config, queue *RetryQueue, unmatched []models.ExtendedGithubIssue) {
+	log := config.GetLogger()
+	for _, ghIssue := range unmatched {
+		if queue.Remove(ghIssue.GetNumber()) {
+			log.Infof("GitHub #%d no longer matches the filters; removing it from the retry queue", ghIssue.GetNumber())
+		}
+	}
+}
+
+// updateRetryQueue queues the issues which failed to sync in a run, and removes
+// those which synced.
+func updateRetryQueue(config cfg.Config, queue *RetryQueue, report RunReport) {
+	now := time.Now()
+	for _, number := range report.Synced {
+		queue.Remove(number)
+	}
+	for _, f := range report.Failures {
+		if isRetried(f) {
+			queue.Fail(f, now, config.GetRetryBackoff(), config.GetRetryMaxBackoff())
+		}
+	}
+}
+
+// isRetried returns whether a failure of a run is added to the retry queue.
+func isRetried(f RunFailure) bool {
+	switch f.Action {
+	case actionCreateIssue, actionUpdateIssue, actionRetryIssue:
+		return f.GitHubNumber != 0
+	}
+	return false
+}
+
+// newRunID returns a random ID for a sync run, logged in the `run_id` field of
+// each of its messages.
+func newRunID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// withIssueFields returns a configuration and clients which log the fields of a
+// GitHub issue and of its JIRA issue, if it has one yet.
+func withIssueFields(config cfg.Config, ghIssue models.ExtendedGithubIssue, jiraKey string, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) (cfg.Config, issuesyncgithub.Client, issuesyncjira.Client) {
+	fields := logrus.Fields{
+		"gh_number": ghIssue.GetNumber(),
+		"gh_id":     ghIssue.GetID(),
+	}
+	if jiraKey != "" {
+		fields["jira_key"] = jiraKey
+	}
+	config = config.WithLogFields(fields)
+	log := config.GetLogger()
+	return config, issuesyncgithub.WithLogger(ghClient, log), issuesyncjira.WithLogger(jClient, log)
+}
+
+// actionLogger returns the logger of a configuration, with the action being
+// performed.
+func actionLogger(config cfg.Config, action string) logrus.Entry {
+	log := config.GetLogger()
+	return *log.WithField("action", action)
+}
+
 func jiraCustomFieldsNeedUpdate(config cfg.Config, jIssue jira.Issue, fieldKey cfg.FieldKey, githubFieldValue string) bool {
 	jiraField, err := config.GetFieldMapper().GetFieldValue(&jIssue, fieldKey)
 
@@ -89,9 +290,22 @@ func jiraCustomFieldsNeedUpdate(config cfg.Config, jIssue jira.Issue, fieldKey c
 	}
 }
 
+// normalizeText returns text without carriage returns or trailing blank lines.
+// JIRA Cloud drops both, the latter as trailing empty paragraphs, from the
+// descriptions and comments it stores.
+func normalizeText(text string) string {
+	return strings.TrimRight(strings.Replace(text, "\r", "", -1), "\n")
+}
+
+// sameText returns whether two descriptions or comments are the same once
+// normalized, so that what JIRA dropped from them doesn't count as a change.
+func sameText(a string, b string) bool {
+	return normalizeText(a) == normalizeText(b)
+}
+
 // DidIssueChange tests each of the relevant fields on the provided JIRA and GitHub issue
 // and returns whether or not they differ.
-func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue) bool {
+func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, jClient issuesyncjira.Client) bool {
 	log := config.GetLogger()
 
 	log.Debugf("Comparing GitHub issue #%d and JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
@@ -99,7 +313,7 @@ func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssu
 	anyDifferent := false
 
 	anyDifferent = anyDifferent || (ghIssue.GetTitle() != jIssue.Fields.Summary)
-	anyDifferent = anyDifferent || (ghIssue.GetBody() != jIssue.Fields.Description)
+	anyDifferent = anyDifferent || !sameText(ghIssue.GetBody(), jIssue.Fields.Description)
 	anyDifferent = anyDifferent || jiraCustomFieldsNeedUpdate(config, jIssue, cfg.GitHubStatus, ghIssue.GetState())
 	anyDifferent = anyDifferent || jiraCustomFieldsNeedUpdate(config, jIssue, cfg.GitHubReporter, ghIssue.User.GetLogin())
 	commits, err := config.GetFieldMapper().GetFieldValue(&jIssue, cfg.GitHubCommits)
@@ -109,13 +323,16 @@ func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssu
 		return true
 	}
 
-	commitIdsInJiraUntyped := commits.([]interface{})
-	commitIdsInJira := make([]string, len(commitIdsInJiraUntyped))
-	for i, v := range commitIdsInJiraUntyped {
-		commitIdsInJira[i] = fmt.Sprint(v)
-	}
+	// A nil value means the field mapper doesn't track commits.
+	if commits != nil {
+		commitIdsInJiraUntyped := commits.([]interface{})
+		commitIdsInJira := make([]string, len(commitIdsInJiraUntyped))
+		for i, v := range commitIdsInJiraUntyped {
+			commitIdsInJira[i] = fmt.Sprint(v)
+		}
 
-	anyDifferent = anyDifferent || !utils.SliceStringsEq(commitIdsInJira, ghIssue.CommitIds)
+		anyDifferent = anyDifferent || !utils.SliceStringsEq(commitIdsInJira, ghIssue.CommitIds)
+	}
 	ghLabels := make([]string, len(ghIssue.Labels))
 	for i, l := range ghIssue.Labels {
 		ghLabels[i] = *l.Name
@@ -124,6 +341,14 @@ func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssu
 
 	anyDifferent = anyDifferent || jiraCustomFieldsNeedUpdate(config, jIssue, cfg.GitHubLabels, ghLabelsString)
 	anyDifferent = anyDifferent || (ghIssue.ProjectCard != nil && strings.ToLower(jIssue.Fields.Status.Name) != strings.ToLower(ghIssue.ProjectCard.GetColumnName()))
+	if !anyDifferent {
+		changed, err := milestoneChanged(config, ghIssue, jIssue, jClient)
+		if err != nil {
+			log.Error(err)
+			return true
+		}
+		anyDifferent = changed
+	}
 	log.Debugf("Issues have any differences: %t", anyDifferent)
 
 	return anyDifferent
@@ -131,19 +356,49 @@ func DidIssueChange(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssu
 
 // UpdateIssue compares each field of a GitHub issue to a JIRA issue; if any of them
 // differ, the differing fields of the JIRA issue are updated to match the GitHub
-// issue.
-func UpdateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
-	log := config.GetLogger()
+// issue. References to other issues are rewritten by the run's resolver.
+func UpdateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
+	_, err := updateIssue(config, ghIssue, jIssue, refs, ghClient, jClient)
+	return err
+}
+
+// updateIssue is UpdateIssue, also returning the fields sent to JIRA, or nil if
+// the JIRA issue was already up to date.
+func updateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) (*jira.IssueFields, error) {
+	log := actionLogger(config, actionUpdateIssue)
 
 	log.Debugf("Updating JIRA %s with GitHub #%d", jIssue.Key, *ghIssue.Number)
 
+	stored, found, err := loadSyncRecord(config, jIssue, jClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := reverseSyncFields(config, &ghIssue, jIssue, stored, ghClient); err != nil {
+		return nil, err
+	}
+
+	ghIssue = withRewrittenBody(ghIssue, refs)
+
+	if err := loadDevelopment(config, &ghIssue, ghClient); err != nil {
+		return nil, err
+	}
+	ghIssue = withDevelopmentSection(config, ghIssue)
+
+	resolution := resolveConflicts(config, &ghIssue, jIssue, stored, found)
+
 	var issue jira.Issue
+	var updated *jira.IssueFields
 
-	if DidIssueChange(config, ghIssue, jIssue) {
+	if DidIssueChange(config, ghIssue, jIssue, jClient) {
 		fields, err := config.GetFieldMapper().MapFields(&ghIssue)
 
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if err := applyMilestone(config, ghIssue, &fields, jClient); err != nil {
+			return nil, err
 		}
 
 		issue = jira.Issue{
@@ -155,46 +410,79 @@ func UpdateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue j
 		if ghIssue.ProjectCard != nil {
 			err = issuesyncjira.TryApplyTransitionWithStatusName(jClient, jIssue, ghIssue.ProjectCard.GetColumnName())
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
 		issue, err = issuesyncjira.UpdateIssue(jClient, config.GetTimeout(), issue)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		updated = &fields
 
 		log.Debugf("Successfully updated JIRA issue %s!", jIssue.Key)
 	} else {
 		log.Debugf("JIRA issue %s is already up to date!", jIssue.Key)
 	}
 
-	issue, err := issuesyncjira.GetIssue(jClient, config.GetTimeout(), jIssue.Key)
+	if err := syncMilestoneSprint(config, ghIssue, jIssue, jClient); err != nil {
+		return nil, err
+	}
+
+	issue, err = issuesyncjira.GetIssue(jClient, config.GetTimeout(), jIssue.Key)
 	if err != nil {
 		log.Debugf("Failed to retrieve JIRA issue %s!", jIssue.Key)
-		return err
+		return nil, err
 	}
 
-	if err := CompareComments(config, ghIssue.Issue, issue, ghClient, jClient); err != nil {
-		return err
+	resolution.recordStatus(config, ghIssue, issue)
+	if err := applyConflictResolution(config, resolution, issue, jClient); err != nil {
+		return nil, err
 	}
 
-	return nil
+	if err := syncReferenceLinks(config, ghIssue, issue, refs, ghClient, jClient); err != nil {
+		return nil, err
+	}
+
+	if err := syncRemoteLinks(config, ghIssue, issue, jClient); err != nil {
+		return nil, err
+	}
+
+	if err := CompareComments(config, ghIssue.Issue, issue, refs, ghClient, jClient); err != nil {
+		return nil, err
+	}
+
+	if err := reverseSyncComments(config, ghIssue.Issue, issue, ghClient); err != nil {
+		return nil, err
+	}
+
+	return updated, nil
 }
 
 // CreateIssue generates a JIRA issue from the various fields on the given GitHub issue, then
-// sends it to the JIRA API.
-func CreateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
-	log := config.GetLogger()
+// sends it to the JIRA API. The new issue is added to the run's resolver.
+func CreateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
+	log := actionLogger(config, actionCreateIssue)
 
 	log.Debugf("Creating JIRA issue based on GitHub issue #%d", *ghIssue.Issue.Number)
 
+	ghIssue = withRewrittenBody(ghIssue, refs)
+
+	if err := loadDevelopment(config, &ghIssue, ghClient); err != nil {
+		return err
+	}
+	ghIssue = withDevelopmentSection(config, ghIssue)
+
 	fields, err := config.GetFieldMapper().MapFields(&ghIssue)
 
 	if err != nil {
 		return err
 	}
 
+	if err := applyMilestone(config, ghIssue, &fields, jClient); err != nil {
+		return err
+	}
+
 	jIssue := jira.Issue{
 		Fields: &fields,
 	}
@@ -204,6 +492,13 @@ func CreateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, ghClient
 		return err
 	}
 
+	refs.add(ghIssue.GetNumber(), jIssue.Key)
+
+	config = config.WithLogFields(logrus.Fields{"jira_key": jIssue.Key})
+	log = actionLogger(config, actionCreateIssue)
+	ghClient = issuesyncgithub.WithLogger(ghClient, config.GetLogger())
+	jClient = issuesyncjira.WithLogger(jClient, config.GetLogger())
+
 	if ghIssue.ProjectCard != nil {
 		err = issuesyncjira.TryApplyTransitionWithStatusName(jClient, jIssue, ghIssue.ProjectCard.GetColumnName())
 		if err != nil {
@@ -211,6 +506,10 @@ func CreateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, ghClient
 		}
 	}
 
+	if err := syncMilestoneSprint(config, ghIssue, jIssue, jClient); err != nil {
+		return err
+	}
+
 	jIssue, err = issuesyncjira.GetIssue(jClient, config.GetTimeout(), jIssue.Key)
 	if err != nil {
 		return err
@@ -218,7 +517,21 @@ func CreateIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, ghClient
 
 	log.Debugf("Created JIRA issue %s!", jIssue.Key)
 
-	if err := CompareComments(config, ghIssue.Issue, jIssue, ghClient, jClient); err != nil {
+	resolution := newConflictResolution(config, ghIssue)
+	resolution.recordStatus(config, ghIssue, jIssue)
+	if err := applyConflictResolution(config, resolution, jIssue, jClient); err != nil {
+		return err
+	}
+
+	if err := syncReferenceLinks(config, ghIssue, jIssue, refs, ghClient, jClient); err != nil {
+		return err
+	}
+
+	if err := syncRemoteLinks(config, ghIssue, jIssue, jClient); err != nil {
+		return err
+	}
+
+	if err := CompareComments(config, ghIssue.Issue, jIssue, refs, ghClient, jClient); err != nil {
 		return err
 	}
 
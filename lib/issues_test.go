@@ -0,0 +1,14 @@
+package lib
+
+import "testing"
+
+func TestSameText(t *testing.T) {
+	// JIRA Cloud drops carriage returns, and trailing blank lines as empty
+	// paragraphs.
+	if !sameText("Steps:\r\n1. Start it\r\n\r\n", "Steps:\n1. Start it") {
+		t.Error("Expected text to be the same as what JIRA Cloud stores of it")
+	}
+	if sameText("Steps:\n\n1. Start it", "Steps:\n1. Start it") {
+		t.Error("Expected a blank line within text to count as a change")
+	}
+}
@@ -0,0 +1,164 @@
+package issuesyncgithub
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"golang.org/x/oauth2"
+)
+
+// appJWTLifetime is how long the JWTs authenticating as the GitHub App are valid;
+// GitHub accepts at most ten minutes.
+const appJWTLifetime = 9 * time.Minute
+
+// newAppTokenSource creates a token source of installation access tokens of the
+// configured GitHub App, which are refreshed before they expire. If no
+// installation ID is configured, the installation on the repository is used.
+func newAppTokenSource(config cfg.Config, base http.RoundTripper) (oauth2.TokenSource, error) {
+	key, err := readAppPrivateKey(config.GetConfigString("github-app-private-key-path"))
+	if err != nil {
+		return nil, err
+	}
+
+	transport := appTransport{appID: config.GetGitHubAppID(), key: key, base: base}
+	appClient, err := newGitHubClient(config, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.GetTimeout())
+	defer cancel()
+
+	installationID := config.GetGitHubAppInstallationID()
+	if installationID == 0 {
+		owner, repo := config.GetRepo()
+		installation, _, err := appClient.Apps.FindRepositoryInstallation(ctx, owner, repo)
+		if err != nil {
+			return nil, fmt.Errorf("unable to find the installation of the GitHub App on %s/%s: %v", owner, repo, err)
+		}
+		installationID = installation.GetID()
+		log := config.GetLogger()
+		log.Debugf("Using installation %d of the GitHub App", installationID)
+	}
+
+	source := installationTokenSource{
+		client:         appClient,
+		installationID: installationID,
+		timeout:        config.GetTimeout(),
+	}
+	return oauth2.ReuseTokenSource(nil, source), nil
+}
+
+// installationTokenSource creates installation access tokens of a GitHub App.
+type installationTokenSource struct {
+	client         *github.Client
+	installationID int64
+	timeout        time.Duration
+}
+
+func (s installationTokenSource) Token() (*oauth2.Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	tok, _, err := s.client.Apps.CreateInstallationToken(ctx, s.installationID, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create an access token for installation %d of the GitHub App: %v", s.installationID, err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: tok.GetToken(),
+		TokenType:   "token",
+		Expiry:      tok.GetExpiresAt(),
+	}, nil
+}
+
+// appTransport authenticates requests as a GitHub App, with a JWT signed by its
+// private key.
+type appTransport struct {
+	appID int64
+	key   *rsa.PrivateKey
+	base  http.RoundTripper
+}
+
+func (t appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	jwt, err := appJWT(t.appID, t.key, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the request they're given.
+	authenticated := req.WithContext(req.Context())
+	authenticated.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		authenticated.Header[k] = v
+	}
+	authenticated.Header.Set("Authorization", "Bearer "+jwt)
+
+	return t.base.RoundTrip(authenticated)
+}
+
+// appJWT creates the RS256-signed JWT GitHub Apps authenticate with. It's issued
+// a minute in the past to allow for clock drift.
+func appJWT(appID int64, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// readAppPrivateKey reads the PEM-encoded RSA private key of a GitHub App.
+func readAppPrivateKey(path string) (*rsa.PrivateKey, error) {
+	pemKey, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read GitHub App private key: %v", err)
+	}
+
+	block, _ := pem.Decode(pemKey)
+	if block == nil {
+		return nil, errors.New("unable to decode GitHub App private key PEM block")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key must be an RSA key")
+	}
+	return key, nil
+}
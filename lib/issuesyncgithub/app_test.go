@@ -0,0 +1,140 @@
+package issuesyncgithub
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppJWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	jwt, err := appJWT(42, key, now)
+	if err != nil {
+		t.Fatalf("appJWT failed with error: %v", err)
+	}
+
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("Expected a JWT of 3 parts; got %q", jwt)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], signature); err != nil {
+		t.Errorf("Expected the JWT to be signed with the key: %v", err)
+	}
+
+	var header map[string]string
+	decodeJWTPart(t, parts[0], &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("Unexpected header %v", header)
+	}
+
+	var claims map[string]int64
+	decodeJWTPart(t, parts[1], &claims)
+	expected := map[string]int64{
+		"iss": 42,
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+	}
+	if len(claims) != len(expected) {
+		t.Errorf("Expected the claims %v; got %v", expected, claims)
+	}
+	for claim, value := range expected {
+		if claims[claim] != value {
+			t.Errorf("Expected claim %s to be %d; got %d", claim, value, claims[claim])
+		}
+	}
+}
+
+// decodeJWTPart decodes the base64 JSON of the header or claims of a JWT.
+func decodeJWTPart(t *testing.T, part string, out interface{}) {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadAppPrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeKey := func(name string, block *pem.Block) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for _, path := range []string{
+		writeKey("pkcs1.pem", &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}),
+		writeKey("pkcs8.pem", &pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+	} {
+		key, err := readAppPrivateKey(path)
+		if err != nil {
+			t.Errorf("Reading %s failed with error: %v", filepath.Base(path), err)
+		} else if key.N.Cmp(rsaKey.N) != 0 {
+			t.Errorf("Expected %s to hold the generated key", filepath.Base(path))
+		}
+	}
+
+	ecPath := writeKey("ec.pem", &pem.Block{Type: "PRIVATE KEY", Bytes: ecPKCS8})
+	if _, err := readAppPrivateKey(ecPath); err == nil || !strings.Contains(err.Error(), "RSA") {
+		t.Errorf("Expected reading an EC key to fail as it isn't an RSA key; got %v", err)
+	}
+
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{notPEM, filepath.Join(dir, "missing.pem")} {
+		if _, err := readAppPrivateKey(path); err == nil {
+			t.Errorf("Expected reading %s to fail", filepath.Base(path))
+		}
+	}
+}
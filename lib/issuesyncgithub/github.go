@@ -6,6 +6,10 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/indeedeng/issue-sync/lib/models"
 	"github.com/indeedeng/issue-sync/lib/utils"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/indeedeng/issue-sync/cfg"
@@ -24,6 +28,41 @@ type Client interface {
 	listComments(ctx context.Context, owner string, repo string, number int) ([]*github.IssueComment, *github.Response, error)
 	getUser(ctx context.Context, user string) (*github.User, *github.Response, error)
 	getRateLimits(ctx context.Context) (*github.RateLimits, *github.Response, error)
+	getIssue(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error)
+	listSubIssues(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Issue, *github.Response, error)
+	listIssueTimeline(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Timeline, *github.Response, error)
+	getPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
+	getCommit(ctx context.Context, owner string, repo string, sha string) (*github.RepositoryCommit, *github.Response, error)
+	listMilestones(ctx context.Context, owner string, repo string, page int) ([]*github.Milestone, *github.Response, error)
+	editIssue(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error)
+	createComment(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error)
+	editComment(ctx context.Context, owner string, repo string, id int64, body string) (*github.IssueComment, *github.Response, error)
+	searchIssues(ctx context.Context, query string, page int) (*github.IssuesSearchResult, *github.Response, error)
+}
+
+// loggingClient is a Client which logs with another logger, such as one with
+// the fields of the issue being synced.
+type loggingClient struct {
+	Client
+	log logrus.Entry
+}
+
+func (g loggingClient) getLogger() logrus.Entry {
+	return g.log
+}
+
+// WithLogger returns a client which logs the API calls it makes with a logger,
+// e.g. one with the fields of the issue being synced.
+func WithLogger(g Client, log logrus.Entry) Client {
+	if l, ok := g.(loggingClient); ok {
+		g = l.Client
+	}
+	return loggingClient{Client: g, log: log}
+}
+
+// withAction returns a logger for the API calls of an action.
+func withAction(log logrus.Entry, action string) logrus.Entry {
+	return *log.WithFields(logrus.Fields{"backend": "github", "action": action})
 }
 
 // realGHClient is a standard GitHub clients, that actually makes all of the
@@ -77,44 +116,219 @@ func (g realGHClient) getRateLimits(ctx context.Context) (*github.RateLimits, *g
 	return g.client.RateLimits(ctx)
 }
 
+func (g realGHClient) getIssue(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error) {
+	return g.client.Issues.Get(ctx, owner, repo, number)
+}
+
+func (g realGHClient) listSubIssues(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Issue, *github.Response, error) {
+	// The version of the GitHub library we use predates the sub-issues API, so we
+	// build the request ourselves.
+	u := fmt.Sprintf("repos/%v/%v/issues/%d/sub_issues?page=%d&per_page=100", owner, repo, number, page)
+	req, err := g.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var issues []*github.Issue
+	res, err := g.client.Do(ctx, req, &issues)
+	if err != nil {
+		return nil, res, err
+	}
+	return issues, res, nil
+}
+
+func (g realGHClient) listIssueTimeline(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Timeline, *github.Response, error) {
+	return g.client.Issues.ListIssueTimeline(ctx, owner, repo, number, &github.ListOptions{
+		Page:    page,
+		PerPage: 100,
+	})
+}
+
+func (g realGHClient) getPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	return g.client.PullRequests.Get(ctx, owner, repo, number)
+}
+
+func (g realGHClient) getCommit(ctx context.Context, owner string, repo string, sha string) (*github.RepositoryCommit, *github.Response, error) {
+	return g.client.Repositories.GetCommit(ctx, owner, repo, sha)
+}
+
+func (g realGHClient) listMilestones(ctx context.Context, owner string, repo string, page int) ([]*github.Milestone, *github.Response, error) {
+	return g.client.Issues.ListMilestones(ctx, owner, repo, &github.MilestoneListOptions{
+		State: "all",
+		ListOptions: github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		},
+	})
+}
+
+func (g realGHClient) editIssue(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error) {
+	// github.IssueRequest can neither set the state reason nor clear the
+	// milestone, so we build the request ourselves.
+	u := fmt.Sprintf("repos/%v/%v/issues/%d", owner, repo, number)
+	req, err := g.client.NewRequest("PATCH", u, fields)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issue := new(github.Issue)
+	res, err := g.client.Do(ctx, req, issue)
+	if err != nil {
+		return nil, res, err
+	}
+	return issue, res, nil
+}
+
+func (g realGHClient) createComment(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error) {
+	return g.client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: &body})
+}
+
+func (g realGHClient) editComment(ctx context.Context, owner string, repo string, id int64, body string) (*github.IssueComment, *github.Response, error) {
+	return g.client.Issues.EditComment(ctx, owner, repo, id, &github.IssueComment{Body: &body})
+}
+
+func (g realGHClient) searchIssues(ctx context.Context, query string, page int) (*github.IssuesSearchResult, *github.Response, error) {
+	return g.client.Search.Issues(ctx, query, &github.SearchOptions{
+		ListOptions: github.ListOptions{
+			Page:    page,
+			PerPage: 100,
+		},
+	})
+}
+
+// dryrunGHClient is an implementation of Client which performs all
+// GET requests the same as the realGHClient, but does not perform any
+// unsafe requests which may modify server data, instead printing out the
+// actions it is asked to perform without making the request.
+type dryrunGHClient struct {
+	realGHClient
+}
+
+func (g dryrunGHClient) editIssue(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error) {
+	log := g.log
+
+	log.Info("")
+	log.Infof("Update GitHub issue #%d:", number)
+	for k, v := range fields {
+		log.Infof("  %s: %v", k, v)
+	}
+	log.Info("")
+
+	return &github.Issue{Number: &number}, nil, nil
+}
+
+func (g dryrunGHClient) createComment(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error) {
+	log := g.log
+
+	log.Info("")
+	log.Infof("Create comment on GitHub issue #%d:", number)
+	log.Infof("  Body: %s", truncate(body, 100))
+	log.Info("")
+
+	return &github.IssueComment{Body: &body}, nil, nil
+}
+
+func (g dryrunGHClient) editComment(ctx context.Context, owner string, repo string, id int64, body string) (*github.IssueComment, *github.Response, error) {
+	log := g.log
+
+	log.Info("")
+	log.Infof("Update GitHub comment %d:", id)
+	log.Infof("  Body: %s", truncate(body, 100))
+	log.Info("")
+
+	return &github.IssueComment{ID: &id, Body: &body}, nil, nil
+}
+
+// TestGHClient is a Client for tests, whose calls are made to its Handle
+// functions.
 type TestGHClient struct {
-	handleGetLogger func() logrus.Entry
-	handleListIssueEvents func(ctx context.Context, owner, repo string, number int, page int) ([]*github.IssueEvent, *github.Response, error)
-	handleListByRepo func(ctx context.Context, owner string, repo string, page int, since time.Time) ([]*github.Issue, *github.Response, error)
-	handleGetRepository func(ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error)
-	handleListComments func(ctx context.Context, owner string, repo string, number int) ([]*github.IssueComment, *github.Response, error)
-	handleGetUser func(ctx context.Context, user string) (*github.User, *github.Response, error)
-	handleGetRateLimits func(ctx context.Context) (*github.RateLimits, *github.Response, error)
+	HandleGetLogger func() logrus.Entry
+	HandleListIssueEvents func(ctx context.Context, owner, repo string, number int, page int) ([]*github.IssueEvent, *github.Response, error)
+	HandleListByRepo func(ctx context.Context, owner string, repo string, page int, since time.Time) ([]*github.Issue, *github.Response, error)
+	HandleGetRepository func(ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error)
+	HandleListComments func(ctx context.Context, owner string, repo string, number int) ([]*github.IssueComment, *github.Response, error)
+	HandleGetUser func(ctx context.Context, user string) (*github.User, *github.Response, error)
+	HandleGetRateLimits func(ctx context.Context) (*github.RateLimits, *github.Response, error)
+	HandleGetIssue func(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error)
+	HandleListSubIssues func(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Issue, *github.Response, error)
+	HandleListIssueTimeline func(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Timeline, *github.Response, error)
+	HandleGetPullRequest func(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error)
+	HandleGetCommit func(ctx context.Context, owner string, repo string, sha string) (*github.RepositoryCommit, *github.Response, error)
+	HandleListMilestones func(ctx context.Context, owner string, repo string, page int) ([]*github.Milestone, *github.Response, error)
+	HandleEditIssue func(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error)
+	HandleCreateComment func(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error)
+	HandleEditComment func(ctx context.Context, owner string, repo string, id int64, body string) (*github.IssueComment, *github.Response, error)
+	HandleSearchIssues func(ctx context.Context, query string, page int) (*github.IssuesSearchResult, *github.Response, error)
 }
 
 func (g TestGHClient) getLogger() logrus.Entry {
-	return g.handleGetLogger()
+	return g.HandleGetLogger()
 }
 
 func (g TestGHClient) listIssueEvents(ctx context.Context, owner, repo string, number int, page int) ([]*github.IssueEvent, *github.Response, error) {
-	return g.handleListIssueEvents(ctx, owner, repo, number, page)
+	return g.HandleListIssueEvents(ctx, owner, repo, number, page)
 }
 
 func (g TestGHClient) getRepository(ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error) {
-	return g.handleGetRepository(ctx, owner, repo)
+	return g.HandleGetRepository(ctx, owner, repo)
 }
 
 func (g TestGHClient) listByRepo(ctx context.Context, owner string, repo string, page int, since time.Time) ([]*github.Issue, *github.Response, error) {
-	return g.handleListByRepo(ctx, owner, repo, page, since)
+	return g.HandleListByRepo(ctx, owner, repo, page, since)
 }
 
 func (g TestGHClient) listComments(ctx context.Context, owner string, repo string, number int) ([]*github.IssueComment, *github.Response, error) {
-	return g.handleListComments(ctx, owner, repo, number)
+	return g.HandleListComments(ctx, owner, repo, number)
 }
 
 func (g TestGHClient) getUser(ctx context.Context, user string) (*github.User, *github.Response, error) {
-	return g.handleGetUser(ctx, user)
+	return g.HandleGetUser(ctx, user)
 }
 
 func (g TestGHClient) getRateLimits(ctx context.Context) (*github.RateLimits, *github.Response, error) {
 	return g.getRateLimits(ctx)
 }
 
+func (g TestGHClient) getIssue(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error) {
+	return g.HandleGetIssue(ctx, owner, repo, number)
+}
+
+func (g TestGHClient) listSubIssues(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Issue, *github.Response, error) {
+	return g.HandleListSubIssues(ctx, owner, repo, number, page)
+}
+
+func (g TestGHClient) listIssueTimeline(ctx context.Context, owner string, repo string, number int, page int) ([]*github.Timeline, *github.Response, error) {
+	return g.HandleListIssueTimeline(ctx, owner, repo, number, page)
+}
+
+func (g TestGHClient) getPullRequest(ctx context.Context, owner string, repo string, number int) (*github.PullRequest, *github.Response, error) {
+	return g.HandleGetPullRequest(ctx, owner, repo, number)
+}
+
+func (g TestGHClient) getCommit(ctx context.Context, owner string, repo string, sha string) (*github.RepositoryCommit, *github.Response, error) {
+	return g.HandleGetCommit(ctx, owner, repo, sha)
+}
+
+func (g TestGHClient) listMilestones(ctx context.Context, owner string, repo string, page int) ([]*github.Milestone, *github.Response, error) {
+	return g.HandleListMilestones(ctx, owner, repo, page)
+}
+
+func (g TestGHClient) editIssue(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error) {
+	return g.HandleEditIssue(ctx, owner, repo, number, fields)
+}
+
+func (g TestGHClient) createComment(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error) {
+	return g.HandleCreateComment(ctx, owner, repo, number, body)
+}
+
+func (g TestGHClient) editComment(ctx context.Context, owner string, repo string, id int64, body string) (*github.IssueComment, *github.Response, error) {
+	return g.HandleEditComment(ctx, owner, repo, id, body)
+}
+
+func (g TestGHClient) searchIssues(ctx context.Context, query string, page int) (*github.IssuesSearchResult, *github.Response, error) {
+	return g.HandleSearchIssues(ctx, query, page)
+}
+
 func getCurrentProjectCardAndCommitIds(g Client, timeout time.Duration, user string, repoName string, issue *github.Issue) (*github.ProjectCard, []string, error) {
 	log := g.getLogger()
 	ctx := context.Background()
@@ -126,7 +340,7 @@ func getCurrentProjectCardAndCommitIds(g Client, timeout time.Duration, user str
 
 	// search for the current project card
 	for page := 1; page <= pages; page++ {
-		is, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+		is, res, err := utils.Retry(withAction(log, "get_current_project_card_and_commit_ids"), timeout, func() (interface{}, interface{}, error) {
 			return g.listIssueEvents(ctx, user, repoName, issue.GetNumber(), page)
 		})
 		if err != nil {
@@ -170,7 +384,7 @@ func ListIssues(g Client, timeout time.Duration, user string, repoName string, s
 	var issues []models.ExtendedGithubIssue
 
 	for page := 1; page <= pages; page++ {
-		is, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+		is, res, err := utils.Retry(withAction(log, "list_issues"), timeout, func() (interface{}, interface{}, error) {
 			return g.listByRepo(ctx, user, repoName, page, since)
 		})
 		if err != nil {
@@ -206,12 +420,390 @@ func ListIssues(g Client, timeout time.Duration, user string, repoName string, s
 	return issues, nil
 }
 
+// GetIssue returns a single GitHub issue by its number, along with its current
+// project card and commit IDs.
+func GetIssue(g Client, timeout time.Duration, user string, repoName string, number int) (models.ExtendedGithubIssue, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	r, _, err := utils.Retry(withAction(log, "get_repository"), timeout, func() (interface{}, interface{}, error) {
+		return g.getRepository(ctx, user, repoName)
+	})
+	if err != nil {
+		log.Errorf("error retrieving GitHub repository %s/%s. Error: %v.", user, repoName, err)
+		return models.ExtendedGithubIssue{}, err
+	}
+	repo, ok := r.(*github.Repository)
+	if !ok {
+		log.Errorf("get GitHub repository did not return repository! Got: %v", r)
+		return models.ExtendedGithubIssue{}, fmt.Errorf("get GitHub repository failed: expected *github.Repository; got %T", r)
+	}
+
+	issue, err := GetBareIssue(g, timeout, user, repoName, number)
+	if err != nil {
+		return models.ExtendedGithubIssue{}, err
+	}
+
+	var currentProjectCard *github.ProjectCard
+	var commitIds []string
+	if repo.GetHasProjects() {
+		currentProjectCard, commitIds, _ = getCurrentProjectCardAndCommitIds(g, timeout, user, repoName, &issue)
+	}
+
+	return models.ExtendedGithubIssue{Issue: issue, ProjectCard: currentProjectCard, CommitIds: commitIds}, nil
+}
+
+// GetBareIssue returns a GitHub issue without the project card and commits
+// GetIssue also retrieves. A 404 (see IsNotFound) isn't retried.
+func GetBareIssue(g Client, timeout time.Duration, user string, repoName string, number int) (github.Issue, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	i, _, err := utils.Retry(withAction(log, "get_issue"), timeout, func() (interface{}, interface{}, error) {
+		i, res, err := g.getIssue(ctx, user, repoName, number)
+		return i, res, permanentIfNotFound(err)
+	})
+	if err != nil {
+		log.Errorf("error retrieving GitHub issue #%d. Error: %v.", number, err)
+		return github.Issue{}, err
+	}
+	issue, ok := i.(*github.Issue)
+	if !ok {
+		log.Errorf("get GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("get GitHub issue failed: expected *github.Issue; got %T", i)
+	}
+
+	return *issue, nil
+}
+
+// IsNotFound returns whether an error is a 404 or 410 response from GitHub,
+// which retrying can't fix: the resource, or the API, doesn't exist.
+func IsNotFound(err error) bool {
+	e, ok := err.(*github.ErrorResponse)
+	if !ok || e.Response == nil {
+		return false
+	}
+	return e.Response.StatusCode == http.StatusNotFound || e.Response.StatusCode == http.StatusGone
+}
+
+// permanentIfNotFound marks the error of an API call as permanent for Retry if
+// it's a 404 or 410 response.
+func permanentIfNotFound(err error) error {
+	if IsNotFound(err) {
+		return utils.Permanent(err)
+	}
+	return err
+}
+
+// ListSubIssues returns the sub-issues of a GitHub issue. Where the sub-issues
+// API isn't available, such as on older GitHub Enterprise servers, the error is
+// a 404 (see IsNotFound), and isn't retried.
+func ListSubIssues(g Client, timeout time.Duration, user string, repoName string, number int) ([]*github.Issue, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var issues []*github.Issue
+
+	for page := 1; page <= pages; page++ {
+		is, res, err := utils.Retry(withAction(log, "list_sub_issues"), timeout, func() (interface{}, interface{}, error) {
+			is, res, err := g.listSubIssues(ctx, user, repoName, number, page)
+			return is, res, permanentIfNotFound(err)
+		})
+		if err != nil {
+			log.Errorf("error retrieving sub-issues of GitHub issue #%d. Error: %v.", number, err)
+			return nil, err
+		}
+		issuePointers, ok := is.([]*github.Issue)
+		if !ok {
+			log.Errorf("get GitHub sub-issues did not return issues! Got: %v", is)
+			return nil, fmt.Errorf("get GitHub sub-issues failed: expected []*github.Issue; got %T", is)
+		}
+
+		pages = res.(*github.Response).LastPage
+		issues = append(issues, issuePointers...)
+	}
+
+	return issues, nil
+}
+
+// ListTimeline returns the timeline events of a GitHub issue, such as
+// cross-references from other issues and pull requests.
+func ListTimeline(g Client, timeout time.Duration, user string, repoName string, number int) ([]*github.Timeline, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var events []*github.Timeline
+
+	for page := 1; page <= pages; page++ {
+		es, res, err := utils.Retry(withAction(log, "list_timeline"), timeout, func() (interface{}, interface{}, error) {
+			return g.listIssueTimeline(ctx, user, repoName, number, page)
+		})
+		if err != nil {
+			log.Errorf("error retrieving timeline of GitHub issue #%d. Error: %v.", number, err)
+			return nil, err
+		}
+		eventPointers, ok := es.([]*github.Timeline)
+		if !ok {
+			log.Errorf("get GitHub issue timeline did not return events! Got: %v", es)
+			return nil, fmt.Errorf("get GitHub issue timeline failed: expected []*github.Timeline; got %T", es)
+		}
+
+		pages = res.(*github.Response).LastPage
+		events = append(events, eventPointers...)
+	}
+
+	return events, nil
+}
+
+// GetPullRequest returns a single GitHub pull request by its repository and number.
+func GetPullRequest(g Client, timeout time.Duration, user string, repoName string, number int) (github.PullRequest, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	p, _, err := utils.Retry(withAction(log, "get_pull_request"), timeout, func() (interface{}, interface{}, error) {
+		return g.getPullRequest(ctx, user, repoName, number)
+	})
+	if err != nil {
+		log.Errorf("error retrieving GitHub pull request %s/%s#%d. Error: %v.", user, repoName, number, err)
+		return github.PullRequest{}, err
+	}
+	pr, ok := p.(*github.PullRequest)
+	if !ok {
+		log.Errorf("get GitHub pull request did not return pull request! Got: %v", p)
+		return github.PullRequest{}, fmt.Errorf("get GitHub pull request failed: expected *github.PullRequest; got %T", p)
+	}
+
+	return *pr, nil
+}
+
+// GetCommit returns a single GitHub commit by its repository and SHA.
+func GetCommit(g Client, timeout time.Duration, user string, repoName string, sha string) (github.RepositoryCommit, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	c, _, err := utils.Retry(withAction(log, "get_commit"), timeout, func() (interface{}, interface{}, error) {
+		return g.getCommit(ctx, user, repoName, sha)
+	})
+	if err != nil {
+		log.Errorf("error retrieving GitHub commit %s/%s@%s. Error: %v.", user, repoName, sha, err)
+		return github.RepositoryCommit{}, err
+	}
+	commit, ok := c.(*github.RepositoryCommit)
+	if !ok {
+		log.Errorf("get GitHub commit did not return commit! Got: %v", c)
+		return github.RepositoryCommit{}, fmt.Errorf("get GitHub commit failed: expected *github.RepositoryCommit; got %T", c)
+	}
+
+	return *commit, nil
+}
+
+// ListMilestones returns all of the milestones of a GitHub repository, both
+// open and closed.
+func ListMilestones(g Client, timeout time.Duration, user string, repoName string) ([]*github.Milestone, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var milestones []*github.Milestone
+
+	for page := 1; page <= pages; page++ {
+		ms, res, err := utils.Retry(withAction(log, "list_milestones"), timeout, func() (interface{}, interface{}, error) {
+			return g.listMilestones(ctx, user, repoName, page)
+		})
+		if err != nil {
+			log.Errorf("error retrieving GitHub milestones. Error: %v.", err)
+			return nil, err
+		}
+		milestonePointers, ok := ms.([]*github.Milestone)
+		if !ok {
+			log.Errorf("get GitHub milestones did not return milestones! Got: %v", ms)
+			return nil, fmt.Errorf("get GitHub milestones failed: expected []*github.Milestone; got %T", ms)
+		}
+
+		pages = res.(*github.Response).LastPage
+		milestones = append(milestones, milestonePointers...)
+	}
+
+	return milestones, nil
+}
+
+// searchMaxOperators is the most AND, OR and NOT operators GitHub allows in a
+// search query.
+const searchMaxOperators = 5
+
+// searchOperatorRegex matches the AND, OR and NOT operators of a search query.
+var searchOperatorRegex = regexp.MustCompile(`(?:^|[\s()])(?:AND|OR|NOT)(?:$|[\s()])`)
+
+// searchBatchSize returns the number of issue numbers SearchIssueNumbers
+// searches for in one query, which are joined by OR operators, so that the
+// query stays within GitHub's limit of operators along with those of the user's
+// query and the AND joining the two.
+func searchBatchSize(query string) (int, error) {
+	available := searchMaxOperators - len(searchOperatorRegex.FindAllString(query, -1))
+	if query != "" {
+		available--
+	}
+	if available < 0 {
+		return 0, fmt.Errorf("search query %q uses more than %d AND, OR and NOT operators", query, searchMaxOperators-1)
+	}
+	return available + 1, nil
+}
+
+// searchNumbersQuery returns the GitHub search query for the issues among
+// numbers which match the user's query. Both are parenthesized, so that the
+// user's query applies to every number.
+func searchNumbersQuery(user string, repoName string, query string, numbers []int) string {
+	terms := make([]string, len(numbers))
+	for i, n := range numbers {
+		terms[i] = strconv.Itoa(n)
+	}
+	q := fmt.Sprintf("repo:%s/%s is:issue ", user, repoName)
+	if query != "" {
+		q += fmt.Sprintf("(%s) AND ", query)
+	}
+	return q + fmt.Sprintf("(%s)", strings.Join(terms, " OR "))
+}
+
+// SearchIssueNumbers returns which of the given issues (not pull requests) of
+// a GitHub repository match a GitHub search query, such as
+// "label:bug -author:app/dependabot". The issues are searched for by number, in
+// batches, so that the results stay below GitHub's cap of 1000 results; as the
+// numbers are also matched as text, only the issues of the batch are kept. If
+// GitHub returns incomplete results, e.g. because it timed out, an error is
+// returned, since the missing issues would wrongly be seen as not matching.
+func SearchIssueNumbers(g Client, timeout time.Duration, user string, repoName string, query string, numbers []int) (map[int]bool, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	batchSize, err := searchBatchSize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	found := map[int]bool{}
+
+	for start := 0; start < len(numbers); start += batchSize {
+		end := start + batchSize
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		batch := map[int]bool{}
+		for _, n := range numbers[start:end] {
+			batch[n] = true
+		}
+		q := searchNumbersQuery(user, repoName, query, numbers[start:end])
+
+		// Set it so that it will run the loop once, and it'll be updated in the loop.
+		pages := 1
+		fetched := 0
+
+		for page := 1; page <= pages; page++ {
+			r, res, err := utils.Retry(withAction(log, "search_issue_numbers"), timeout, func() (interface{}, interface{}, error) {
+				return g.searchIssues(ctx, q, page)
+			})
+			if err != nil {
+				log.Errorf("error searching GitHub issues. Error: %v.", err)
+				return nil, err
+			}
+			result, ok := r.(*github.IssuesSearchResult)
+			if !ok {
+				log.Errorf("search GitHub issues did not return issues! Got: %v", r)
+				return nil, fmt.Errorf("search GitHub issues failed: expected *github.IssuesSearchResult; got %T", r)
+			}
+			if result.GetIncompleteResults() {
+				log.Errorf("GitHub search for %q returned incomplete results.", q)
+				return nil, fmt.Errorf("search GitHub issues failed: incomplete results for %q", q)
+			}
+
+			for _, i := range result.Issues {
+				if batch[i.GetNumber()] {
+					found[i.GetNumber()] = true
+				}
+			}
+			fetched += len(result.Issues)
+			pages = res.(*github.Response).LastPage
+
+			if page >= pages && result.GetTotal() > fetched {
+				log.Errorf("GitHub search for %q returned %d of %d results.", q, fetched, result.GetTotal())
+				return nil, fmt.Errorf("search GitHub issues failed: got %d of %d results for %q", fetched, result.GetTotal(), q)
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// EditIssue updates the given fields of a GitHub issue, using the names of the
+// GitHub REST API (e.g. "state", "state_reason", "labels", "milestone"). A nil
+// value clears the field.
+func EditIssue(g Client, timeout time.Duration, user string, repoName string, number int, fields map[string]interface{}) error {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	_, _, err := utils.Retry(withAction(log, "edit_issue"), timeout, func() (interface{}, interface{}, error) {
+		return g.editIssue(ctx, user, repoName, number, fields)
+	})
+	if err != nil {
+		log.Errorf("error updating GitHub issue #%d. Error: %v.", number, err)
+		return err
+	}
+
+	return nil
+}
+
+// CreateComment adds a comment with the given body to a GitHub issue.
+func CreateComment(g Client, timeout time.Duration, user string, repoName string, number int, body string) (github.IssueComment, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	c, _, err := utils.Retry(withAction(log, "create_comment"), timeout, func() (interface{}, interface{}, error) {
+		return g.createComment(ctx, user, repoName, number, body)
+	})
+	if err != nil {
+		log.Errorf("error creating comment on GitHub issue #%d. Error: %v.", number, err)
+		return github.IssueComment{}, err
+	}
+	comment, ok := c.(*github.IssueComment)
+	if !ok {
+		log.Errorf("create GitHub comment did not return comment! Got: %v", c)
+		return github.IssueComment{}, fmt.Errorf("create GitHub comment failed: expected *github.IssueComment; got %T", c)
+	}
+
+	return *comment, nil
+}
+
+// EditComment replaces the body of a GitHub comment.
+func EditComment(g Client, timeout time.Duration, user string, repoName string, id int64, body string) (github.IssueComment, error) {
+	log := g.getLogger()
+	ctx := context.Background()
+
+	c, _, err := utils.Retry(withAction(log, "edit_comment"), timeout, func() (interface{}, interface{}, error) {
+		return g.editComment(ctx, user, repoName, id, body)
+	})
+	if err != nil {
+		log.Errorf("error updating GitHub comment %d. Error: %v.", id, err)
+		return github.IssueComment{}, err
+	}
+	comment, ok := c.(*github.IssueComment)
+	if !ok {
+		log.Errorf("update GitHub comment did not return comment! Got: %v", c)
+		return github.IssueComment{}, fmt.Errorf("update GitHub comment failed: expected *github.IssueComment; got %T", c)
+	}
+
+	return *comment, nil
+}
+
 // ListComments returns the list of all comments on a GitHub issue in
 // ascending order of creation.
 func ListComments(g Client, timeout time.Duration, user string, repoName string, issue github.Issue) ([]*github.IssueComment, error) {
 	log := g.getLogger()
 	ctx := context.Background()
-	c, _, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	c, _, err := utils.Retry(withAction(log, "list_comments"), timeout, func() (interface{}, interface{}, error) {
 		return g.listComments(ctx, user, repoName, issue.GetNumber())
 	})
 	if err != nil {
@@ -229,7 +821,7 @@ func ListComments(g Client, timeout time.Duration, user string, repoName string,
 
 // GetUser returns a GitHub user from its login.
 func GetUser(g Client, log logrus.Entry, timeout time.Duration, userName string) (github.User, error) {
-	u, _, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	u, _, err := utils.Retry(withAction(log, "get_user"), timeout, func() (interface{}, interface{}, error) {
 		return g.getUser(context.Background(), userName)
 	})
 
@@ -246,6 +838,42 @@ func GetUser(g Client, log logrus.Entry, timeout time.Duration, userName string)
 	return *user, nil
 }
 
+// NewAPIClient creates a client of the GitHub API library we use, authenticated
+// either with `github-token` or as an installation of a GitHub App.
+func NewAPIClient(config cfg.Config) (*github.Client, error) {
+	transport, err := utils.NewTransport(config.GetGitHubTransportOptions())
+	if err != nil {
+		return nil, err
+	}
+	// The OAuth2 library makes its requests with the client in the context.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+
+	var ts oauth2.TokenSource
+	if config.UsesGitHubApp() {
+		ts, err = newAppTokenSource(config, transport)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		ts = oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: config.GetConfigString("github-token")},
+		)
+	}
+	tc := oauth2.NewClient(ctx, ts)
+
+	return newGitHubClient(config, tc)
+}
+
+// newGitHubClient creates a client of the GitHub API library we use, for either
+// github.com or the configured GitHub Enterprise Server.
+func newGitHubClient(config cfg.Config, httpClient *http.Client) (*github.Client, error) {
+	if config.GetGitHubBaseURL() == "" {
+		return github.NewClient(httpClient), nil
+	}
+
+	return github.NewEnterpriseClient(config.GetGitHubBaseURL(), config.GetGitHubUploadURL(), httpClient)
+}
+
 // NewClient creates a Client and returns it; which
 // implementation it uses depends on the configuration of this
 // run. For example, a dry-run clients may be created which does
@@ -256,21 +884,25 @@ func NewClient(config cfg.Config) (Client, error) {
 
 	log := config.GetLogger()
 
-	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: config.GetConfigString("github-token")},
-	)
-	tc := oauth2.NewClient(ctx, ts)
-
-	client := github.NewClient(tc)
+	client, err := NewAPIClient(config)
+	if err != nil {
+		log.Errorf("Error authenticating to GitHub: %v", err)
+		return realGHClient{}, err
+	}
 
-	ret = realGHClient{
+	real := realGHClient{
 		client: *client,
 		log: log,
 	}
 
+	if config.IsDryRun() {
+		ret = dryrunGHClient{real}
+	} else {
+		ret = real
+	}
+
 	// Make a request so we can check that we can connect fine.
-	_, _, err := ret.getRateLimits(context.Background())
+	_, _, err = ret.getRateLimits(context.Background())
 	if err != nil {
 		return realGHClient{}, err
 	}
@@ -279,6 +911,25 @@ func NewClient(config cfg.Config) (Client, error) {
 	return ret, nil
 }
 
+// newlineReplaceRegex is a regex to match both "\r\n" and just "\n" newline styles,
+// in order to allow us to escape both sequences cleanly in the output of a dry run.
+var newlineReplaceRegex = regexp.MustCompile("\r?\n")
+
+// truncate is a utility function to replace all the newlines in
+// the string with the characters "\n", then truncate it to no
+// more than `length` characters
+func truncate(s string, length int) string {
+	if s == "" {
+		return "empty"
+	}
+
+	s = newlineReplaceRegex.ReplaceAllString(s, "\\n")
+	if len(s) <= length {
+		return s
+	}
+	return fmt.Sprintf("%s...", s[0:length])
+}
+
 func NewTestClient() TestGHClient {
 	return TestGHClient {}
 }
@@ -4,7 +4,7 @@ import (
 	"context"
 	"github.com/Sirupsen/logrus"
 	"github.com/indeedeng/issue-sync/cfg"
-	"github.com/google/go-github/github"
+	"github.com/google/go-github/v28/github"
 	"testing"
 	"time"
 )
@@ -16,16 +16,16 @@ func TestListIssues(t *testing.T) {
 
 	log := *cfg.NewLogger("test", "debug")
 
-	client.handleGetLogger = func() logrus.Entry {
+	client.HandleGetLogger = func() logrus.Entry {
 		return log
 	}
 
-	client.handleGetRepository = func(ctx context.Context, owner string, repo string) (repository *github.Repository, response *github.Response, e error) {
+	client.HandleGetRepository = func(ctx context.Context, owner string, repo string) (repository *github.Repository, response *github.Response, e error) {
 		hasProject := true
 		return &github.Repository{ HasProjects: &hasProject}, nil, nil
 	}
 
-	client.handleListByRepo = func(ctx context.Context, owner string, repo string, page int, since time.Time) (issues []*github.Issue, response *github.Response, e error) {
+	client.HandleListByRepo = func(ctx context.Context, owner string, repo string, page int, since time.Time) (issues []*github.Issue, response *github.Response, e error) {
 		issues = make([]*github.Issue, 3)
 		for i := 0; i < len(issues); i++ {
 			issues[i] = &github.Issue{}
@@ -33,7 +33,7 @@ func TestListIssues(t *testing.T) {
 		return issues, &github.Response{LastPage:3}, nil
 	}
 
-	client.handleListIssueEvents = func(ctx context.Context, owner, repo string, number int, page int) (events []*github.IssueEvent, response *github.Response, e error) {
+	client.HandleListIssueEvents = func(ctx context.Context, owner, repo string, number int, page int) (events []*github.IssueEvent, response *github.Response, e error) {
 		events = make([]*github.IssueEvent, 3)
 		for i := 0; i < len(events); i++ {
 			events[i] = &github.IssueEvent{}
@@ -48,3 +48,32 @@ func TestListIssues(t *testing.T) {
 		t.Fatalf("Expected len(issues) = 9; Got len(issues) = %d", len(issues))
 	}
 }
+
+func TestSearchNumbersQuery(t *testing.T) {
+	tests := []struct {
+		query     string
+		batchSize int
+		expected  string
+	}{
+		{"", 6, "repo:o/r is:issue (1 OR 2)"},
+		{"label:bug", 5, "repo:o/r is:issue (label:bug) AND (1 OR 2)"},
+		{"label:bug OR label:crash", 4, "repo:o/r is:issue (label:bug OR label:crash) AND (1 OR 2)"},
+		{"NOT(label:wontfix) AND -author:app/dependabot", 3, "repo:o/r is:issue (NOT(label:wontfix) AND -author:app/dependabot) AND (1 OR 2)"},
+	}
+
+	for _, test := range tests {
+		if q := searchNumbersQuery("o", "r", test.query, []int{1, 2}); q != test.expected {
+			t.Errorf("Expected the query for %q to be %q; got %q", test.query, test.expected, q)
+		}
+		batchSize, err := searchBatchSize(test.query)
+		if err != nil {
+			t.Errorf("searchBatchSize(%q) failed with error: %v", test.query, err)
+		} else if batchSize != test.batchSize {
+			t.Errorf("Expected %d numbers per query for %q; got %d", test.batchSize, test.query, batchSize)
+		}
+	}
+
+	if _, err := searchBatchSize("a OR b OR c OR d OR e OR f"); err == nil {
+		t.Error("Expected a query with 5 operators to be rejected")
+	}
+}
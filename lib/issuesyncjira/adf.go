@@ -0,0 +1,236 @@
+package issuesyncjira
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// adfNode is a node of an Atlassian Document Format document, which is how
+// version 3 of the JIRA REST API represents rich text such as descriptions,
+// comments and multi-line text fields.
+//
+// See https://developer.atlassian.com/cloud/jira/platform/apis/document/structure/
+type adfNode struct {
+	Type    string                 `json:"type"`
+	Version int                    `json:"version,omitempty"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+	Content []adfNode              `json:"content,omitempty"`
+	Text    string                 `json:"text,omitempty"`
+	Marks   []adfMark              `json:"marks,omitempty"`
+}
+
+// adfMark is formatting applied to an ADF text node, such as a link.
+type adfMark struct {
+	Type  string                 `json:"type"`
+	Attrs map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// headingRegex matches a heading line in JIRA wiki markup, e.g. "h4. Title".
+var headingRegex = regexp.MustCompile(`^h([1-6])\. (.*)$`)
+
+// wikiLinkRegex matches a link in JIRA wiki markup, e.g. "[text|https://example.com]".
+var wikiLinkRegex = regexp.MustCompile(`\[([^|\[\]]+)\|((?:https?://|mailto:)[^\]\s]+)\]`)
+
+// wikiToADF converts text in the subset of JIRA wiki markup issue-sync writes
+// (headings, rules, bulleted lists and links) to an ADF document. Every line
+// is kept, with consecutive lines joined by hard breaks and blank lines as empty
+// paragraphs, so that adfToWiki returns exactly the original text; otherwise
+// we couldn't tell whether a description or comment changed.
+func wikiToADF(text string) adfNode {
+	doc := adfNode{Type: "doc", Version: 1}
+
+	var paragraph []string
+	var items []string
+
+	flushParagraph := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		var content []adfNode
+		for i, line := range paragraph {
+			if i > 0 {
+				content = append(content, adfNode{Type: "hardBreak"})
+			}
+			content = append(content, wikiInlineToADF(line)...)
+		}
+		doc.Content = append(doc.Content, adfNode{Type: "paragraph", Content: content})
+		paragraph = nil
+	}
+	flushList := func() {
+		if len(items) == 0 {
+			return
+		}
+		list := adfNode{Type: "bulletList"}
+		for _, item := range items {
+			list.Content = append(list.Content, adfNode{
+				Type:    "listItem",
+				Content: []adfNode{{Type: "paragraph", Content: wikiInlineToADF(item)}},
+			})
+		}
+		doc.Content = append(doc.Content, list)
+		items = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case line == "":
+			flushParagraph()
+			flushList()
+			doc.Content = append(doc.Content, adfNode{Type: "paragraph"})
+		case line == "----":
+			flushParagraph()
+			flushList()
+			doc.Content = append(doc.Content, adfNode{Type: "rule"})
+		case headingRegex.MatchString(line):
+			flushParagraph()
+			flushList()
+			match := headingRegex.FindStringSubmatch(line)
+			doc.Content = append(doc.Content, adfNode{
+				Type:    "heading",
+				Attrs:   map[string]interface{}{"level": int(match[1][0] - '0')},
+				Content: wikiInlineToADF(match[2]),
+			})
+		case strings.HasPrefix(line, "* "):
+			flushParagraph()
+			items = append(items, strings.TrimPrefix(line, "* "))
+		default:
+			flushList()
+			paragraph = append(paragraph, line)
+		}
+	}
+	flushParagraph()
+	flushList()
+
+	return doc
+}
+
+// wikiInlineToADF converts a line of text to ADF text nodes, turning wiki markup
+// links into text with a link mark.
+func wikiInlineToADF(line string) []adfNode {
+	var nodes []adfNode
+	last := 0
+	for _, loc := range wikiLinkRegex.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] > last {
+			nodes = append(nodes, adfNode{Type: "text", Text: line[last:loc[0]]})
+		}
+		nodes = append(nodes, adfNode{
+			Type: "text",
+			Text: line[loc[2]:loc[3]],
+			Marks: []adfMark{{
+				Type:  "link",
+				Attrs: map[string]interface{}{"href": line[loc[4]:loc[5]]},
+			}},
+		})
+		last = loc[1]
+	}
+	if last < len(line) {
+		nodes = append(nodes, adfNode{Type: "text", Text: line[last:]})
+	}
+	return nodes
+}
+
+// adfToWiki converts an ADF document back to JIRA wiki markup. Documents
+// created by wikiToADF are converted back to the exact original text; nodes
+// wikiToADF never creates, such as those added by editing the issue in JIRA,
+// are converted to their closest wiki markup, or to their plain text.
+func adfToWiki(doc adfNode) string {
+	lines := make([]string, 0, len(doc.Content))
+	for _, block := range doc.Content {
+		lines = append(lines, adfBlockToWiki(block))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// adfBlockToWiki converts a block node of an ADF document to wiki markup.
+func adfBlockToWiki(block adfNode) string {
+	switch block.Type {
+	case "paragraph":
+		return adfInlineToWiki(block.Content)
+	case "heading":
+		level, _ := block.Attrs["level"].(float64)
+		if level == 0 {
+			if l, ok := block.Attrs["level"].(int); ok {
+				level = float64(l)
+			}
+		}
+		return fmt.Sprintf("h%d. %s", int(level), adfInlineToWiki(block.Content))
+	case "rule":
+		return "----"
+	case "bulletList", "orderedList":
+		bullet := "* "
+		if block.Type == "orderedList" {
+			bullet = "# "
+		}
+		items := make([]string, 0, len(block.Content))
+		for _, item := range block.Content {
+			items = append(items, bullet+adfToWiki(item))
+		}
+		return strings.Join(items, "\n")
+	case "codeBlock":
+		return "{code}\n" + adfInlineToWiki(block.Content) + "\n{code}"
+	case "blockquote":
+		return "{quote}\n" + adfToWiki(block) + "\n{quote}"
+	default:
+		if len(block.Content) > 0 && block.Content[0].Type == "paragraph" {
+			return adfToWiki(block)
+		}
+		return adfInlineToWiki(block.Content)
+	}
+}
+
+// adfInlineToWiki converts the inline nodes of an ADF block to wiki markup.
+func adfInlineToWiki(nodes []adfNode) string {
+	var b strings.Builder
+	for _, node := range nodes {
+		switch node.Type {
+		case "text":
+			href := ""
+			for _, mark := range node.Marks {
+				if mark.Type == "link" {
+					href, _ = mark.Attrs["href"].(string)
+				}
+			}
+			if href != "" {
+				fmt.Fprintf(&b, "[%s|%s]", node.Text, href)
+			} else {
+				b.WriteString(node.Text)
+			}
+		case "hardBreak":
+			b.WriteString("\n")
+		case "mention", "emoji":
+			if text, ok := node.Attrs["text"].(string); ok {
+				b.WriteString(text)
+			} else if name, ok := node.Attrs["shortName"].(string); ok {
+				b.WriteString(name)
+			}
+		case "inlineCard":
+			if u, ok := node.Attrs["url"].(string); ok {
+				fmt.Fprintf(&b, "[%s|%s]", u, u)
+			}
+		default:
+			b.WriteString(adfInlineToWiki(node.Content))
+		}
+	}
+	return b.String()
+}
+
+// decodeADF returns the ADF document a JSON value decoded into an interface{}
+// holds, if it's one.
+func decodeADF(value interface{}) (adfNode, bool) {
+	m, ok := value.(map[string]interface{})
+	if !ok || m["type"] != "doc" {
+		return adfNode{}, false
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return adfNode{}, false
+	}
+	var doc adfNode
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return adfNode{}, false
+	}
+	return doc, true
+}
@@ -0,0 +1,100 @@
+package issuesyncjira
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+func TestADFRoundTrip(t *testing.T) {
+	texts := []string{
+		"",
+		"A single line",
+		"Two\nlines",
+		"Two\n\nparagraphs\n",
+		"Windows\r\nnewlines\r\n",
+		"Comment [(ID 1)|https://github.com/o/r/issues/1#issuecomment-1] from GitHub user [octocat|https://github.com/octocat] at 10:00 AM, January 2 2006:\n\nBody",
+		"h4. Development\n* [abc1234|https://github.com/o/r/commit/abc1234] Fix it\n* \n----\nAfter",
+		"Not a [link|example] and [a|b|https://example.com]",
+		`{"labels":["a|b"],"body":"x"}`,
+	}
+
+	for _, text := range texts {
+		b, err := json.Marshal(wikiToADF(text))
+		if err != nil {
+			t.Fatalf("Unable to marshal ADF for %q: %v", text, err)
+		}
+		var doc adfNode
+		if err := json.Unmarshal(b, &doc); err != nil {
+			t.Fatalf("Unable to unmarshal ADF for %q: %v", text, err)
+		}
+
+		if got := adfToWiki(doc); got != text {
+			t.Errorf("Round trip of %q returned %q", text, got)
+		}
+	}
+}
+
+func TestWikiToADF(t *testing.T) {
+	doc := wikiToADF("h4. Title\nSee [the issue|https://github.com/o/r/issues/1].\n----")
+
+	if doc.Type != "doc" || doc.Version != 1 || len(doc.Content) != 3 {
+		t.Fatalf("Expected a doc with 3 blocks; got %+v", doc)
+	}
+	if heading := doc.Content[0]; heading.Type != "heading" || heading.Attrs["level"] != 4 {
+		t.Errorf("Expected a level 4 heading; got %+v", heading)
+	}
+
+	paragraph := doc.Content[1]
+	if paragraph.Type != "paragraph" || len(paragraph.Content) != 3 {
+		t.Fatalf("Expected a paragraph with 3 text nodes; got %+v", paragraph)
+	}
+	link := paragraph.Content[1]
+	if link.Text != "the issue" || len(link.Marks) != 1 || link.Marks[0].Attrs["href"] != "https://github.com/o/r/issues/1" {
+		t.Errorf("Expected a link to the issue; got %+v", link)
+	}
+
+	if rule := doc.Content[2]; rule.Type != "rule" {
+		t.Errorf("Expected a rule; got %+v", rule)
+	}
+}
+
+func TestConvertFromV3(t *testing.T) {
+	raw := map[string]interface{}{}
+	err := json.Unmarshal([]byte(`{
+		"key": "TPK-1",
+		"fields": {
+			"summary": "Summary",
+			"description": {"type": "doc", "version": 1, "content": [
+				{"type": "paragraph", "content": [{"type": "text", "text": "Line"}, {"type": "hardBreak"}, {"type": "text", "text": "Next"}]}
+			]},
+			"customfield_10001": {"type": "doc", "version": 1, "content": [
+				{"type": "codeBlock", "content": [{"type": "text", "text": "x := 1"}]}
+			]},
+			"comment": {"comments": [
+				{"id": "10", "body": {"type": "doc", "version": 1, "content": [
+					{"type": "paragraph", "content": [{"type": "mention", "attrs": {"text": "@octocat"}}]}
+				]}}
+			]}
+		}
+	}`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issue jira.Issue
+	if err := convertFromV3(raw, &issue); err != nil {
+		t.Fatalf("convertFromV3 failed with error: %v", err)
+	}
+
+	if issue.Fields.Description != "Line\nNext" {
+		t.Errorf("Expected description %q; got %q", "Line\nNext", issue.Fields.Description)
+	}
+	if field := issue.Fields.Unknowns["customfield_10001"]; field != "{code}\nx := 1\n{code}" {
+		t.Errorf("Expected the custom field as a code block; got %q", field)
+	}
+	if issue.Fields.Comments == nil || len(issue.Fields.Comments.Comments) != 1 || issue.Fields.Comments.Comments[0].Body != "@octocat" {
+		t.Errorf("Expected a comment mentioning @octocat; got %+v", issue.Fields.Comments)
+	}
+}
@@ -1,6 +1,7 @@
 package issuesyncjira
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/Sirupsen/logrus"
@@ -16,6 +17,7 @@ import (
 	"github.com/indeedeng/issue-sync/cfg"
 	"github.com/indeedeng/issue-sync/lib/utils"
 	"github.com/google/go-github/v28/github"
+	"golang.org/x/oauth2"
 )
 
 // commentDateFormat is the format used in the headers of JIRA comments.
@@ -29,7 +31,7 @@ const maxJQLIssueLength = 100
 // logs it as an error, and returns an error object with the contents
 // of the body. If an error occurs during reading, that error is
 // instead printed and returned. This function closes the body for
-// further reading.
+// further reading. Credentials in the body are redacted.
 func getErrorBody(log logrus.Entry, res *jira.Response) error {
 	defer func() {
 		err := res.Body.Close()
@@ -43,8 +45,35 @@ func getErrorBody(log logrus.Entry, res *jira.Response) error {
 		log.Errorf("Error occurred trying to read error body: %v", err)
 		return err
 	}
-	log.Debugf("Error body: %s", body)
-	return errors.New(string(body))
+	// Error bodies can echo the credentials of the request.
+	redacted := utils.RedactSecrets(string(body))
+	log.Debugf("Error body: %s", redacted)
+	return errors.New(redacted)
+}
+
+// loggingClient is a Client which logs with another logger, such as one with
+// the fields of the issue being synced.
+type loggingClient struct {
+	Client
+	log logrus.Entry
+}
+
+func (j loggingClient) getLogger() logrus.Entry {
+	return j.log
+}
+
+// WithLogger returns a client which logs the API calls it makes with a logger,
+// e.g. one with the fields of the issue being synced.
+func WithLogger(j Client, log logrus.Entry) Client {
+	if l, ok := j.(loggingClient); ok {
+		j = l.Client
+	}
+	return loggingClient{Client: j, log: log}
+}
+
+// withAction returns a logger for the API calls of an action.
+func withAction(log logrus.Entry, action string) logrus.Entry {
+	return *log.WithFields(logrus.Fields{"backend": "jira", "action": action})
 }
 
 // Client is a wrapper around the JIRA API clients library we
@@ -62,6 +91,12 @@ type Client interface {
 	addComment(id string, jComment *jira.Comment, jIssue *jira.Issue, ghComment *github.IssueComment, ghUser *github.User) (*jira.Comment, *jira.Response, error)
 	getTransitions(issue jira.Issue) ([]jira.Transition, *jira.Response, error)
 	applyTransition(issue jira.Issue, transition jira.Transition) (*jira.Response, error)
+	createVersion(version *jira.Version) (*jira.Version, *jira.Response, error)
+	updateVersion(version *jira.Version) (*jira.Version, *jira.Response, error)
+	getSprints(boardID int) ([]jira.Sprint, *jira.Response, error)
+	moveIssuesToSprint(sprintID int, issueKeys []string) (*jira.Response, error)
+	addIssueLink(link *jira.IssueLink) (*jira.Response, error)
+	addRemoteLink(key string, link *RemoteLink) (*jira.Response, error)
 }
 
 // realJIRAClient is a standard JIRA clients, which actually makes
@@ -83,59 +118,91 @@ type dryrunJIRAClient struct {
 	fieldMapper cfg.FieldMapper
 }
 
+// TestJiraClient is a Client for tests, whose calls are made to its Handle
+// functions.
 type TestJiraClient struct {
-	handleGetLogger func() logrus.Entry
-	handleGetFieldMapper func() cfg.FieldMapper
-	handleSearchIssues func(jql string) (interface{}, *jira.Response, error)
-	handleDo func(method string, url string, body interface{}, out interface{}) (*jira.Response, error)
-	handleGetIssue func(key string) (*jira.Issue, *jira.Response, error)
-	handleCreateIssue func(issue *jira.Issue) (*jira.Issue, *jira.Response, error)
-	handleUpdateIssue func(issue *jira.Issue) (*jira.Issue, *jira.Response, error)
-	handleAddComment func(id string, jComment *jira.Comment, jIssue *jira.Issue, ghComment *github.IssueComment, ghUser *github.User) (*jira.Comment, *jira.Response, error)
-	handleGetTransitions func(issue jira.Issue) ([]jira.Transition, *jira.Response, error)
-	handleApplyTransition func(issue jira.Issue, transition jira.Transition) (*jira.Response, error)
+	HandleGetLogger func() logrus.Entry
+	HandleGetFieldMapper func() cfg.FieldMapper
+	HandleSearchIssues func(jql string) (interface{}, *jira.Response, error)
+	HandleDo func(method string, url string, body interface{}, out interface{}) (*jira.Response, error)
+	HandleGetIssue func(key string) (*jira.Issue, *jira.Response, error)
+	HandleCreateIssue func(issue *jira.Issue) (*jira.Issue, *jira.Response, error)
+	HandleUpdateIssue func(issue *jira.Issue) (*jira.Issue, *jira.Response, error)
+	HandleAddComment func(id string, jComment *jira.Comment, jIssue *jira.Issue, ghComment *github.IssueComment, ghUser *github.User) (*jira.Comment, *jira.Response, error)
+	HandleGetTransitions func(issue jira.Issue) ([]jira.Transition, *jira.Response, error)
+	HandleApplyTransition func(issue jira.Issue, transition jira.Transition) (*jira.Response, error)
+	HandleCreateVersion func(version *jira.Version) (*jira.Version, *jira.Response, error)
+	HandleUpdateVersion func(version *jira.Version) (*jira.Version, *jira.Response, error)
+	HandleGetSprints func(boardID int) ([]jira.Sprint, *jira.Response, error)
+	HandleMoveIssuesToSprint func(sprintID int, issueKeys []string) (*jira.Response, error)
+	HandleAddIssueLink func(link *jira.IssueLink) (*jira.Response, error)
+	HandleAddRemoteLink func(key string, link *RemoteLink) (*jira.Response, error)
 }
 
 // Test Client
 
 func (j TestJiraClient) getLogger() logrus.Entry {
-	return j.handleGetLogger()
+	return j.HandleGetLogger()
 }
 
 func (j TestJiraClient) getFieldMapper() cfg.FieldMapper {
-	return j.handleGetFieldMapper()
+	return j.HandleGetFieldMapper()
 }
 
 func (j TestJiraClient) getTransitions(issue jira.Issue) ([]jira.Transition, *jira.Response, error) {
-	return j.handleGetTransitions(issue)
+	return j.HandleGetTransitions(issue)
 }
 
 func (j TestJiraClient) applyTransition(issue jira.Issue, transition jira.Transition) (*jira.Response, error) {
-	return j.handleApplyTransition(issue, transition)
+	return j.HandleApplyTransition(issue, transition)
 }
 
 func (j TestJiraClient) searchIssues(jql string) (interface{}, *jira.Response, error) {
-	return j.handleSearchIssues(jql)
+	return j.HandleSearchIssues(jql)
 }
 
 func (j TestJiraClient) getIssue(key string) (*jira.Issue, *jira.Response, error) {
-	return j.handleGetIssue(key)
+	return j.HandleGetIssue(key)
 }
 
 func (j TestJiraClient) createIssue(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
-	return j.handleCreateIssue(issue)
+	return j.HandleCreateIssue(issue)
 }
 
 func (j TestJiraClient) updateIssue(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
-	return j.handleUpdateIssue(issue)
+	return j.HandleUpdateIssue(issue)
 }
 
 func (j TestJiraClient) addComment(id string, jComment *jira.Comment, jIssue *jira.Issue, ghComment *github.IssueComment, ghUser *github.User) (*jira.Comment, *jira.Response, error) {
-	return j.handleAddComment(id, jComment, jIssue, ghComment, ghUser)
+	return j.HandleAddComment(id, jComment, jIssue, ghComment, ghUser)
 }
 
 func (j TestJiraClient) do(method string, url string, body interface{}, out interface{}) (*jira.Response, error) {
-	return j.handleDo(method, url, body, out)
+	return j.HandleDo(method, url, body, out)
+}
+
+func (j TestJiraClient) createVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	return j.HandleCreateVersion(version)
+}
+
+func (j TestJiraClient) updateVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	return j.HandleUpdateVersion(version)
+}
+
+func (j TestJiraClient) getSprints(boardID int) ([]jira.Sprint, *jira.Response, error) {
+	return j.HandleGetSprints(boardID)
+}
+
+func (j TestJiraClient) moveIssuesToSprint(sprintID int, issueKeys []string) (*jira.Response, error) {
+	return j.HandleMoveIssuesToSprint(sprintID, issueKeys)
+}
+
+func (j TestJiraClient) addIssueLink(link *jira.IssueLink) (*jira.Response, error) {
+	return j.HandleAddIssueLink(link)
+}
+
+func (j TestJiraClient) addRemoteLink(key string, link *RemoteLink) (*jira.Response, error) {
+	return j.HandleAddRemoteLink(key, link)
 }
 
 // Real Client
@@ -180,6 +247,35 @@ func (j realJIRAClient) do(method string, url string, body interface{}, out inte
 	return j.client.Do(req, out)
 }
 
+func (j realJIRAClient) createVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	return j.client.Version.Create(version)
+}
+
+func (j realJIRAClient) updateVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	return j.client.Version.Update(version)
+}
+
+func (j realJIRAClient) getSprints(boardID int) ([]jira.Sprint, *jira.Response, error) {
+	list, res, err := j.client.Board.GetAllSprintsWithOptions(boardID, &jira.GetAllSprintsOptions{State: "active,future"})
+	if err != nil {
+		return nil, res, err
+	}
+	return list.Values, res, nil
+}
+
+func (j realJIRAClient) moveIssuesToSprint(sprintID int, issueKeys []string) (*jira.Response, error) {
+	return j.client.Sprint.MoveIssuesToSprint(sprintID, issueKeys)
+}
+
+func (j realJIRAClient) addIssueLink(link *jira.IssueLink) (*jira.Response, error) {
+	return j.client.Issue.AddLink(link)
+}
+
+func (j realJIRAClient) addRemoteLink(key string, link *RemoteLink) (*jira.Response, error) {
+	// The JIRA API we're using doesn't support remote links, so we build the request ourselves.
+	return j.do("POST", fmt.Sprintf("rest/api/2/issue/%s/remotelink", key), link, nil)
+}
+
 // DRY RUN CLIENT
 func (j dryrunJIRAClient) getLogger() logrus.Entry {
 	return j.log
@@ -296,38 +392,152 @@ func (j dryrunJIRAClient) do(method string, url string, body interface{}, out in
 	return j.client.Do(req, out)
 }
 
-// NewClient creates a new Client and configures it with
-// the config object provided. The type of clients created depends
-// on the configuration; currently, it creates either a standard
-// clients, or a dry-run clients.
-func NewClient(config *cfg.Config) (Client, error) {
+func (j dryrunJIRAClient) createVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	log := j.log
+
+	log.Info("")
+	log.Info("Create new JIRA version:")
+	log.Infof("  Name: %s", version.Name)
+	log.Infof("  Description: %s", truncate(version.Description, 50))
+	log.Infof("  Release Date: %s", version.ReleaseDate)
+	log.Infof("  Released: %t", version.Released)
+	log.Info("")
+
+	return version, nil, nil
+}
+
+func (j dryrunJIRAClient) updateVersion(version *jira.Version) (*jira.Version, *jira.Response, error) {
+	log := j.log
+
+	log.Info("")
+	log.Infof("Update JIRA version %s:", version.Name)
+	log.Infof("  Release Date: %s", version.ReleaseDate)
+	log.Infof("  Released: %t", version.Released)
+	log.Info("")
+
+	return version, nil, nil
+}
+
+func (j dryrunJIRAClient) getSprints(boardID int) ([]jira.Sprint, *jira.Response, error) {
+	list, res, err := j.client.Board.GetAllSprintsWithOptions(boardID, &jira.GetAllSprintsOptions{State: "active,future"})
+	if err != nil {
+		return nil, res, err
+	}
+	return list.Values, res, nil
+}
+
+func (j dryrunJIRAClient) moveIssuesToSprint(sprintID int, issueKeys []string) (*jira.Response, error) {
+	log := j.log
+
+	log.Info("")
+	log.Info("Move JIRA issues to sprint:")
+	log.Infof("  Sprint ID: %d", sprintID)
+	log.Infof("  Issues: %s", strings.Join(issueKeys, ", "))
+	log.Info("")
+
+	return nil, nil
+}
+
+func (j dryrunJIRAClient) addIssueLink(link *jira.IssueLink) (*jira.Response, error) {
+	log := j.log
+
+	log.Info("")
+	log.Info("Create JIRA issue link:")
+	log.Infof("  Type: %s", link.Type.Name)
+	log.Infof("  Inward Issue: %s", link.InwardIssue.Key)
+	log.Infof("  Outward Issue: %s", link.OutwardIssue.Key)
+	log.Info("")
+
+	return nil, nil
+}
+
+func (j dryrunJIRAClient) addRemoteLink(key string, link *RemoteLink) (*jira.Response, error) {
+	log := j.log
+
+	log.Info("")
+	log.Infof("Set remote link on JIRA issue %s:", key)
+	log.Infof("  Global ID: %s", link.GlobalID)
+	log.Infof("  Title: %s", link.Object.Title)
+	log.Infof("  URL: %s", link.Object.URL)
+	log.Infof("  Status: %s", link.Object.Status.Icon.Title)
+	log.Info("")
+
+	return nil, nil
+}
+
+// NewAPIClient creates a client of the JIRA API library we use, authenticated
+// as configured. Unlike NewClient, it doesn't load the JIRA configuration, so
+// it works even if the JIRA project isn't set up for issue-sync yet.
+func NewAPIClient(config cfg.Config) (*jira.Client, error) {
 	log := config.GetLogger()
 
+	transport, err := utils.NewTransport(config.GetJIRATransportOptions())
+	if err != nil {
+		log.Errorf("Error configuring the connection to JIRA: %v", err)
+		return nil, err
+	}
+	// The OAuth libraries make their requests with the client in the context.
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, &http.Client{Transport: transport})
+
 	var httpClient *http.Client
-	var err error
+	uri := config.GetConfigString("jira-uri")
 
-	if config.IsBasicAuth() {
-		transport := jira.BasicAuthTransport{
+	switch config.GetJIRAAuthMethod() {
+	case cfg.JIRAAuthBasic:
+		basicAuth := jira.BasicAuthTransport{
 			Username: config.GetConfigString("jira-user"),
 			Password: config.GetConfigString("jira-pass"),
+			Transport: transport,
 		}
-		httpClient = transport.Client()
-	} else {
-		httpClient, err = newJIRAHTTPClient(*config)
+		httpClient = basicAuth.Client()
+	case cfg.JIRAAuthAPIToken:
+		basicAuth := jira.BasicAuthTransport{
+			Username: config.GetConfigString("jira-user"),
+			Password: config.GetConfigString("jira-api-token"),
+			Transport: transport,
+		}
+		httpClient = basicAuth.Client()
+	case cfg.JIRAAuthPAT:
+		httpClient = oauth2.NewClient(ctx, oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: config.GetConfigString("jira-pat")},
+		))
+	case cfg.JIRAAuthOAuth2:
+		httpClient, uri, err = newJIRAOAuth2Client(ctx, config)
+		if err != nil {
+			log.Errorf("Error getting OAuth 2.0 access token: %v", err)
+			return nil, err
+		}
+	default:
+		httpClient, err = newJIRAHTTPClient(config, transport)
 		if err != nil {
 			log.Errorf("Error getting OAuth config: %v", err)
-			return dryrunJIRAClient{}, err
+			return nil, err
 		}
 	}
 
-	client, err := jira.NewClient(httpClient, config.GetConfigString("jira-uri"))
+	client, err := jira.NewClient(httpClient, uri)
 	if err != nil {
 		log.Errorf("Error initializing JIRA clients; check your base URI. Error: %v", err)
-		return dryrunJIRAClient{}, err
+		return nil, err
 	}
 
 	log.Debug("JIRA clients initialized")
 
+	return client, nil
+}
+
+// NewClient creates a new Client and configures it with
+// the config object provided. The type of clients created depends
+// on the configuration; currently, it creates either a standard
+// clients, or a dry-run clients.
+func NewClient(config *cfg.Config) (Client, error) {
+	log := config.GetLogger()
+
+	client, err := NewAPIClient(*config)
+	if err != nil {
+		return dryrunJIRAClient{}, err
+	}
+
 	err = config.LoadJIRAConfig(*client)
 
 	if err != nil {
@@ -337,18 +547,35 @@ func NewClient(config *cfg.Config) (Client, error) {
 
 	var j Client
 
+	useV3 := getAPIVersion(*config, *client, log) == cfg.JIRAAPIVersion3
+
 	if config.IsDryRun() {
-		j = dryrunJIRAClient{
+		dryrunClient := dryrunJIRAClient{
 			log: config.GetLogger(),
 			client: *client,
 			fieldMapper: config.GetFieldMapper(),
 		}
+		if useV3 {
+			j = dryrunV3JIRAClient{dryrunJIRAClient: dryrunClient}
+		} else {
+			j = dryrunClient
+		}
 	} else {
-		j = realJIRAClient{
+		realClient := realJIRAClient{
 			client: *client,
 			log: config.GetLogger(),
 			fieldMapper: config.GetFieldMapper(),
 		}
+		if useV3 {
+			richTextFields, err := getRichTextFields(*client)
+			if err != nil {
+				log.Errorf("Error retrieving JIRA fields: %v", err)
+				return dryrunJIRAClient{}, err
+			}
+			j = v3JIRAClient{realJIRAClient: realClient, richTextFields: richTextFields}
+		} else {
+			j = realClient
+		}
 	}
 
 	return j, nil
@@ -418,7 +645,7 @@ func ListIssues(j Client, timeout time.Duration, jiraProjectKey string,  githubI
 		jql = fmt.Sprintf("project='%s'", jiraProjectKey)
 	}
 
-	ji, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	ji, res, err := utils.Retry(withAction(log, "list_issues"), timeout, func() (interface{}, interface{}, error) {
 		return j.searchIssues(jql)
 	})
 
@@ -474,7 +701,7 @@ func ListIssues(j Client, timeout time.Duration, jiraProjectKey string,  githubI
 func GetIssue(j Client, timeout time.Duration, key string) (jira.Issue, error) {
 	log := j.getLogger()
 
-	i, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	i, res, err := utils.Retry(withAction(log, "get_issue"), timeout, func() (interface{}, interface{}, error) {
 		return j.getIssue(key)
 	})
 	if err != nil {
@@ -496,7 +723,7 @@ func GetIssue(j Client, timeout time.Duration, key string) (jira.Issue, error) {
 func CreateIssue(j Client, timeout time.Duration, issue jira.Issue) (jira.Issue, error) {
 	log := j.getLogger()
 
-	i, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	i, res, err := utils.Retry(withAction(log, "create_issue"), timeout, func() (interface{}, interface{}, error) {
 		return j.createIssue(&issue)
 	})
 	if err != nil {
@@ -518,7 +745,7 @@ func CreateIssue(j Client, timeout time.Duration, issue jira.Issue) (jira.Issue,
 func UpdateIssue(j Client, timeout time.Duration, issue jira.Issue) (jira.Issue, error) {
 	log := j.getLogger()
 
-	i, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	i, res, err := utils.Retry(withAction(log, "update_issue"), timeout, func() (interface{}, interface{}, error) {
 		return j.updateIssue(&issue)
 	})
 	if err != nil {
@@ -573,7 +800,7 @@ func CreateComment(j Client, timeout time.Duration, jIssue jira.Issue, ghComment
 		return jira.Comment{}, err
 	}
 
-	com, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	com, res, err := utils.Retry(withAction(log, "create_comment"), timeout, func() (interface{}, interface{}, error) {
 		return j.addComment(jIssue.ID, &jComment, &jIssue, &ghComment, &ghUser)
 	})
 	if err != nil {
@@ -625,7 +852,7 @@ func UpdateComment(j Client, timeout time.Duration, issue jira.Issue, id string,
 
 	jComment := new(jira.Comment)
 
-	com, res, err := utils.Retry(log, timeout, func() (interface{}, interface{}, error) {
+	com, res, err := utils.Retry(withAction(log, "update_comment"), timeout, func() (interface{}, interface{}, error) {
 		url := fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id)
 		res, err := j.do("PUT", url, requestBody, nil)
 		return jComment, res, err
@@ -642,6 +869,30 @@ func UpdateComment(j Client, timeout time.Duration, issue jira.Issue, id string,
 	return *co, nil
 }
 
+// CreateNote adds a comment with the given body, written by issue-sync itself
+// rather than copied from GitHub, to a JIRA issue (identified by its key).
+func CreateNote(j Client, timeout time.Duration, key string, body string) error {
+	log := j.getLogger()
+
+	requestBody := struct {
+		Body string `json:"body"`
+	}{
+		Body: body,
+	}
+
+	_, res, err := utils.Retry(withAction(log, "create_note"), timeout, func() (interface{}, interface{}, error) {
+		url := fmt.Sprintf("rest/api/2/issue/%s/comment", key)
+		res, err := j.do("POST", url, requestBody, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error creating comment on JIRA issue %s: %v", key, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
+
 // newlineReplaceRegex is a regex to match both "\r\n" and just "\n" newline styles,
 // in order to allow us to escape both sequences cleanly in the output of a dry run.
 var newlineReplaceRegex = regexp.MustCompile("\r?\n")
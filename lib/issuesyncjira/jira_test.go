@@ -23,15 +23,15 @@ func TestListIssues(t *testing.T) {
 
 	log := *cfg.NewLogger("test", "debug")
 
-	client.handleGetLogger = func() logrus.Entry {
+	client.HandleGetLogger = func() logrus.Entry {
 		return log
 	}
 
-	client.handleGetFieldMapper = func() cfg.FieldMapper {
+	client.HandleGetFieldMapper = func() cfg.FieldMapper {
 		return testFieldMapper
 	}
 
-	client.handleSearchIssues = func(jql string) (i interface{}, response *jira.Response, e error) {
+	client.HandleSearchIssues = func(jql string) (i interface{}, response *jira.Response, e error) {
 		issues := make([]jira.Issue, 3)
 		for i := 0; i < len(issues); i++ {
 			issues[i] = jira.Issue{Fields: &jira.IssueFields{Project:jira.Project{Key: testProjectKey}}}
@@ -53,11 +53,11 @@ func TestTryApplyTransitionWithName(t *testing.T) {
 	client := NewTestClient()
 	log := *cfg.NewLogger("test", "debug")
 
-	client.handleGetLogger = func() logrus.Entry {
+	client.HandleGetLogger = func() logrus.Entry {
 		return log
 	}
 
-	client.handleGetTransitions = func(issue jira.Issue) (transitions []jira.Transition, response *jira.Response, e error) {
+	client.HandleGetTransitions = func(issue jira.Issue) (transitions []jira.Transition, response *jira.Response, e error) {
 		transitions = make([]jira.Transition, 3)
 		for i := 0; i < len(transitions); i++ {
 			transitions[i] = jira.Transition{To: jira.Status{Name:fmt.Sprintf("Transition_%d", i)}}
@@ -65,7 +65,7 @@ func TestTryApplyTransitionWithName(t *testing.T) {
 		return transitions, &jira.Response{}, nil
 	}
 
-	client.handleApplyTransition = func(issue jira.Issue, transition jira.Transition) (response *jira.Response, e error) {
+	client.HandleApplyTransition = func(issue jira.Issue, transition jira.Transition) (response *jira.Response, e error) {
 		return &jira.Response{}, nil
 	}
 
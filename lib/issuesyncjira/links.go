@@ -0,0 +1,57 @@
+package issuesyncjira
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// CreateIssueLink links two JIRA issues (identified by their keys) with the
+// issue link type of the given name, e.g. "Relates".
+func CreateIssueLink(j Client, timeout time.Duration, linkType string, inwardKey string, outwardKey string) error {
+	log := j.getLogger()
+
+	link := jira.IssueLink{
+		Type:         jira.IssueLinkType{Name: linkType},
+		InwardIssue:  &jira.Issue{Key: inwardKey},
+		OutwardIssue: &jira.Issue{Key: outwardKey},
+	}
+
+	_, res, err := utils.Retry(withAction(log, "create_issue_link"), timeout, func() (interface{}, interface{}, error) {
+		res, err := j.addIssueLink(&link)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error linking JIRA issues %s and %s: %v", inwardKey, outwardKey, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
+
+// UpdateIssueFields sets only the given fields of a JIRA issue (identified by
+// its key), leaving all others untouched. The keys of `fields` are JIRA field
+// IDs, such as "parent" or "customfield_10001".
+func UpdateIssueFields(j Client, timeout time.Duration, key string, fields map[string]interface{}) error {
+	log := j.getLogger()
+
+	requestBody := struct {
+		Fields map[string]interface{} `json:"fields"`
+	}{
+		Fields: fields,
+	}
+
+	_, res, err := utils.Retry(withAction(log, "update_issue_fields"), timeout, func() (interface{}, interface{}, error) {
+		url := fmt.Sprintf("rest/api/2/issue/%s", key)
+		res, err := j.do("PUT", url, requestBody, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error updating fields of JIRA issue %s: %v", key, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
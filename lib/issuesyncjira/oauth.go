@@ -13,14 +13,15 @@ import (
 	"os"
 
 	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/utils"
 	"github.com/dghubble/oauth1"
 )
 
 // newJIRAHTTPClient obtains an access token (either from configuration
 // or from an OAuth handshake) and creates an HTTP client that uses the
-// token, which can be used to configure a JIRA client.
-func newJIRAHTTPClient(config cfg.Config) (*http.Client, error) {
-	ctx := context.Background()
+// token and transport, which can be used to configure a JIRA client.
+func newJIRAHTTPClient(config cfg.Config, transport http.RoundTripper) (*http.Client, error) {
+	ctx := context.WithValue(context.Background(), oauth1.HTTPClient, &http.Client{Transport: transport})
 
 	oauthConfig, err := oauthConfig(config)
 	if err != nil {
@@ -29,7 +30,7 @@ func newJIRAHTTPClient(config cfg.Config) (*http.Client, error) {
 
 	tok, ok := jiraTokenFromConfig(config)
 	if !ok {
-		tok, err = jiraTokenFromWeb(oauthConfig)
+		tok, err = jiraTokenFromWeb(oauthConfig, transport)
 		if err != nil {
 			return nil, err
 		}
@@ -43,7 +44,16 @@ func newJIRAHTTPClient(config cfg.Config) (*http.Client, error) {
 // configuration, and creates an OAuth configuration which can
 // be used to begin a handshake.
 func oauthConfig(config cfg.Config) (oauth1.Config, error) {
-	pvtKeyPath := config.GetConfigString("jira-private-key-path")
+	return newOAuthConfig(
+		config.GetConfigString("jira-uri"),
+		config.GetConfigString("jira-consumer-key"),
+		config.GetConfigString("jira-private-key-path"),
+	)
+}
+
+// newOAuthConfig creates an OAuth configuration for a JIRA server from the
+// consumer key and the path of the private key of its application link.
+func newOAuthConfig(uri string, consumerKey string, pvtKeyPath string) (oauth1.Config, error) {
 
 	pvtKeyFile, err := os.Open(pvtKeyPath)
 	if err != nil {
@@ -68,10 +78,8 @@ func oauthConfig(config cfg.Config) (oauth1.Config, error) {
 		return oauth1.Config{}, fmt.Errorf("unable to parse PKCS1 private key: %v", err)
 	}
 
-	uri := config.GetConfigString("jira-uri")
-
 	return oauth1.Config{
-		ConsumerKey: config.GetConfigString("jira-consumer-key"),
+		ConsumerKey: consumerKey,
 		CallbackURL: "oob",
 		Endpoint: oauth1.Endpoint{
 			RequestTokenURL: fmt.Sprintf("%splugins/servlet/oauth/request-token", uri),
@@ -84,6 +92,22 @@ func oauthConfig(config cfg.Config) (oauth1.Config, error) {
 	}, nil
 }
 
+// JIRATokenFromWeb performs an OAuth handshake with the configured JIRA server,
+// prompting the user to authorize issue-sync in their browser, and returns the
+// access token.
+func JIRATokenFromWeb(config cfg.Config) (*oauth1.Token, error) {
+	oauthConfig, err := oauthConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := utils.NewTransport(config.GetJIRATransportOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return jiraTokenFromWeb(oauthConfig, transport)
+}
+
 // jiraTokenFromConfig attempts to load an OAuth access token from the
 // application configuration file. It returns the token (or null if not
 // configured) and an "ok" bool to indicate whether the token is provided.
@@ -106,7 +130,15 @@ func jiraTokenFromConfig(config cfg.Config) (*oauth1.Token, bool) {
 
 // jiraTokenFromWeb performs an OAuth handshake, obtaining a request and
 // then an access token by authorizing with the JIRA REST API.
-func jiraTokenFromWeb(config oauth1.Config) (*oauth1.Token, error) {
+func jiraTokenFromWeb(config oauth1.Config, transport http.RoundTripper) (*oauth1.Token, error) {
+	// The OAuth library makes the handshake requests with the default client,
+	// so it's replaced for the duration of the handshake.
+	defaultClient := http.DefaultClient
+	http.DefaultClient = &http.Client{Transport: transport}
+	defer func() {
+		http.DefaultClient = defaultClient
+	}()
+
 	requestToken, requestSecret, err := config.RequestToken()
 	if err != nil {
 		return nil, fmt.Errorf("unable to get request token: %v", err)
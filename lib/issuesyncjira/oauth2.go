@@ -0,0 +1,161 @@
+package issuesyncjira
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"golang.org/x/oauth2"
+)
+
+// Endpoints of Atlassian's OAuth 2.0 (3LO) authorization server and API gateway.
+const (
+	atlassianAuthURL  = "https://auth.atlassian.com/authorize"
+	atlassianTokenURL = "https://auth.atlassian.com/oauth/token"
+	atlassianAPIURL   = "https://api.atlassian.com"
+)
+
+// oauth2Scopes are the scopes issue-sync requests for OAuth 2.0 apps;
+// offline_access is needed to get a refresh token.
+var oauth2Scopes = []string{"read:jira-work", "write:jira-work", "read:jira-user", "offline_access"}
+
+// newJIRAOAuth2Client creates an HTTP client which authenticates with an OAuth 2.0
+// (3LO) access token, refreshed as needed, and returns it with the base URI of
+// the JIRA API, which OAuth 2.0 apps access through Atlassian's API gateway.
+// Without a refresh token, an authorization is performed first. Requests are
+// made with the HTTP client in the context, if any.
+func newJIRAOAuth2Client(ctx context.Context, config cfg.Config) (*http.Client, string, error) {
+	oauthConfig := &oauth2.Config{
+		ClientID:     config.GetConfigString("jira-oauth2-client-id"),
+		ClientSecret: config.GetConfigString("jira-oauth2-client-secret"),
+		RedirectURL:  config.GetConfigString("jira-oauth2-redirect-url"),
+		Scopes:       oauth2Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  atlassianAuthURL,
+			TokenURL: atlassianTokenURL,
+		},
+	}
+
+	tok := &oauth2.Token{RefreshToken: config.GetConfigString("jira-oauth2-refresh-token")}
+	if tok.RefreshToken == "" {
+		var err error
+		tok, err = jiraOAuth2TokenFromWeb(ctx, oauthConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		if err := config.SetJIRAOAuth2RefreshToken(tok.RefreshToken); err != nil {
+			return nil, "", err
+		}
+	}
+
+	source := oauth2.ReuseTokenSource(nil, &savingTokenSource{
+		config:       config,
+		source:       oauthConfig.TokenSource(ctx, tok),
+		refreshToken: tok.RefreshToken,
+	})
+	httpClient := oauth2.NewClient(ctx, source)
+
+	cloudID := config.GetConfigString("jira-cloud-id")
+	if cloudID == "" {
+		var err error
+		cloudID, err = findCloudID(httpClient, config.GetConfigString("jira-uri"))
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	return httpClient, fmt.Sprintf("%s/ex/jira/%s/", atlassianAPIURL, cloudID), nil
+}
+
+// savingTokenSource saves each new refresh token as soon as it's issued, since
+// Atlassian rotates refresh tokens and the previous one stops working.
+type savingTokenSource struct {
+	config       cfg.Config
+	source       oauth2.TokenSource
+	// refreshToken is the last refresh token saved.
+	refreshToken string
+}
+
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	tok, err := s.source.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to refresh the JIRA OAuth 2.0 access token: %v", err)
+	}
+
+	if tok.RefreshToken != "" && tok.RefreshToken != s.refreshToken {
+		if err := s.config.SetJIRAOAuth2RefreshToken(tok.RefreshToken); err != nil {
+			log := s.config.GetLogger()
+			log.Errorf("Error saving the rotated JIRA OAuth 2.0 refresh token; authorize issue-sync again if the next run can't authenticate. Error: %v", err)
+		}
+		s.refreshToken = tok.RefreshToken
+	}
+
+	return tok, nil
+}
+
+// jiraOAuth2TokenFromWeb performs an OAuth 2.0 authorization, prompting the user
+// to authorize issue-sync in their browser and to enter the code they're
+// redirected with.
+func jiraOAuth2TokenFromWeb(ctx context.Context, config *oauth2.Config) (*oauth2.Token, error) {
+	authURL := config.AuthCodeURL("issue-sync",
+		oauth2.SetAuthURLParam("audience", "api.atlassian.com"),
+		oauth2.SetAuthURLParam("prompt", "consent"),
+	)
+
+	fmt.Printf("Please go to the following URL in your browser:\n%v\n\n", authURL)
+	fmt.Print("Authorization code (the code parameter of the URL you are redirected to): ")
+
+	var code string
+	_, err := fmt.Scan(&code)
+	fmt.Println()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read auth code: %v", err)
+	}
+
+	tok, err := config.Exchange(ctx, code)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get access token: %v", err)
+	}
+	if tok.RefreshToken == "" {
+		return nil, errors.New("no refresh token was issued; check that the app has the offline_access scope")
+	}
+
+	return tok, nil
+}
+
+// accessibleResource is a site an OAuth 2.0 app is authorized to access.
+type accessibleResource struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// findCloudID returns the ID of the JIRA Cloud site at a URI, among those the
+// OAuth 2.0 app is authorized to access.
+func findCloudID(httpClient *http.Client, uri string) (string, error) {
+	res, err := httpClient.Get(atlassianAPIURL + "/oauth/token/accessible-resources")
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to list the sites the JIRA OAuth 2.0 app can access: %s", res.Status)
+	}
+
+	var resources []accessibleResource
+	if err := json.NewDecoder(res.Body).Decode(&resources); err != nil {
+		return "", err
+	}
+
+	for _, r := range resources {
+		if strings.TrimSuffix(r.URL, "/") == strings.TrimSuffix(uri, "/") {
+			return r.ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("the JIRA OAuth 2.0 app isn't authorized to access %s; set jira-cloud-id, or authorize it again", uri)
+}
@@ -0,0 +1,153 @@
+package issuesyncjira
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"golang.org/x/oauth2"
+)
+
+// redirectTransport sends every request to a test server, whatever its host.
+type redirectTransport struct {
+	server *url.URL
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.URL.Scheme = t.server.Scheme
+	req.URL.Host = t.server.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestFindCloudID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth/token/accessible-resources" {
+			t.Errorf("Unexpected request to %s", r.URL.Path)
+		}
+		w.Write([]byte(`[
+			{"id": "1111", "url": "https://other.atlassian.net"},
+			{"id": "2222", "url": "https://example.atlassian.net"}
+		]`))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	httpClient := &http.Client{Transport: redirectTransport{serverURL}}
+
+	cloudID, err := findCloudID(httpClient, "https://example.atlassian.net/")
+	if err != nil {
+		t.Fatalf("findCloudID failed with error: %v", err)
+	}
+	if cloudID != "2222" {
+		t.Errorf("Expected cloud ID 2222; got %s", cloudID)
+	}
+
+	if _, err := findCloudID(httpClient, "https://missing.atlassian.net"); err == nil {
+		t.Errorf("Expected a site the app can't access to be an error")
+	}
+}
+
+func TestPATAuthentication(t *testing.T) {
+	authorization := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":        "error",
+		"jira-uri":         server.URL,
+		"jira-auth-method": cfg.JIRAAuthPAT,
+		"jira-pat":         "personal-token",
+	})
+	client, err := NewAPIClient(config)
+	if err != nil {
+		t.Fatalf("NewAPIClient failed with error: %v", err)
+	}
+
+	req, err := client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Do(req, nil); err != nil {
+		t.Fatalf("Request failed with error: %v", err)
+	}
+	if authorization != "Bearer personal-token" {
+		t.Errorf("Expected the PAT as a bearer token; got Authorization: %q", authorization)
+	}
+}
+
+// rotatingTokenSource issues tokens with a new refresh token each time.
+type rotatingTokenSource struct {
+	tokens []string
+	err    error
+}
+
+func (s *rotatingTokenSource) Token() (*oauth2.Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	tok := &oauth2.Token{AccessToken: "access", RefreshToken: s.tokens[0]}
+	s.tokens = s.tokens[1:]
+	return tok, nil
+}
+
+func TestSavingTokenSourceSavesRotatedToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	secretsPath := filepath.Join(dir, "secrets.json")
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":                 "error",
+		"secrets-file":              secretsPath,
+		"jira-oauth2-refresh-token": "first",
+	})
+	source := &savingTokenSource{
+		config:       config,
+		source:       &rotatingTokenSource{tokens: []string{"first", "second"}},
+		refreshToken: "first",
+	}
+
+	saved := func() interface{} {
+		b, err := ioutil.ReadFile(secretsPath)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		var secrets map[string]interface{}
+		if err := json.Unmarshal(b, &secrets); err != nil {
+			t.Fatal(err)
+		}
+		return secrets["jira-oauth2-refresh-token"]
+	}
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token failed with error: %v", err)
+	}
+	if token := saved(); token != nil {
+		t.Errorf("Expected an unchanged refresh token not to be saved; got %v", token)
+	}
+
+	if _, err := source.Token(); err != nil {
+		t.Fatalf("Token failed with error: %v", err)
+	}
+	if token := saved(); token != "second" {
+		t.Errorf("Expected the rotated refresh token to be saved at once; got %v", token)
+	}
+
+	source.source = &rotatingTokenSource{err: errors.New("invalid_grant")}
+	if _, err := source.Token(); err == nil {
+		t.Errorf("Expected a failed refresh to be an error")
+	}
+}
@@ -0,0 +1,97 @@
+package issuesyncjira
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// issuePropertyKeys is the list of the entity properties set on a JIRA issue.
+type issuePropertyKeys struct {
+	Keys []struct {
+		Key string `json:"key"`
+	} `json:"keys"`
+}
+
+// issueProperty is an entity property of a JIRA issue, whose value is left
+// undecoded.
+type issueProperty struct {
+	Value json.RawMessage `json:"value"`
+}
+
+// GetIssueProperty reads the value of an entity property of a JIRA issue
+// (identified by its key) into `value`, which must be a pointer. The returned
+// bool is false if the issue doesn't have the property.
+func GetIssueProperty(j Client, timeout time.Duration, key string, property string, value interface{}) (bool, error) {
+	log := j.getLogger()
+
+	k, res, err := utils.Retry(withAction(log, "get_issue_property"), timeout, func() (interface{}, interface{}, error) {
+		keys := new(issuePropertyKeys)
+		url := fmt.Sprintf("rest/api/2/issue/%s/properties", key)
+		res, err := j.do("GET", url, nil, keys)
+		return keys, res, err
+	})
+	if err != nil {
+		log.Errorf("Error listing properties of JIRA issue %s: %v", key, err)
+		return false, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	keys, ok := k.(*issuePropertyKeys)
+	if !ok {
+		log.Errorf("List JIRA issue properties did not return properties! Got: %v", k)
+		return false, fmt.Errorf("list JIRA issue properties failed: expected *issuePropertyKeys; got %T", k)
+	}
+
+	found := false
+	for _, p := range keys.Keys {
+		if p.Key == property {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return false, nil
+	}
+
+	p, res, err := utils.Retry(withAction(log, "get_issue_property"), timeout, func() (interface{}, interface{}, error) {
+		prop := new(issueProperty)
+		url := fmt.Sprintf("rest/api/2/issue/%s/properties/%s", key, property)
+		res, err := j.do("GET", url, nil, prop)
+		return prop, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving property %s of JIRA issue %s: %v", property, key, err)
+		return false, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	prop, ok := p.(*issueProperty)
+	if !ok {
+		log.Errorf("Get JIRA issue property did not return property! Got: %v", p)
+		return false, fmt.Errorf("get JIRA issue property failed: expected *issueProperty; got %T", p)
+	}
+
+	if err := json.Unmarshal(prop.Value, value); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetIssueProperty sets an entity property of a JIRA issue (identified by its
+// key) to the JSON representation of `value`.
+func SetIssueProperty(j Client, timeout time.Duration, key string, property string, value interface{}) error {
+	log := j.getLogger()
+
+	_, res, err := utils.Retry(withAction(log, "set_issue_property"), timeout, func() (interface{}, interface{}, error) {
+		url := fmt.Sprintf("rest/api/2/issue/%s/properties/%s", key, property)
+		res, err := j.do("PUT", url, value, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error setting property %s of JIRA issue %s: %v", property, key, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
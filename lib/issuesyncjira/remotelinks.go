@@ -0,0 +1,88 @@
+package issuesyncjira
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// RemoteLink represents a link from a JIRA issue to an object in another
+// system. For an example of its structure, make a request to
+// `${jira-uri}/rest/api/2/issue/${key}/remotelink`.
+type RemoteLink struct {
+	ID           int                   `json:"id,omitempty"`
+	GlobalID     string                `json:"globalId"`
+	Application  RemoteLinkApplication `json:"application"`
+	Relationship string                `json:"relationship,omitempty"`
+	Object       RemoteLinkObject      `json:"object"`
+}
+
+// RemoteLinkApplication identifies the system a remote link points to.
+type RemoteLinkApplication struct {
+	Type string `json:"type,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// RemoteLinkObject describes the object a remote link points to.
+type RemoteLinkObject struct {
+	URL     string           `json:"url"`
+	Title   string           `json:"title"`
+	Summary string           `json:"summary,omitempty"`
+	Icon    RemoteLinkIcon   `json:"icon"`
+	Status  RemoteLinkStatus `json:"status"`
+}
+
+// RemoteLinkIcon is an icon displayed next to a remote link.
+type RemoteLinkIcon struct {
+	URL16x16 string `json:"url16x16,omitempty"`
+	Title    string `json:"title,omitempty"`
+}
+
+// RemoteLinkStatus is the state of the object a remote link points to.
+// Resolved objects are shown struck through.
+type RemoteLinkStatus struct {
+	Resolved bool           `json:"resolved"`
+	Icon     RemoteLinkIcon `json:"icon"`
+}
+
+// ListRemoteLinks returns the remote links of a JIRA issue (identified by its key).
+func ListRemoteLinks(j Client, timeout time.Duration, key string) ([]RemoteLink, error) {
+	log := j.getLogger()
+
+	l, res, err := utils.Retry(withAction(log, "list_remote_links"), timeout, func() (interface{}, interface{}, error) {
+		links := new([]RemoteLink)
+		url := fmt.Sprintf("rest/api/2/issue/%s/remotelink", key)
+		res, err := j.do("GET", url, nil, links)
+		return links, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving remote links of JIRA issue %s: %v", key, err)
+		return nil, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	links, ok := l.(*[]RemoteLink)
+	if !ok {
+		log.Errorf("Get JIRA remote links did not return remote links! Got: %v", l)
+		return nil, fmt.Errorf("get JIRA remote links failed: expected *[]RemoteLink; got %T", l)
+	}
+
+	return *links, nil
+}
+
+// SetRemoteLink creates a remote link on a JIRA issue (identified by its key),
+// or updates the remote link with the same global ID if there is one.
+func SetRemoteLink(j Client, timeout time.Duration, key string, link RemoteLink) error {
+	log := j.getLogger()
+
+	_, res, err := utils.Retry(withAction(log, "set_remote_link"), timeout, func() (interface{}, interface{}, error) {
+		res, err := j.addRemoteLink(key, &link)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error setting remote link %s on JIRA issue %s: %v", link.GlobalID, key, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
@@ -0,0 +1,293 @@
+package issuesyncjira
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+// commentURLRegex matches the URLs of the version 2 API endpoints which create
+// and update comments, which UpdateComment and CreateNote build themselves.
+var commentURLRegex = regexp.MustCompile(`^rest/api/2/(issue/[^/]+/comment(?:/[^/]+)?)$`)
+
+// searchPageSize is the number of issues requested per page of search results.
+const searchPageSize = 100
+
+// serverInfo is the part of the response of the JIRA serverInfo endpoint we use.
+type serverInfo struct {
+	DeploymentType string `json:"deploymentType"`
+}
+
+// v3JIRAClient is an implementation of Client which uses version 3 of the
+// JIRA REST API for the requests that carry rich text, which JIRA Cloud
+// represents as Atlassian Document Format documents there. Descriptions,
+// comments and multi-line text fields are converted from wiki markup to ADF
+// when sent, and back when received, so the rest of issue-sync works with
+// strings as it does with version 2. All other requests are the same as the
+// realJIRAClient's.
+type v3JIRAClient struct {
+	realJIRAClient
+
+	// richTextFields are the IDs of the multi-line text custom fields, which
+	// also take ADF documents.
+	richTextFields []string
+}
+
+// dryrunV3JIRAClient is the dry-run counterpart of v3JIRAClient: it reads
+// issues with version 3 of the API, and performs no unsafe requests.
+type dryrunV3JIRAClient struct {
+	dryrunJIRAClient
+}
+
+func (j v3JIRAClient) searchIssues(jql string) (interface{}, *jira.Response, error) {
+	return searchIssuesV3(j.client, jql)
+}
+
+func (j v3JIRAClient) getIssue(key string) (*jira.Issue, *jira.Response, error) {
+	return getIssueV3(j.client, key)
+}
+
+func (j v3JIRAClient) createIssue(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
+	body, err := j.issueToV3(issue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created := new(jira.Issue)
+	res, err := j.realJIRAClient.do("POST", "rest/api/3/issue", body, created)
+	if err != nil {
+		return nil, res, jira.NewJiraError(res, err)
+	}
+	return created, res, nil
+}
+
+func (j v3JIRAClient) updateIssue(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
+	body, err := j.issueToV3(issue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := j.realJIRAClient.do("PUT", fmt.Sprintf("rest/api/3/issue/%s", issue.Key), body, nil)
+	if err != nil {
+		return nil, res, jira.NewJiraError(res, err)
+	}
+
+	// Like go-jira, return a copy of the issue rather than fetching it again.
+	ret := *issue
+	return &ret, res, nil
+}
+
+func (j v3JIRAClient) addComment(id string, jComment *jira.Comment, jIssue *jira.Issue, ghComment *github.IssueComment, ghUser *github.User) (*jira.Comment, *jira.Response, error) {
+	body := map[string]interface{}{"body": wikiToADF(jComment.Body)}
+
+	var raw map[string]interface{}
+	res, err := j.realJIRAClient.do("POST", fmt.Sprintf("rest/api/3/issue/%s/comment", id), body, &raw)
+	if err != nil {
+		return nil, res, jira.NewJiraError(res, err)
+	}
+
+	comment := new(jira.Comment)
+	if err := convertFromV3(raw, comment); err != nil {
+		return nil, res, err
+	}
+	return comment, res, nil
+}
+
+// do sends requests to the comment endpoints to version 3 of the API, with the
+// comment body converted to ADF; other requests are sent as they are.
+func (j v3JIRAClient) do(method string, url string, body interface{}, out interface{}) (*jira.Response, error) {
+	if match := commentURLRegex.FindStringSubmatch(url); match != nil && (method == "POST" || method == "PUT") {
+		var fields map[string]interface{}
+		if err := convert(body, &fields); err != nil {
+			return nil, err
+		}
+		if text, ok := fields["body"].(string); ok {
+			fields["body"] = wikiToADF(text)
+		}
+		return j.realJIRAClient.do(method, "rest/api/3/"+match[1], fields, out)
+	}
+	return j.realJIRAClient.do(method, url, body, out)
+}
+
+// issueToV3 converts an issue to the JSON object version 3 of the API takes,
+// with its description and multi-line text fields as ADF documents.
+func (j v3JIRAClient) issueToV3(issue *jira.Issue) (map[string]interface{}, error) {
+	var body map[string]interface{}
+	if err := convert(issue, &body); err != nil {
+		return nil, err
+	}
+
+	fields, ok := body["fields"].(map[string]interface{})
+	if !ok {
+		return body, nil
+	}
+	for _, id := range append([]string{"description"}, j.richTextFields...) {
+		if text, ok := fields[id].(string); ok {
+			fields[id] = wikiToADF(text)
+		}
+	}
+	return body, nil
+}
+
+func (j dryrunV3JIRAClient) searchIssues(jql string) (interface{}, *jira.Response, error) {
+	return searchIssuesV3(j.client, jql)
+}
+
+func (j dryrunV3JIRAClient) getIssue(key string) (*jira.Issue, *jira.Response, error) {
+	return getIssueV3(j.client, key)
+}
+
+// searchIssuesV3 searches for issues with the enhanced JQL search of version 3
+// of the API, converting their rich text to wiki markup. It follows
+// nextPageToken until the last page, and asks for the navigable fields, which
+// the deprecated search endpoint returned by default.
+func searchIssuesV3(client jira.Client, jql string) ([]jira.Issue, *jira.Response, error) {
+	var issues []jira.Issue
+	var res *jira.Response
+	pageToken := ""
+	for {
+		query := url.Values{}
+		query.Set("jql", jql)
+		query.Set("fields", "*navigable")
+		query.Set("maxResults", fmt.Sprint(searchPageSize))
+		if pageToken != "" {
+			query.Set("nextPageToken", pageToken)
+		}
+		req, err := client.NewRequest("GET", "rest/api/3/search/jql?"+query.Encode(), nil)
+		if err != nil {
+			return nil, res, err
+		}
+
+		var result struct {
+			Issues        []map[string]interface{} `json:"issues"`
+			NextPageToken string                   `json:"nextPageToken"`
+			IsLast        bool                     `json:"isLast"`
+		}
+		res, err = client.Do(req, &result)
+		if err != nil {
+			return nil, res, jira.NewJiraError(res, err)
+		}
+
+		for _, raw := range result.Issues {
+			var issue jira.Issue
+			if err := convertFromV3(raw, &issue); err != nil {
+				return nil, res, err
+			}
+			issues = append(issues, issue)
+		}
+
+		if result.IsLast || result.NextPageToken == "" {
+			return issues, res, nil
+		}
+		pageToken = result.NextPageToken
+	}
+}
+
+// getIssueV3 gets an issue with version 3 of the API, converting its rich text
+// to wiki markup.
+func getIssueV3(client jira.Client, key string) (*jira.Issue, *jira.Response, error) {
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/3/issue/%s", key), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]interface{}
+	res, err := client.Do(req, &raw)
+	if err != nil {
+		return nil, res, jira.NewJiraError(res, err)
+	}
+
+	issue := new(jira.Issue)
+	if err := convertFromV3(raw, issue); err != nil {
+		return nil, res, err
+	}
+	return issue, res, nil
+}
+
+// convertFromV3 replaces the ADF documents in an issue or comment returned by
+// version 3 of the API with wiki markup, then decodes it into out.
+func convertFromV3(raw map[string]interface{}, out interface{}) error {
+	replaceADF(raw)
+	if fields, ok := raw["fields"].(map[string]interface{}); ok {
+		replaceADF(fields)
+		if comments, ok := fields["comment"].(map[string]interface{}); ok {
+			list, _ := comments["comments"].([]interface{})
+			for _, c := range list {
+				if comment, ok := c.(map[string]interface{}); ok {
+					replaceADF(comment)
+				}
+			}
+		}
+	}
+	return convert(raw, out)
+}
+
+// replaceADF replaces each value of an object which is an ADF document with
+// its wiki markup.
+func replaceADF(object map[string]interface{}) {
+	for key, value := range object {
+		if doc, ok := decodeADF(value); ok {
+			object[key] = adfToWiki(doc)
+		}
+	}
+}
+
+// convert converts a value to another type by way of its JSON representation.
+func convert(in interface{}, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// getAPIVersion returns the version of the JIRA REST API to use: the one
+// configured, or, if it's `auto`, version 3 for JIRA Cloud and version 2
+// otherwise, according to the server info.
+func getAPIVersion(config cfg.Config, client jira.Client, log logrus.Entry) string {
+	version := config.GetJIRAAPIVersion()
+	if version != cfg.JIRAAPIVersionAuto {
+		return version
+	}
+
+	req, err := client.NewRequest("GET", "rest/api/2/serverInfo", nil)
+	if err != nil {
+		log.Warnf("Unable to detect the JIRA API version; using version 2: %v", err)
+		return cfg.JIRAAPIVersion2
+	}
+	var info serverInfo
+	if res, err := client.Do(req, &info); err != nil {
+		log.Warnf("Unable to detect the JIRA API version; using version 2: %v", jira.NewJiraError(res, err))
+		return cfg.JIRAAPIVersion2
+	}
+
+	if strings.EqualFold(info.DeploymentType, "Cloud") {
+		log.Debug("JIRA Cloud detected; using version 3 of the JIRA API")
+		return cfg.JIRAAPIVersion3
+	}
+	log.Debugf("JIRA %s detected; using version 2 of the JIRA API", info.DeploymentType)
+	return cfg.JIRAAPIVersion2
+}
+
+// getRichTextFields returns the IDs of the multi-line text custom fields.
+func getRichTextFields(client jira.Client) ([]string, error) {
+	fields, err := cfg.GetJIRAFields(client)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, f := range fields {
+		if strings.HasSuffix(f.Schema.Custom, ":textarea") {
+			ids = append(ids, f.ID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,52 @@
+package issuesyncjira
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+func TestSearchIssuesV3(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rest/api/3/search/jql" {
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query := r.URL.Query()
+		if query.Get("jql") != "project = SYNC" || query.Get("fields") != "*navigable" {
+			t.Errorf("Unexpected query %v", query)
+		}
+
+		switch query.Get("nextPageToken") {
+		case "":
+			fmt.Fprint(w, `{"nextPageToken": "page-2", "issues": [{"key": "SYNC-1", "fields": {"description": {"type": "doc", "version": 1, "content": [
+				{"type": "paragraph", "content": [{"type": "text", "text": "First"}]}
+			]}}}]}`)
+		case "page-2":
+			fmt.Fprint(w, `{"isLast": true, "issues": [{"key": "SYNC-2", "fields": {"summary": "Second"}}]}`)
+		default:
+			t.Errorf("Unexpected page token %q", query.Get("nextPageToken"))
+		}
+	}))
+	defer server.Close()
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issues, _, err := searchIssuesV3(*client, "project = SYNC")
+	if err != nil {
+		t.Fatalf("searchIssuesV3 failed with error: %v", err)
+	}
+	if len(issues) != 2 || issues[0].Key != "SYNC-1" || issues[1].Key != "SYNC-2" {
+		t.Fatalf("Expected the issues of both pages; got %+v", issues)
+	}
+	if issues[0].Fields.Description != "First" {
+		t.Errorf("Expected the description to be converted to wiki markup; got %q", issues[0].Fields.Description)
+	}
+}
@@ -0,0 +1,113 @@
+package issuesyncjira
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// ListVersions returns all of the versions (releases) defined in the
+// given JIRA project.
+func ListVersions(j Client, timeout time.Duration, projectKey string) ([]jira.Version, error) {
+	log := j.getLogger()
+
+	v, res, err := utils.Retry(withAction(log, "list_versions"), timeout, func() (interface{}, interface{}, error) {
+		versions := new([]jira.Version)
+		url := fmt.Sprintf("rest/api/2/project/%s/versions", projectKey)
+		res, err := j.do("GET", url, nil, versions)
+		return versions, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving JIRA versions: %v", err)
+		return nil, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	versions, ok := v.(*[]jira.Version)
+	if !ok {
+		log.Errorf("Get JIRA versions did not return versions! Got: %v", v)
+		return nil, fmt.Errorf("get JIRA versions failed: expected *[]jira.Version; got %T", v)
+	}
+
+	return *versions, nil
+}
+
+// CreateVersion creates a new version in a JIRA project, and returns it
+// as it exists on JIRA.
+func CreateVersion(j Client, timeout time.Duration, version jira.Version) (jira.Version, error) {
+	log := j.getLogger()
+
+	v, res, err := utils.Retry(withAction(log, "create_version"), timeout, func() (interface{}, interface{}, error) {
+		return j.createVersion(&version)
+	})
+	if err != nil {
+		log.Errorf("Error creating JIRA version %s: %v", version.Name, err)
+		return jira.Version{}, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	ve, ok := v.(*jira.Version)
+	if !ok {
+		log.Errorf("Create JIRA version did not return version! Got: %v", v)
+		return jira.Version{}, fmt.Errorf("create JIRA version failed: expected *jira.Version; got %T", v)
+	}
+
+	return *ve, nil
+}
+
+// UpdateVersion updates a JIRA version (identified by its ID) with the fields
+// of the provided version, and returns it as it exists on JIRA.
+func UpdateVersion(j Client, timeout time.Duration, version jira.Version) (jira.Version, error) {
+	log := j.getLogger()
+
+	v, res, err := utils.Retry(withAction(log, "update_version"), timeout, func() (interface{}, interface{}, error) {
+		return j.updateVersion(&version)
+	})
+	if err != nil {
+		log.Errorf("Error updating JIRA version %s: %v", version.Name, err)
+		return jira.Version{}, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	ve, ok := v.(*jira.Version)
+	if !ok {
+		log.Errorf("Update JIRA version did not return version! Got: %v", v)
+		return jira.Version{}, fmt.Errorf("update JIRA version failed: expected *jira.Version; got %T", v)
+	}
+
+	return *ve, nil
+}
+
+// ListSprints returns the active and future sprints of a JIRA Agile board.
+// Closed sprints are not returned, as issues can't be moved into them.
+func ListSprints(j Client, timeout time.Duration, boardID int) ([]jira.Sprint, error) {
+	log := j.getLogger()
+
+	s, res, err := utils.Retry(withAction(log, "list_sprints"), timeout, func() (interface{}, interface{}, error) {
+		return j.getSprints(boardID)
+	})
+	if err != nil {
+		log.Errorf("Error retrieving sprints of JIRA board %d: %v", boardID, err)
+		return nil, getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+	sprints, ok := s.([]jira.Sprint)
+	if !ok {
+		log.Errorf("Get JIRA sprints did not return sprints! Got: %v", s)
+		return nil, fmt.Errorf("get JIRA sprints failed: expected []jira.Sprint; got %T", s)
+	}
+
+	return sprints, nil
+}
+
+// MoveIssueToSprint moves a JIRA issue (identified by its key) into the
+// given sprint. Moving an issue into the sprint it is already in is a no-op.
+func MoveIssueToSprint(j Client, timeout time.Duration, sprintID int, key string) error {
+	log := j.getLogger()
+
+	_, res, err := utils.Retry(withAction(log, "move_issue_to_sprint"), timeout, func() (interface{}, interface{}, error) {
+		res, err := j.moveIssuesToSprint(sprintID, []string{key})
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error moving JIRA issue %s to sprint %d: %v", key, sprintID, err)
+		return getErrorBody(j.getLogger(), res.(*jira.Response))
+	}
+
+	return nil
+}
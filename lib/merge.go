@@ -0,0 +1,93 @@
+package lib
+
+import (
+	"strings"
+
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// merge3 performs a line-based three-way merge of two texts, `ours` and `theirs`,
+// which were both derived from `base`. It returns the merged text, and false if
+// both texts changed the same region of `base` differently.
+func merge3(base string, ours string, theirs string) (string, bool) {
+	baseLines := strings.Split(base, "\n")
+	ourLines := strings.Split(ours, "\n")
+	theirLines := strings.Split(theirs, "\n")
+
+	ourMatches := matchLines(baseLines, ourLines)
+	theirMatches := matchLines(baseLines, theirLines)
+
+	var merged []string
+	b, o, t := 0, 0, 0
+	for {
+		// Find the next base line which is unchanged in both texts; everything
+		// before it is a chunk which may have been changed on either side.
+		next := b
+		for next < len(baseLines) && (ourMatches[next] < 0 || theirMatches[next] < 0) {
+			next++
+		}
+
+		ourEnd, theirEnd := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			ourEnd, theirEnd = ourMatches[next], theirMatches[next]
+		}
+
+		baseChunk, ourChunk, theirChunk := baseLines[b:next], ourLines[o:ourEnd], theirLines[t:theirEnd]
+		switch {
+		case utils.SliceStringsEq(ourChunk, baseChunk):
+			merged = append(merged, theirChunk...)
+		case utils.SliceStringsEq(theirChunk, baseChunk), utils.SliceStringsEq(ourChunk, theirChunk):
+			merged = append(merged, ourChunk...)
+		default:
+			return "", false
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[next])
+		b, o, t = next+1, ourEnd+1, theirEnd+1
+	}
+
+	return strings.Join(merged, "\n"), true
+}
+
+// matchLines computes a longest common subsequence of two lists of lines. It
+// returns, for each line of `a`, the index of the matching line of `b`, or -1 if
+// the line isn't part of the subsequence.
+func matchLines(a []string, b []string) []int {
+	// lengths[i][j] is the length of the LCS of a[i:] and b[j:]
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	matches := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			matches[i] = j
+			i++
+			j++
+		case j < len(b) && lengths[i][j+1] > lengths[i+1][j]:
+			j++
+		default:
+			matches[i] = -1
+			i++
+		}
+	}
+
+	return matches
+}
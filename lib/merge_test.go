@@ -0,0 +1,32 @@
+package lib
+
+import (
+	"testing"
+)
+
+func TestMerge3CombinesDisjointChanges(t *testing.T) {
+	base := "one\ntwo\nthree\nfour"
+	ours := "one\n2\nthree\nfour"
+	theirs := "one\ntwo\nthree\nfour\nfive"
+
+	merged, ok := merge3(base, ours, theirs)
+	if !ok {
+		t.Fatalf("Expected disjoint changes to merge")
+	}
+	if merged != "one\n2\nthree\nfour\nfive" {
+		t.Fatalf("Expected merged = one\\n2\\nthree\\nfour\\nfive; Got merged = %q", merged)
+	}
+}
+
+func TestMerge3AcceptsIdenticalChanges(t *testing.T) {
+	merged, ok := merge3("one\ntwo", "one\n2", "one\n2")
+	if !ok || merged != "one\n2" {
+		t.Fatalf("Expected merged = one\\n2; Got merged = %q (ok = %t)", merged, ok)
+	}
+}
+
+func TestMerge3DetectsConflicts(t *testing.T) {
+	if _, ok := merge3("one\ntwo\nthree", "one\n2\nthree", "one\nTWO\nthree"); ok {
+		t.Fatalf("Expected overlapping changes to conflict")
+	}
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// jiraVersionDateFormat is the format JIRA uses for version release dates.
+const jiraVersionDateFormat = "2006-01-02"
+
+// milestoneChanged returns whether the fixVersions of the JIRA issue differ
+// from the milestone of the GitHub issue, in a way applyMilestone can fix: a
+// milestone without a matching JIRA version isn't a change, unless the version
+// is created. It is always false unless milestones are mapped to fixVersions,
+// and fixVersions aren't synced back to GitHub.
+func milestoneChanged(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, jClient issuesyncjira.Client) (bool, error) {
+	if config.GetMilestoneMapping() != cfg.MilestoneToFixVersion || config.ReverseSyncs(cfg.ReverseSyncFixVersion) {
+		return false, nil
+	}
+
+	if ghIssue.Milestone == nil {
+		return len(jIssue.Fields.FixVersions) != 0, nil
+	}
+
+	if len(jIssue.Fields.FixVersions) == 1 && jIssue.Fields.FixVersions[0].Name == ghIssue.Milestone.GetTitle() {
+		return false, nil
+	}
+	if config.CreateFixVersions() {
+		return true, nil
+	}
+
+	versions, err := issuesyncjira.ListVersions(jClient, config.GetTimeout(), config.GetProjectKey())
+	if err != nil {
+		return false, err
+	}
+	for _, v := range versions {
+		if v.Name == ghIssue.Milestone.GetTitle() {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// applyMilestone sets the fixVersions of the JIRA issue fields to the version
+// matching the milestone of the GitHub issue, creating or updating the version
+// if configured. If the issue has no milestone, the fixVersions are cleared. The
+// fixVersions are left alone if they are synced back to GitHub instead.
+func applyMilestone(config cfg.Config, ghIssue models.ExtendedGithubIssue, fields *jira.IssueFields, jClient issuesyncjira.Client) error {
+	log := config.GetLogger()
+
+	if config.GetMilestoneMapping() != cfg.MilestoneToFixVersion || config.ReverseSyncs(cfg.ReverseSyncFixVersion) {
+		return nil
+	}
+
+	if ghIssue.Milestone == nil {
+		fields.Unknowns["fixVersions"] = []interface{}{}
+		return nil
+	}
+
+	version, found, err := ensureFixVersion(config, *ghIssue.Milestone, jClient)
+	if err != nil {
+		return err
+	}
+	if !found {
+		log.Warnf("JIRA version %s does not exist; not setting fixVersion of GitHub #%d", ghIssue.Milestone.GetTitle(), ghIssue.GetNumber())
+		return nil
+	}
+
+	fields.FixVersions = []*jira.FixVersion{{ID: version.ID, Name: version.Name}}
+
+	return nil
+}
+
+// ensureFixVersion finds the JIRA version named after a GitHub milestone. If it
+// exists, its release date and released state are updated to match the milestone;
+// if it doesn't and `create-fix-versions` is set, it is created. The returned bool
+// is false if no version exists.
+func ensureFixVersion(config cfg.Config, milestone github.Milestone, jClient issuesyncjira.Client) (jira.Version, bool, error) {
+	log := config.GetLogger()
+
+	versions, err := issuesyncjira.ListVersions(jClient, config.GetTimeout(), config.GetProjectKey())
+	if err != nil {
+		return jira.Version{}, false, err
+	}
+
+	releaseDate := ""
+	if milestone.DueOn != nil {
+		releaseDate = milestone.DueOn.Format(jiraVersionDateFormat)
+	}
+	released := milestone.GetState() == "closed"
+
+	for _, v := range versions {
+		if v.Name != milestone.GetTitle() {
+			continue
+		}
+
+		if v.ReleaseDate == releaseDate && v.Released == released {
+			return v, true, nil
+		}
+
+		log.Debugf("Updating JIRA version %s with GitHub milestone %d", v.Name, milestone.GetNumber())
+
+		v.ReleaseDate = releaseDate
+		v.Released = released
+		v, err = issuesyncjira.UpdateVersion(jClient, config.GetTimeout(), v)
+		if err != nil {
+			return jira.Version{}, false, err
+		}
+		return v, true, nil
+	}
+
+	if !config.CreateFixVersions() {
+		return jira.Version{}, false, nil
+	}
+
+	log.Debugf("Creating JIRA version based on GitHub milestone %d", milestone.GetNumber())
+
+	projectID, err := strconv.Atoi(config.GetProject().ID)
+	if err != nil {
+		return jira.Version{}, false, err
+	}
+
+	v, err := issuesyncjira.CreateVersion(jClient, config.GetTimeout(), jira.Version{
+		Name:        milestone.GetTitle(),
+		Description: milestone.GetDescription(),
+		ReleaseDate: releaseDate,
+		Released:    released,
+		ProjectID:   projectID,
+	})
+	if err != nil {
+		return jira.Version{}, false, err
+	}
+
+	return v, true, nil
+}
+
+// syncMilestoneSprint moves the JIRA issue into the sprint of the configured board
+// which has the same name as the milestone of the GitHub issue. Nothing is done
+// unless milestones are mapped to sprints, or if no matching sprint is open.
+func syncMilestoneSprint(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, jClient issuesyncjira.Client) error {
+	log := config.GetLogger()
+
+	if config.GetMilestoneMapping() != cfg.MilestoneToSprint || ghIssue.Milestone == nil {
+		return nil
+	}
+
+	sprints, err := issuesyncjira.ListSprints(jClient, config.GetTimeout(), config.GetJIRABoardID())
+	if err != nil {
+		return err
+	}
+
+	for _, s := range sprints {
+		if !strings.EqualFold(s.Name, ghIssue.Milestone.GetTitle()) {
+			continue
+		}
+		return issuesyncjira.MoveIssueToSprint(jClient, config.GetTimeout(), s.ID, jIssue.Key)
+	}
+
+	log.Debugf("No open sprint named %s on board %d; not moving JIRA issue %s", ghIssue.Milestone.GetTitle(), config.GetJIRABoardID(), jIssue.Key)
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// newTestMilestoneClient returns a JIRA client whose project has the given
+// versions, and which counts the versions it updates.
+func newTestMilestoneClient(config cfg.Config, versions []jira.Version, updated *int) issuesyncjira.Client {
+	client := issuesyncjira.NewTestClient()
+	client.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	client.HandleDo = func(method string, url string, body interface{}, out interface{}) (*jira.Response, error) {
+		*out.(*[]jira.Version) = versions
+		return &jira.Response{}, nil
+	}
+	client.HandleUpdateVersion = func(version *jira.Version) (*jira.Version, *jira.Response, error) {
+		*updated++
+		return version, &jira.Response{}, nil
+	}
+	return client
+}
+
+func newTestMilestoneIssue(milestone string) models.ExtendedGithubIssue {
+	ghIssue := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(1)}}
+	if milestone != "" {
+		ghIssue.Milestone = &github.Milestone{Title: github.String(milestone), State: github.String("open")}
+	}
+	return ghIssue
+}
+
+func TestMilestoneChanged(t *testing.T) {
+	versions := []jira.Version{{ID: "10", Name: "v1.0"}, {ID: "11", Name: "v2.0"}}
+
+	tests := []struct {
+		name              string
+		mapping           string
+		createFixVersions bool
+		milestone         string
+		fixVersions       []string
+		expected          bool
+	}{
+		{name: "not mapped", mapping: "", milestone: "v2.0", fixVersions: []string{"v1.0"}, expected: false},
+		{name: "same version", mapping: cfg.MilestoneToFixVersion, milestone: "v1.0", fixVersions: []string{"v1.0"}, expected: false},
+		{name: "other version", mapping: cfg.MilestoneToFixVersion, milestone: "v2.0", fixVersions: []string{"v1.0"}, expected: true},
+		{name: "milestone removed", mapping: cfg.MilestoneToFixVersion, milestone: "", fixVersions: []string{"v1.0"}, expected: true},
+		{name: "no milestone", mapping: cfg.MilestoneToFixVersion, milestone: "", expected: false},
+		{name: "missing version", mapping: cfg.MilestoneToFixVersion, milestone: "v3.0", expected: false},
+		{name: "missing version created", mapping: cfg.MilestoneToFixVersion, createFixVersions: true, milestone: "v3.0", expected: true},
+	}
+
+	for _, test := range tests {
+		config := cfg.NewConfigFromSettings(map[string]interface{}{
+			"log-level":           "error",
+			"milestone-mapping":   test.mapping,
+			"create-fix-versions": test.createFixVersions,
+		})
+		var updated int
+		client := newTestMilestoneClient(config, versions, &updated)
+
+		jIssue := jira.Issue{Fields: &jira.IssueFields{}}
+		for _, name := range test.fixVersions {
+			jIssue.Fields.FixVersions = append(jIssue.Fields.FixVersions, &jira.FixVersion{Name: name})
+		}
+
+		changed, err := milestoneChanged(config, newTestMilestoneIssue(test.milestone), jIssue, client)
+		if err != nil {
+			t.Fatalf("%s: milestoneChanged failed with error: %v", test.name, err)
+		}
+		if changed != test.expected {
+			t.Errorf("%s: Expected changed = %t; Got changed = %t", test.name, test.expected, changed)
+		}
+	}
+}
+
+func TestApplyMilestone(t *testing.T) {
+	versions := []jira.Version{{ID: "10", Name: "v1.0"}, {ID: "11", Name: "v2.0", Released: true}}
+
+	tests := []struct {
+		name       string
+		milestone  string
+		expectedID string
+		cleared    bool
+		updated    int
+	}{
+		{name: "existing version", milestone: "v1.0", expectedID: "10"},
+		{name: "released version reopened", milestone: "v2.0", expectedID: "11", updated: 1},
+		{name: "missing version", milestone: "v3.0"},
+		{name: "no milestone", milestone: "", cleared: true},
+	}
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":         "error",
+		"milestone-mapping": cfg.MilestoneToFixVersion,
+	})
+	for _, test := range tests {
+		var updated int
+		client := newTestMilestoneClient(config, versions, &updated)
+
+		fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+		if err := applyMilestone(config, newTestMilestoneIssue(test.milestone), &fields, client); err != nil {
+			t.Fatalf("%s: applyMilestone failed with error: %v", test.name, err)
+		}
+
+		id := ""
+		if len(fields.FixVersions) == 1 {
+			id = fields.FixVersions[0].ID
+		}
+		if id != test.expectedID {
+			t.Errorf("%s: Expected fixVersion %q; Got %q", test.name, test.expectedID, id)
+		}
+		if _, cleared := fields.Unknowns["fixVersions"]; cleared != test.cleared {
+			t.Errorf("%s: Expected fixVersions cleared = %t; Got %t", test.name, test.cleared, cleared)
+		}
+		if updated != test.updated {
+			t.Errorf("%s: Expected %d versions updated; Got %d", test.name, test.updated, updated)
+		}
+	}
+}
+
+func TestSyncMilestoneSprint(t *testing.T) {
+	tests := []struct {
+		name      string
+		mapping   string
+		milestone string
+		moved     int
+	}{
+		{name: "matching sprint", mapping: cfg.MilestoneToSprint, milestone: "Sprint 4", moved: 4},
+		{name: "matching sprint ignoring case", mapping: cfg.MilestoneToSprint, milestone: "sprint 5", moved: 5},
+		{name: "no matching sprint", mapping: cfg.MilestoneToSprint, milestone: "Sprint 9"},
+		{name: "no milestone", mapping: cfg.MilestoneToSprint, milestone: ""},
+		{name: "not mapped", mapping: cfg.MilestoneToFixVersion, milestone: "Sprint 4"},
+	}
+
+	for _, test := range tests {
+		config := cfg.NewConfigFromSettings(map[string]interface{}{
+			"log-level":         "error",
+			"milestone-mapping": test.mapping,
+			"jira-board-id":     42,
+		})
+
+		moved := 0
+		client := issuesyncjira.NewTestClient()
+		client.HandleGetLogger = func() logrus.Entry {
+			return config.GetLogger()
+		}
+		client.HandleGetSprints = func(boardID int) ([]jira.Sprint, *jira.Response, error) {
+			if boardID != 42 {
+				t.Errorf("%s: Expected the sprints of board 42; Got board %d", test.name, boardID)
+			}
+			return []jira.Sprint{{ID: 4, Name: "Sprint 4"}, {ID: 5, Name: "Sprint 5"}}, &jira.Response{}, nil
+		}
+		client.HandleMoveIssuesToSprint = func(sprintID int, issueKeys []string) (*jira.Response, error) {
+			moved = sprintID
+			return &jira.Response{}, nil
+		}
+
+		jIssue := jira.Issue{Key: "SYNC-1", Fields: &jira.IssueFields{}}
+		if err := syncMilestoneSprint(config, newTestMilestoneIssue(test.milestone), jIssue, client); err != nil {
+			t.Fatalf("%s: syncMilestoneSprint failed with error: %v", test.name, err)
+		}
+		if moved != test.moved {
+			t.Errorf("%s: Expected the issue moved to sprint %d; Got sprint %d", test.name, test.moved, moved)
+		}
+	}
+}
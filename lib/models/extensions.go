@@ -8,4 +8,25 @@ type ExtendedGithubIssue struct {
 	github.Issue
 	ProjectCard *github.ProjectCard
 	CommitIds []string
+	Commits []Commit
+	PullRequests []PullRequest
+}
+
+// Commit is a GitHub commit which references an issue.
+type Commit struct {
+	Repo    string
+	SHA     string
+	Message string
+	Author  string
+	URL     string
+}
+
+// PullRequest is a GitHub pull request which references an issue.
+type PullRequest struct {
+	Repo   string
+	Number int
+	Title  string
+	URL    string
+	State  string
+	Merged bool
 }
\ No newline at end of file
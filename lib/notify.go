@@ -0,0 +1,364 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// runProblemKey is the key of the problem of a run which stopped early.
+const runProblemKey = "run"
+
+// Problem is something which went wrong in a run, and which notifications are
+// sent about.
+type Problem struct {
+	// Key identifies the problem across runs, e.g. update_issue#42.
+	Key          string `json:"key"`
+	GitHubNumber int    `json:"gh_number,omitempty"`
+	JIRAKey      string `json:"jira_key,omitempty"`
+	Action       string `json:"action"`
+	Error        string `json:"error"`
+	// Runs is how many runs in a row the problem occurred in.
+	Runs int `json:"runs"`
+}
+
+// Notification is the JSON body posted to `notify-webhook-url`.
+type Notification struct {
+	RunID      string    `json:"run_id"`
+	Repo       string    `json:"repo"`
+	Failures   []Problem `json:"failures"`
+	Recoveries []Problem `json:"recoveries"`
+	// Text is a human-readable summary, which is also what is posted to Slack.
+	Text string `json:"text"`
+}
+
+// Names of the webhooks notifications are sent to.
+const (
+	sinkWebhook = "webhook"
+	sinkSlack   = "slack"
+)
+
+// problemState is what the notifier remembers about a problem.
+type problemState struct {
+	Problem Problem `json:"problem"`
+	// NotifiedAt is when the problem was last notified to each webhook, by name.
+	NotifiedAt map[string]time.Time `json:"notified_at,omitempty"`
+}
+
+// notifierState is what the notifier remembers across runs, and saves to the
+// `notify-state-file`.
+type notifierState struct {
+	Problems map[string]*problemState `json:"problems"`
+	// Sent are the times notifications were sent in the last hour.
+	Sent []time.Time `json:"sent"`
+}
+
+// notifySink is a webhook notifications are sent to.
+type notifySink struct {
+	name string
+	url  string
+	// body returns the JSON body the webhook takes for a notification.
+	body func(notification Notification) interface{}
+}
+
+// Notifier posts the failures of runs, and their recoveries, to webhooks. A
+// problem is only notified once to each webhook, unless it persists for the
+// repeat interval; issues are only notified once they failed in several runs in
+// a row; and at most one notification is sent per run, within an hourly limit.
+type Notifier struct {
+	state     notifierState
+	statePath string
+	loaded    bool
+
+	now func() time.Time
+}
+
+// NewNotifier creates a notifier, which remembers the problems it notified
+// about between runs.
+func NewNotifier() *Notifier {
+	return &Notifier{
+		state: notifierState{Problems: map[string]*problemState{}},
+		now:   time.Now,
+	}
+}
+
+// Notify sends a notification about the new problems and recoveries of a run,
+// if any, to each of the configured webhooks. What each webhook was notified of
+// is remembered separately, so that a webhook which failed is notified again by
+// the next run, without notifying the others again.
+func (n *Notifier) Notify(config cfg.Config, report RunReport) error {
+	var sinks []notifySink
+	if url := config.GetNotifyWebhookURL(); url != "" {
+		sinks = append(sinks, notifySink{name: sinkWebhook, url: url, body: func(notification Notification) interface{} {
+			return notification
+		}})
+	}
+	if url := config.GetNotifySlackURL(); url != "" {
+		sinks = append(sinks, notifySink{name: sinkSlack, url: url, body: func(notification Notification) interface{} {
+			return map[string]string{"text": notification.Text}
+		}})
+	}
+	if len(sinks) == 0 {
+		return nil
+	}
+
+	if err := n.load(config.GetNotifyStateFile()); err != nil {
+		return err
+	}
+
+	now := n.now()
+
+	var failing []Problem
+	seen := map[string]bool{}
+	for _, p := range problemsOf(report) {
+		seen[p.Key] = true
+		s, ok := n.state.Problems[p.Key]
+		if !ok {
+			s = &problemState{}
+			n.state.Problems[p.Key] = s
+		}
+		p.Runs = s.Problem.Runs + 1
+		s.Problem = p
+
+		// Issues can fail once, e.g. while JIRA restarts; runs can't.
+		if p.GitHubNumber != 0 && p.Runs < config.GetNotifyIssueFailures() {
+			continue
+		}
+		failing = append(failing, p)
+	}
+
+	configured := map[string]bool{}
+	for _, sink := range sinks {
+		configured[sink.name] = true
+	}
+	synced := map[int]bool{}
+	for _, number := range report.Synced {
+		synced[number] = true
+	}
+	var recovered []Problem
+	for key, s := range n.state.Problems {
+		if seen[key] {
+			continue
+		}
+		// Issues which weren't synced in this run may still be broken.
+		if s.Problem.GitHubNumber != 0 && !synced[s.Problem.GitHubNumber] {
+			continue
+		}
+		// Webhooks which are no longer configured can't be told of the recovery.
+		for name := range s.NotifiedAt {
+			if !configured[name] {
+				delete(s.NotifiedAt, name)
+			}
+		}
+		if len(s.NotifiedAt) == 0 {
+			delete(n.state.Problems, key)
+			continue
+		}
+		recovered = append(recovered, s.Problem)
+	}
+
+	notifications := map[string]Notification{}
+	for _, sink := range sinks {
+		notification := Notification{RunID: report.RunID, Repo: report.Repo, Failures: []Problem{}, Recoveries: []Problem{}}
+		for _, p := range failing {
+			notifiedAt := n.state.Problems[p.Key].NotifiedAt[sink.name]
+			if notifiedAt.IsZero() || now.Sub(notifiedAt) >= config.GetNotifyRepeatInterval() {
+				notification.Failures = append(notification.Failures, p)
+			}
+		}
+		for _, p := range recovered {
+			if _, ok := n.state.Problems[p.Key].NotifiedAt[sink.name]; ok {
+				notification.Recoveries = append(notification.Recoveries, p)
+			}
+		}
+		if len(notification.Failures) != 0 || len(notification.Recoveries) != 0 {
+			notification.Text = notificationText(notification)
+			notifications[sink.name] = notification
+		}
+	}
+	if len(notifications) == 0 {
+		return n.save()
+	}
+
+	var sent []time.Time
+	for _, t := range n.state.Sent {
+		if now.Sub(t) < time.Hour {
+			sent = append(sent, t)
+		}
+	}
+	n.state.Sent = sent
+	if len(sent) >= config.GetNotifyMaxPerHour() {
+		// The problems are notified by the next run which isn't rate limited.
+		log := config.GetLogger()
+		log.Warnf("Not notifying the failures and recoveries of %d webhooks, as %d notifications were sent in the last hour",
+			len(notifications), len(sent))
+		return n.save()
+	}
+
+	var errs []string
+	anySent := false
+	for _, sink := range sinks {
+		notification, ok := notifications[sink.name]
+		if !ok {
+			continue
+		}
+		if err := n.post(config, sink.url, sink.body(notification)); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", sink.name, err))
+			continue
+		}
+		anySent = true
+
+		for _, p := range notification.Failures {
+			s := n.state.Problems[p.Key]
+			if s.NotifiedAt == nil {
+				s.NotifiedAt = map[string]time.Time{}
+			}
+			s.NotifiedAt[sink.name] = now
+		}
+		for _, p := range notification.Recoveries {
+			s := n.state.Problems[p.Key]
+			delete(s.NotifiedAt, sink.name)
+			if len(s.NotifiedAt) == 0 {
+				delete(n.state.Problems, p.Key)
+			}
+		}
+	}
+	if anySent {
+		n.state.Sent = append(n.state.Sent, now)
+	}
+
+	if err := n.save(); err != nil {
+		return err
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("unable to send the notification to %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// problemsOf returns the problems of a run.
+func problemsOf(report RunReport) []Problem {
+	var problems []Problem
+	if report.Error != "" {
+		problems = append(problems, Problem{Key: runProblemKey, Action: "run", Error: report.Error})
+	}
+	for _, f := range report.Failures {
+		key := f.Action
+		if f.GitHubNumber != 0 {
+			key = fmt.Sprintf("%s#%d", f.Action, f.GitHubNumber)
+		}
+		problems = append(problems, Problem{
+			Key:          key,
+			GitHubNumber: f.GitHubNumber,
+			JIRAKey:      f.JIRAKey,
+			Action:       f.Action,
+			Error:        f.Error,
+		})
+	}
+	return problems
+}
+
+// notificationText summarizes a notification for people.
+func notificationText(notification Notification) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "issue-sync %s: %d failing, %d recovered (run %s)",
+		notification.Repo, len(notification.Failures), len(notification.Recoveries), notification.RunID)
+	for _, p := range notification.Failures {
+		fmt.Fprintf(&b, "\n- %s failed", problemName(p))
+		if p.Runs > 1 {
+			fmt.Fprintf(&b, " in %d runs in a row", p.Runs)
+		}
+		fmt.Fprintf(&b, ": %s", p.Error)
+	}
+	for _, p := range notification.Recoveries {
+		fmt.Fprintf(&b, "\n- %s recovered", problemName(p))
+	}
+	return b.String()
+}
+
+// problemName describes what a problem is about.
+func problemName(p Problem) string {
+	switch {
+	case p.Key == runProblemKey:
+		return "The run"
+	case p.GitHubNumber != 0 && p.JIRAKey != "":
+		return fmt.Sprintf("%s of #%d (%s)", p.Action, p.GitHubNumber, p.JIRAKey)
+	case p.GitHubNumber != 0:
+		return fmt.Sprintf("%s of #%d", p.Action, p.GitHubNumber)
+	default:
+		return p.Action
+	}
+}
+
+// post sends a JSON body to a webhook.
+func (n *Notifier) post(config cfg.Config, url string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	transport, err := utils.NewTransport(config.GetNotifyTransportOptions())
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Transport: transport, Timeout: config.GetTimeout()}
+
+	res, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded %s", res.Status)
+	}
+	return nil
+}
+
+// load reads the state from a file the first time, or if the file changed.
+func (n *Notifier) load(path string) error {
+	if n.loaded && path == n.statePath {
+		return nil
+	}
+	n.statePath, n.loaded = path, true
+	if path == "" {
+		return nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	state := notifierState{}
+	if err := json.Unmarshal(b, &state); err != nil {
+		return fmt.Errorf("unable to read %s: %v", path, err)
+	}
+	if state.Problems == nil {
+		state.Problems = map[string]*problemState{}
+	}
+	n.state = state
+	return nil
+}
+
+// save writes the state to the `notify-state-file`, if it's set.
+func (n *Notifier) save() error {
+	if n.statePath == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(n.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(n.statePath, b, 0644)
+}
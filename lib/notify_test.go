@@ -0,0 +1,148 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestNotifier(t *testing.T) {
+	var received []Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err != nil {
+			t.Errorf("Unable to decode notification: %v", err)
+		}
+		received = append(received, notification)
+	}))
+	defer server.Close()
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":             "error",
+		"notify-webhook-url":    server.URL,
+		"notify-issue-failures": 2,
+	})
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	notifier := NewNotifier()
+	notifier.now = func() time.Time { return now }
+
+	ghIssue := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(42)}}
+	run := func(fail bool, synced bool) {
+		report := newRunReport("run", "o/r")
+		if fail {
+			report.failed("update_issue", &ghIssue, "SYNC-7", errors.New("transition failed"))
+		} else if synced {
+			report.succeeded("update_issue", &ghIssue)
+		}
+		if err := notifier.Notify(config, report); err != nil {
+			t.Fatalf("Notify failed with error: %v", err)
+		}
+		now = now.Add(time.Hour)
+	}
+
+	run(true, false)
+	if len(received) != 0 {
+		t.Fatalf("Expected an issue failing once not to be notified; got %v", received)
+	}
+
+	run(true, false)
+	if len(received) != 1 || len(received[0].Failures) != 1 || received[0].Failures[0].Runs != 2 {
+		t.Fatalf("Expected an issue failing twice to be notified; got %v", received)
+	}
+
+	run(true, false)
+	if len(received) != 1 {
+		t.Fatalf("Expected a notified failure not to be notified again; got %v", received)
+	}
+
+	run(false, false)
+	if len(received) != 1 {
+		t.Fatalf("Expected an issue which wasn't synced not to recover; got %v", received)
+	}
+
+	run(false, true)
+	if len(received) != 2 || len(received[1].Recoveries) != 1 || received[1].Recoveries[0].GitHubNumber != 42 {
+		t.Fatalf("Expected the synced issue to be notified as recovered; got %v", received)
+	}
+}
+
+func TestNotifierTracksEachWebhook(t *testing.T) {
+	webhookCalls, slackCalls := 0, 0
+	slackUp := false
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		webhookCalls++
+	}))
+	defer webhook.Close()
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		slackCalls++
+		if !slackUp {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer slack.Close()
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":          "error",
+		"notify-webhook-url": webhook.URL,
+		"notify-slack-url":   slack.URL,
+	})
+	notifier := NewNotifier()
+
+	report := newRunReport("run", "o/r")
+	report.Error = "JIRA is down"
+	if err := notifier.Notify(config, report); err == nil {
+		t.Error("Expected Notify to fail when Slack can't be reached")
+	}
+	if webhookCalls != 1 || slackCalls != 1 {
+		t.Fatalf("Expected both webhooks to be notified; got %d and %d calls", webhookCalls, slackCalls)
+	}
+
+	slackUp = true
+	if err := notifier.Notify(config, report); err != nil {
+		t.Fatalf("Notify failed with error: %v", err)
+	}
+	if webhookCalls != 1 || slackCalls != 2 {
+		t.Errorf("Expected only Slack to be notified again; got %d and %d calls", webhookCalls, slackCalls)
+	}
+
+	if err := notifier.Notify(config, newRunReport("run", "o/r")); err != nil {
+		t.Fatalf("Notify failed with error: %v", err)
+	}
+	if webhookCalls != 2 || slackCalls != 3 {
+		t.Errorf("Expected both webhooks to be notified of the recovery; got %d and %d calls", webhookCalls, slackCalls)
+	}
+	if len(notifier.state.Problems) != 0 {
+		t.Errorf("Expected the recovered problem to be forgotten; got %v", notifier.state.Problems)
+	}
+}
+
+func TestNotifierUsesTransportOptions(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Team")
+	}))
+	defer server.Close()
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":          "error",
+		"notify-webhook-url": server.URL,
+		"notify-headers":     map[string]interface{}{"X-Team": "sync"},
+	})
+
+	report := newRunReport("run", "o/r")
+	report.Error = "JIRA is down"
+	if err := NewNotifier().Notify(config, report); err != nil {
+		t.Fatalf("Notify failed with error: %v", err)
+	}
+	if header != "sync" {
+		t.Errorf("Expected the notify-headers to be sent; got X-Team %q", header)
+	}
+}
@@ -0,0 +1,194 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// issueReferenceRegex matches a reference to a GitHub issue, such as "#456" or
+// "owner/repo#789". It has matching groups for the character preceding the
+// reference (\1), the repository (\2, if it exists), and the issue number (\3).
+var issueReferenceRegex = regexp.MustCompile(`(^|[^\w/#&.-])([\w.-]+/[\w.-]+)?#(\d+)\b`)
+
+// referenceResolver rewrites references to GitHub issues into references to the
+// JIRA issues they are synced to. One is shared by a whole run, so lookups are
+// cached for the run, including misses.
+type referenceResolver struct {
+	user     string
+	repoName string
+	format   string
+	jiraURI  string
+	// githubURL is the base of the links generated for references which can't
+	// be resolved to a JIRA issue.
+	githubURL string
+	keys      map[int]string
+	lookup    func(number int) (string, bool)
+}
+
+// newReferenceResolver creates a referenceResolver which looks up the JIRA key of
+// a GitHub issue it wasn't given (see add) by retrieving the issue from GitHub,
+// then searching JIRA for its ID.
+func newReferenceResolver(config cfg.Config, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) *referenceResolver {
+	user, repoName := config.GetRepo()
+
+	return &referenceResolver{
+		user:      user,
+		repoName:  repoName,
+		format:    config.GetReferenceRewriting(),
+		jiraURI:   config.GetConfigString("jira-uri"),
+		githubURL: config.GetGitHubHTMLURL(),
+		keys:      map[int]string{},
+		lookup: func(number int) (string, bool) {
+			ghIssue, err := issuesyncgithub.GetBareIssue(ghClient, config.GetTimeout(), user, repoName, number)
+			if err != nil || ghIssue.PullRequestLinks != nil {
+				return "", false
+			}
+			jIssues, err := jiraIssuesByNumber(config, map[int]models.ExtendedGithubIssue{number: {Issue: ghIssue}}, jClient)
+			if err != nil {
+				return "", false
+			}
+			jIssue, ok := jIssues[number]
+			return jIssue.Key, ok
+		},
+	}
+}
+
+// add records the JIRA key of a GitHub issue which is already known, such as one
+// listed or created by the run, so that it isn't looked up.
+func (r *referenceResolver) add(number int, key string) {
+	r.keys[number] = key
+}
+
+// resolve returns the key of the JIRA issue a GitHub issue of the configured
+// repository is synced to, and whether there is one.
+func (r *referenceResolver) resolve(number int) (string, bool) {
+	if key, ok := r.keys[number]; ok {
+		return key, key != ""
+	}
+
+	key, ok := r.lookup(number)
+	if !ok {
+		key = ""
+	}
+	r.keys[number] = key
+
+	return key, ok
+}
+
+// rewrite replaces every reference to a synced issue of the configured repository
+// in a GitHub body with its JIRA key or a smart link to it. Other references are
+// turned into links to GitHub, so they aren't left as dead text in JIRA. If
+// reference rewriting is off, the body is returned unchanged.
+func (r *referenceResolver) rewrite(body string) string {
+	if r.format == "" {
+		return body
+	}
+
+	fullName := r.user + "/" + r.repoName
+
+	return issueReferenceRegex.ReplaceAllStringFunc(body, func(match string) string {
+		// fields[0] is the whole match, 1 is the preceding character, 2 is the
+		// repository (or "" if none), and 3 is the issue number
+		fields := issueReferenceRegex.FindStringSubmatch(match)
+		prefix, repo, number := fields[1], fields[2], fields[3]
+
+		if repo == "" || strings.EqualFold(repo, fullName) {
+			repo = fullName
+			if n, err := strconv.Atoi(number); err == nil {
+				if key, ok := r.resolve(n); ok {
+					return prefix + r.formatKey(key)
+				}
+			}
+		}
+
+		return fmt.Sprintf("%s[%s|%s/%s/issues/%s]", prefix, match[len(prefix):], r.githubURL, repo, number)
+	})
+}
+
+// formatKey renders a JIRA issue key according to the configured format.
+func (r *referenceResolver) formatKey(key string) string {
+	if r.format != cfg.ReferencesToSmartLink {
+		return key
+	}
+	url := fmt.Sprintf("%s/browse/%s", strings.TrimSuffix(r.jiraURI, "/"), key)
+	return fmt.Sprintf("[%s|%s|smart-link]", url, url)
+}
+
+// withRewrittenBody returns a copy of the GitHub issue whose body has had its
+// references rewritten by the resolver.
+func withRewrittenBody(ghIssue models.ExtendedGithubIssue, refs *referenceResolver) models.ExtendedGithubIssue {
+	if ghIssue.Body == nil {
+		return ghIssue
+	}
+	body := refs.rewrite(ghIssue.GetBody())
+	ghIssue.Body = &body
+	return ghIssue
+}
+
+// syncReferenceLinks links the JIRA issue to the JIRA issues of every issue
+// of the configured repository which GitHub reports as cross-referencing or
+// connected to it, using the `reference-link-type` issue link type. Nothing is
+// done if that option is empty.
+func syncReferenceLinks(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, refs *referenceResolver, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	linkType := config.GetReferenceLinkType()
+	if linkType == "" {
+		return nil
+	}
+
+	events, err := issuesyncgithub.ListTimeline(ghClient, config.GetTimeout(), user, repoName, ghIssue.GetNumber())
+	if err != nil {
+		return err
+	}
+
+	linked := map[string]bool{jIssue.Key: true}
+	for _, l := range jIssue.Fields.IssueLinks {
+		if !strings.EqualFold(l.Type.Name, linkType) {
+			continue
+		}
+		if l.InwardIssue != nil {
+			linked[l.InwardIssue.Key] = true
+		}
+		if l.OutwardIssue != nil {
+			linked[l.OutwardIssue.Key] = true
+		}
+	}
+
+	repoURLSuffix := strings.ToLower("/repos/" + user + "/" + repoName)
+
+	for _, e := range events {
+		if e.GetEvent() != "cross-referenced" && e.GetEvent() != "connected" {
+			continue
+		}
+		if e.Source == nil || e.Source.Issue == nil {
+			continue
+		}
+		source := e.Source.Issue
+		if source.PullRequestLinks != nil || !strings.HasSuffix(strings.ToLower(source.GetRepositoryURL()), repoURLSuffix) {
+			continue
+		}
+
+		key, ok := refs.resolve(source.GetNumber())
+		if !ok || linked[key] {
+			continue
+		}
+
+		log.Debugf("Linking JIRA issue %s to cross-referencing issue %s", jIssue.Key, key)
+		if err := issuesyncjira.CreateIssueLink(jClient, config.GetTimeout(), linkType, jIssue.Key, key); err != nil {
+			return err
+		}
+		linked[key] = true
+	}
+
+	return nil
+}
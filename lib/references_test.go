@@ -0,0 +1,113 @@
+package lib
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+)
+
+func newTestReferenceResolver(format string) *referenceResolver {
+	return &referenceResolver{
+		user:      "owner",
+		repoName:  "repo",
+		format:    format,
+		jiraURI:   "https://jira.example.com/",
+		githubURL: "https://github.com",
+		keys:      map[int]string{},
+		lookup: func(number int) (string, bool) {
+			if number == 456 {
+				return "SYNC-12", true
+			}
+			return "", false
+		},
+	}
+}
+
+func TestReferenceResolverRewritesSyncedIssues(t *testing.T) {
+	refs := newTestReferenceResolver("key")
+
+	body := refs.rewrite("Duplicate of #456, see also Owner/Repo#456.")
+	expected := "Duplicate of SYNC-12, see also SYNC-12."
+	if body != expected {
+		t.Fatalf("Expected body = %s; Got body = %s", expected, body)
+	}
+}
+
+func TestReferenceResolverLinksUnsyncedIssuesToGitHub(t *testing.T) {
+	refs := newTestReferenceResolver("key")
+
+	body := refs.rewrite("Fixed by #7 and other/repo#789.")
+	expected := "Fixed by [#7|https://github.com/owner/repo/issues/7] and [other/repo#789|https://github.com/other/repo/issues/789]."
+	if body != expected {
+		t.Fatalf("Expected body = %s; Got body = %s", expected, body)
+	}
+}
+
+func TestReferenceResolverWritesSmartLinks(t *testing.T) {
+	refs := newTestReferenceResolver("smart-link")
+
+	body := refs.rewrite("#456")
+	expected := "[https://jira.example.com/browse/SYNC-12|https://jira.example.com/browse/SYNC-12|smart-link]"
+	if body != expected {
+		t.Fatalf("Expected body = %s; Got body = %s", expected, body)
+	}
+}
+
+func TestReferenceResolverIgnoresAnchorsAndEntities(t *testing.T) {
+	refs := newTestReferenceResolver("key")
+
+	original := "See https://example.com/page#123 and &#39;quoted&#39;"
+	body := refs.rewrite(original)
+	if body != original {
+		t.Fatalf("Expected body = %s; Got body = %s", original, body)
+	}
+}
+
+func TestReferenceResolverDisabled(t *testing.T) {
+	refs := newTestReferenceResolver("")
+
+	original := "Duplicate of #456"
+	body := refs.rewrite(original)
+	if body != original {
+		t.Fatalf("Expected body = %s; Got body = %s", original, body)
+	}
+}
+
+func TestReferenceResolverLooksUpOnce(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":          "error",
+		"repo-name":          "owner/repo",
+		"timeout":            "1m",
+		"rewrite-references": "key",
+	})
+
+	calls := 0
+	ghClient := issuesyncgithub.NewTestClient()
+	ghClient.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	ghClient.HandleGetIssue = func(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error) {
+		calls++
+		res := &http.Response{StatusCode: http.StatusNotFound, Request: &http.Request{Method: "GET", URL: &url.URL{}}}
+		return nil, &github.Response{Response: res}, &github.ErrorResponse{Response: res, Message: "Not Found"}
+	}
+
+	refs := newReferenceResolver(config, ghClient, issuesyncjira.NewTestClient())
+	refs.add(12, "SYNC-12")
+
+	body := refs.rewrite("#12 duplicates #404, like #404 said.")
+	expected := "SYNC-12 duplicates [#404|https://github.com/owner/repo/issues/404], like [#404|https://github.com/owner/repo/issues/404] said."
+	if body != expected {
+		t.Fatalf("Expected body = %s; Got body = %s", expected, body)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected a missing issue to be looked up once, without retries; Got %d calls", calls)
+	}
+}
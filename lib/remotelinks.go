@@ -0,0 +1,137 @@
+package lib
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// githubIconURL returns the icon of the GitHub instance hosting a page, which is
+// shown next to every remote link.
+func githubIconURL(pageURL string) string {
+	u, err := url.Parse(pageURL)
+	if err != nil || u.Host == "" {
+		return "https://github.com/favicon.ico"
+	}
+	return fmt.Sprintf("%s://%s/favicon.ico", u.Scheme, u.Host)
+}
+
+// octiconsURL is the base URL of GitHub's Octicons, which are used as status icons.
+const octiconsURL = "https://raw.githubusercontent.com/primer/octicons/main/icons"
+
+// newGitHubRemoteLink creates a remote link to a GitHub page. The global ID is
+// derived from the URL, so the same page is always linked by the same remote link.
+func newGitHubRemoteLink(url string, title string, relationship string, icon string, status string, resolved bool) issuesyncjira.RemoteLink {
+	return issuesyncjira.RemoteLink{
+		GlobalID: fmt.Sprintf("github=%s", url),
+		Application: issuesyncjira.RemoteLinkApplication{
+			Type: "com.github",
+			Name: "GitHub",
+		},
+		Relationship: relationship,
+		Object: issuesyncjira.RemoteLinkObject{
+			URL:   url,
+			Title: title,
+			Icon: issuesyncjira.RemoteLinkIcon{
+				URL16x16: githubIconURL(url),
+				Title:    "GitHub",
+			},
+			Status: issuesyncjira.RemoteLinkStatus{
+				Resolved: resolved,
+				Icon: issuesyncjira.RemoteLinkIcon{
+					URL16x16: fmt.Sprintf("%s/%s-16.svg", octiconsURL, icon),
+					Title:    status,
+				},
+			},
+		},
+	}
+}
+
+// issueRemoteLink creates the remote link to a GitHub issue.
+func issueRemoteLink(user string, repoName string, ghIssue github.Issue) issuesyncjira.RemoteLink {
+	title := fmt.Sprintf("%s/%s#%d: %s", user, repoName, ghIssue.GetNumber(), ghIssue.GetTitle())
+	if ghIssue.GetState() == "closed" {
+		return newGitHubRemoteLink(ghIssue.GetHTMLURL(), title, "GitHub issue", "issue-closed", "Closed", true)
+	}
+	return newGitHubRemoteLink(ghIssue.GetHTMLURL(), title, "GitHub issue", "issue-opened", "Open", false)
+}
+
+// pullRequestRemoteLink creates the remote link to a GitHub pull request.
+func pullRequestRemoteLink(pr models.PullRequest) issuesyncjira.RemoteLink {
+	title := fmt.Sprintf("%s#%d: %s", pr.Repo, pr.Number, pr.Title)
+	switch {
+	case pr.Merged:
+		return newGitHubRemoteLink(pr.URL, title, "pull request", "git-merge", "Merged", true)
+	case pr.State == "closed":
+		return newGitHubRemoteLink(pr.URL, title, "pull request", "git-pull-request-closed", "Closed", true)
+	default:
+		return newGitHubRemoteLink(pr.URL, title, "pull request", "git-pull-request", "Open", false)
+	}
+}
+
+// commitRemoteLink creates the remote link to a GitHub commit.
+func commitRemoteLink(c models.Commit) issuesyncjira.RemoteLink {
+	title := fmt.Sprintf("%s@%s", c.Repo, shortSHA(c.SHA))
+	if c.Message != "" {
+		title = fmt.Sprintf("%s: %s", title, strings.SplitN(c.Message, "\n", 2)[0])
+	}
+	return newGitHubRemoteLink(c.URL, title, "commit", "git-commit", "Committed", false)
+}
+
+// remoteLinkChanged returns whether two remote links with the same global ID
+// differ in any of the values issue-sync sets.
+func remoteLinkChanged(a issuesyncjira.RemoteLink, b issuesyncjira.RemoteLink) bool {
+	return a.Object.URL != b.Object.URL ||
+		a.Object.Title != b.Object.Title ||
+		a.Object.Status.Resolved != b.Object.Status.Resolved ||
+		a.Object.Status.Icon.Title != b.Object.Status.Icon.Title
+}
+
+// syncRemoteLinks maintains remote links from the JIRA issue to its GitHub issue,
+// and to every pull request and commit referencing it, as found by loadDevelopment.
+// Only links which are missing or out of date are written. Nothing is done unless
+// `remote-links` is set.
+func syncRemoteLinks(config cfg.Config, ghIssue models.ExtendedGithubIssue, jIssue jira.Issue, jClient issuesyncjira.Client) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	if !config.SyncRemoteLinks() {
+		return nil
+	}
+
+	links := []issuesyncjira.RemoteLink{issueRemoteLink(user, repoName, ghIssue.Issue)}
+	for _, pr := range ghIssue.PullRequests {
+		links = append(links, pullRequestRemoteLink(pr))
+	}
+	for _, c := range ghIssue.Commits {
+		links = append(links, commitRemoteLink(c))
+	}
+
+	existing, err := issuesyncjira.ListRemoteLinks(jClient, config.GetTimeout(), jIssue.Key)
+	if err != nil {
+		return err
+	}
+	byGlobalID := map[string]issuesyncjira.RemoteLink{}
+	for _, l := range existing {
+		byGlobalID[l.GlobalID] = l
+	}
+
+	for _, l := range links {
+		if current, ok := byGlobalID[l.GlobalID]; ok && !remoteLinkChanged(current, l) {
+			continue
+		}
+		log.Debugf("Setting remote link to %s on JIRA issue %s", l.Object.URL, jIssue.Key)
+		if err := issuesyncjira.SetRemoteLink(jClient, config.GetTimeout(), jIssue.Key, l); err != nil {
+			return err
+		}
+		byGlobalID[l.GlobalID] = l
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"testing"
+
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestPullRequestRemoteLinkStatus(t *testing.T) {
+	url := "https://github.com/owner/repo/pull/45"
+
+	link := pullRequestRemoteLink(models.PullRequest{Repo: "owner/repo", Number: 45, URL: url, State: "closed", Merged: true})
+
+	if link.GlobalID != "github=https://github.com/owner/repo/pull/45" {
+		t.Fatalf("Expected GlobalID = github=%s; Got GlobalID = %s", url, link.GlobalID)
+	}
+	if !link.Object.Status.Resolved {
+		t.Fatalf("Expected merged pull request to be resolved")
+	}
+	if link.Object.Status.Icon.Title != "Merged" {
+		t.Fatalf("Expected status = Merged; Got status = %s", link.Object.Status.Icon.Title)
+	}
+}
+
+func TestCommitRemoteLink(t *testing.T) {
+	m := commitURLRegex.FindStringSubmatch("https://api.github.com/repos/owner/repo/commits/0123456789abcdef")
+	if m == nil {
+		t.Fatalf("Regex failed to parse commit URL")
+	}
+
+	link := commitRemoteLink(models.Commit{
+		Repo:    m[1] + "/" + m[2],
+		SHA:     m[3],
+		Message: "Fix the thing\n\nLonger description",
+		URL:     "https://github.com/owner/repo/commit/0123456789abcdef",
+	})
+
+	if link.Object.URL != "https://github.com/owner/repo/commit/0123456789abcdef" {
+		t.Fatalf("Expected URL = https://github.com/owner/repo/commit/0123456789abcdef; Got URL = %s", link.Object.URL)
+	}
+	if link.Object.Title != "owner/repo@0123456: Fix the thing" {
+		t.Fatalf("Expected Title = owner/repo@0123456: Fix the thing; Got Title = %s", link.Object.Title)
+	}
+}
+
+func TestFormatDevelopmentSection(t *testing.T) {
+	ghIssue := models.ExtendedGithubIssue{
+		PullRequests: []models.PullRequest{
+			{Repo: "owner/repo", Number: 45, Title: "Fix it", URL: "https://github.com/owner/repo/pull/45", State: "open"},
+		},
+		Commits: []models.Commit{
+			{Repo: "owner/repo", SHA: "0123456789abcdef", Message: "Fix it\n\nDetails", Author: "octocat", URL: "https://github.com/owner/repo/commit/0123456789abcdef"},
+		},
+	}
+
+	expected := "----\nh4. Development\n" +
+		"* Pull request [owner/repo#45|https://github.com/owner/repo/pull/45]: Fix it (Open)\n" +
+		"* Commit [owner/repo@0123456|https://github.com/owner/repo/commit/0123456789abcdef]: Fix it (octocat)"
+
+	if actual := formatDevelopmentSection(ghIssue); actual != expected {
+		t.Fatalf("Expected:\n%s\nGot:\n%s", expected, actual)
+	}
+
+	if actual := formatDevelopmentSection(models.ExtendedGithubIssue{}); actual != "" {
+		t.Fatalf("Expected empty section; Got:\n%s", actual)
+	}
+}
+
+func TestGitHubIconURL(t *testing.T) {
+	for url, expected := range map[string]string{
+		"https://github.com/owner/repo/issues/1":         "https://github.com/favicon.ico",
+		"https://github.example.com/owner/repo/issues/1": "https://github.example.com/favicon.ico",
+		"": "https://github.com/favicon.ico",
+	} {
+		if actual := githubIconURL(url); actual != expected {
+			t.Fatalf("Expected icon of %q = %s; Got %s", url, expected, actual)
+		}
+	}
+}
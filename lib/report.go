@@ -0,0 +1,119 @@
+package lib
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/indeedeng/issue-sync/lib/models"
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// RunReport summarizes a sync run: how many issues each action was performed
+// for, and every failure.
+type RunReport struct {
+	RunID      string    `json:"run_id"`
+	Repo       string    `json:"repo"`
+	Start      time.Time `json:"start"`
+	DurationMS int64     `json:"duration_ms"`
+	// Actions counts the issues each action succeeded for, e.g. create_issue.
+	Actions  map[string]int `json:"actions"`
+	Failures []RunFailure   `json:"failures"`
+	// Synced are the numbers of the GitHub issues which were synced.
+	Synced []int `json:"synced"`
+	// Error is why the run stopped before syncing every issue, if it did.
+	Error string `json:"error,omitempty"`
+	// Since is the `since` date the next run lists GitHub issues from, if the
+	// run moved it; see nextSince.
+	Since *time.Time `json:"since,omitempty"`
+
+	// listed are the GitHub issues listed as updated since the last run.
+	listed []models.ExtendedGithubIssue
+}
+
+// RunFailure is an action of a run which failed. Failures which aren't about a
+// single issue, such as syncing the hierarchy, have no issue number.
+type RunFailure struct {
+	GitHubNumber int    `json:"gh_number,omitempty"`
+	GitHubID     int64  `json:"gh_id,omitempty"`
+	JIRAKey      string `json:"jira_key,omitempty"`
+	Action       string `json:"action"`
+	Error        string `json:"error"`
+}
+
+func newRunReport(runID, repo string) RunReport {
+	return RunReport{
+		RunID:    runID,
+		Repo:     repo,
+		Start:    time.Now(),
+		Actions:  map[string]int{},
+		Failures: []RunFailure{},
+		Synced:   []int{},
+	}
+}
+
+// succeeded counts an action performed for an issue.
+func (r *RunReport) succeeded(action string, ghIssue *models.ExtendedGithubIssue) {
+	r.Actions[action]++
+	r.Synced = append(r.Synced, ghIssue.GetNumber())
+}
+
+// failed records an action which failed, for an issue if ghIssue isn't nil.
+func (r *RunReport) failed(action string, ghIssue *models.ExtendedGithubIssue, jiraKey string, err error) {
+	failure := RunFailure{JIRAKey: jiraKey, Action: action, Error: err.Error()}
+	if ghIssue != nil {
+		failure.GitHubNumber = ghIssue.GetNumber()
+		failure.GitHubID = ghIssue.GetID()
+	}
+	r.Failures = append(r.Failures, failure)
+}
+
+// finish records how long the run took, and why it stopped early, if err isn't nil.
+func (r *RunReport) finish(err error) {
+	r.DurationMS = utils.DurationMillis(time.Since(r.Start))
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// Failed returns whether the run stopped early, or more than threshold of its
+// actions failed.
+func (r RunReport) Failed(threshold int) bool {
+	return r.Error != "" || len(r.Failures) > threshold
+}
+
+// Log logs a summary of the run, then each failure.
+func (r RunReport) Log(log logrus.Entry) {
+	fields := logrus.Fields{
+		"run_id":      r.RunID,
+		"repo":        r.Repo,
+		"duration_ms": r.DurationMS,
+		"failures":    len(r.Failures),
+	}
+	for action, count := range r.Actions {
+		fields[action] = count
+	}
+	log.WithFields(fields).Info("Run finished")
+
+	for _, f := range r.Failures {
+		log.WithFields(logrus.Fields{
+			"run_id":    r.RunID,
+			"gh_number": f.GitHubNumber,
+			"jira_key":  f.JIRAKey,
+			"action":    f.Action,
+		}).Warnf("Failed: %s", f.Error)
+	}
+	if r.Error != "" {
+		log.WithField("run_id", r.RunID).Errorf("Run stopped early: %s", r.Error)
+	}
+}
+
+// WriteFile writes the report as JSON to a file.
+func (r RunReport) WriteFile(path string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
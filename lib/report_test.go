@@ -0,0 +1,66 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestRunReport(t *testing.T) {
+	report := newRunReport("run", "o/r")
+	for _, number := range []int{1, 2} {
+		synced := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(number)}}
+		report.succeeded("update_issue", &synced)
+	}
+	created := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(3)}}
+	report.succeeded("create_issue", &created)
+
+	ghIssue := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(7), ID: github.Int64(70)}}
+	report.failed("update_issue", &ghIssue, "SYNC-3", errors.New("field not on screen"))
+	report.finish(nil)
+
+	if report.Actions["update_issue"] != 2 || report.Actions["create_issue"] != 1 {
+		t.Errorf("Expected 2 updated issues and 1 created issue; got %v", report.Actions)
+	}
+	if !report.Failed(0) {
+		t.Error("Expected the run to fail with a threshold of 0")
+	}
+	if report.Failed(1) {
+		t.Error("Expected the run not to fail with a threshold of 1")
+	}
+
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "report.json")
+	if err := report.WriteFile(path); err != nil {
+		t.Fatalf("WriteFile failed with error: %v", err)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written RunReport
+	if err := json.Unmarshal(b, &written); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := RunFailure{GitHubNumber: 7, GitHubID: 70, JIRAKey: "SYNC-3", Action: "update_issue", Error: "field not on screen"}
+	if len(written.Failures) != 1 || written.Failures[0] != expected {
+		t.Errorf("Expected the failure of #7 to be written; got %+v", written.Failures)
+	}
+
+	report.finish(errors.New("GitHub is down"))
+	if !report.Failed(10) {
+		t.Error("Expected a run which stopped early to fail")
+	}
+}
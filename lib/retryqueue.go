@@ -0,0 +1,136 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/indeedeng/issue-sync/lib/utils"
+)
+
+// QueuedFailure is a GitHub issue which failed to sync, and is retried by later
+// runs until it syncs.
+type QueuedFailure struct {
+	GitHubNumber int    `json:"gh_number"`
+	GitHubID     int64  `json:"gh_id,omitempty"`
+	JIRAKey      string `json:"jira_key,omitempty"`
+	Action       string `json:"action"`
+	Error        string `json:"error"`
+	// Attempts is how many runs the issue failed to sync in.
+	Attempts    int       `json:"attempts"`
+	FirstFailed time.Time `json:"first_failed"`
+	LastFailed  time.Time `json:"last_failed"`
+	// NextRetry is when the issue is retried next, unless it's a dead letter.
+	NextRetry time.Time `json:"next_retry"`
+}
+
+// RetryQueue is the persistent queue of the GitHub issues which failed to sync.
+// Each is retried with exponential backoff, until it syncs or reaches the
+// maximum number of attempts, after which it's a dead letter which is only
+// synced again when GitHub updates it, or with `sync-issue`.
+type RetryQueue struct {
+	path     string
+	failures map[int]*QueuedFailure
+}
+
+// LoadRetryQueue reads the retry queue from a file, which is created when the
+// queue is saved if it doesn't exist. If the path is empty, the queue isn't
+// persisted.
+func LoadRetryQueue(path string) (*RetryQueue, error) {
+	q := &RetryQueue{path: path, failures: map[int]*QueuedFailure{}}
+	if path == "" {
+		return q, nil
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return q, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var failures []*QueuedFailure
+	if err := json.Unmarshal(b, &failures); err != nil {
+		return nil, fmt.Errorf("unable to read the retry queue %s: %v", path, err)
+	}
+	for _, f := range failures {
+		q.failures[f.GitHubNumber] = f
+	}
+	return q, nil
+}
+
+// Failures returns the queued failures, by GitHub issue number.
+func (q *RetryQueue) Failures() []QueuedFailure {
+	failures := make([]QueuedFailure, 0, len(q.failures))
+	for _, f := range q.failures {
+		failures = append(failures, *f)
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		return failures[i].GitHubNumber < failures[j].GitHubNumber
+	})
+	return failures
+}
+
+// Due returns the numbers of the GitHub issues to retry at a time, which have
+// failed fewer than maxAttempts times.
+func (q *RetryQueue) Due(now time.Time, maxAttempts int) []int {
+	var due []int
+	for number, f := range q.failures {
+		if f.Attempts < maxAttempts && !f.NextRetry.After(now) {
+			due = append(due, number)
+		}
+	}
+	sort.Ints(due)
+	return due
+}
+
+// Fail records that an issue failed to sync, and schedules its next retry after
+// a backoff which doubles with each attempt, up to maxBackoff.
+func (q *RetryQueue) Fail(failure RunFailure, now time.Time, backoff time.Duration, maxBackoff time.Duration) {
+	f, ok := q.failures[failure.GitHubNumber]
+	if !ok {
+		f = &QueuedFailure{GitHubNumber: failure.GitHubNumber, FirstFailed: now}
+		q.failures[failure.GitHubNumber] = f
+	}
+	f.GitHubID = failure.GitHubID
+	if failure.JIRAKey != "" {
+		f.JIRAKey = failure.JIRAKey
+	}
+	f.Action = failure.Action
+	f.Error = failure.Error
+	f.Attempts++
+	f.LastFailed = now
+
+	delay := backoff
+	for i := 1; i < f.Attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	f.NextRetry = now.Add(delay)
+}
+
+// Remove removes an issue from the queue, e.g. once it synced, and returns
+// whether it was queued.
+func (q *RetryQueue) Remove(number int) bool {
+	_, ok := q.failures[number]
+	delete(q.failures, number)
+	return ok
+}
+
+// Save writes the queue to its file, if it has one. The file is replaced
+// atomically, as `failures purge` may save it while a daemon does.
+func (q *RetryQueue) Save() error {
+	if q.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(q.Failures(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return utils.WriteFileAtomic(q.path, b, 0644)
+}
@@ -0,0 +1,103 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestRetryQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "failures.json")
+	queue, err := LoadRetryQueue(path)
+	if err != nil {
+		t.Fatalf("LoadRetryQueue failed with error: %v", err)
+	}
+
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	failure := RunFailure{GitHubNumber: 42, JIRAKey: "SYNC-7", Action: "update_issue", Error: "transition failed"}
+
+	expected := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute}
+	for _, delay := range expected {
+		queue.Fail(failure, now, time.Minute, 5*time.Minute)
+		if next := queue.Failures()[0].NextRetry; !next.Equal(now.Add(delay)) {
+			t.Errorf("Expected the next retry in %v; got %v", delay, next.Sub(now))
+		}
+	}
+
+	if due := queue.Due(now, 10); len(due) != 0 {
+		t.Errorf("Expected no issue to be due before its backoff; got %v", due)
+	}
+	later := now.Add(5 * time.Minute)
+	if due := queue.Due(later, 10); !reflect.DeepEqual(due, []int{42}) {
+		t.Errorf("Expected #42 to be due after its backoff; got %v", due)
+	}
+	if due := queue.Due(later, 4); len(due) != 0 {
+		t.Errorf("Expected a dead letter not to be due; got %v", due)
+	}
+
+	queue.Fail(RunFailure{GitHubNumber: 13, Action: "create_issue", Error: "required field"}, now, time.Minute, time.Hour)
+	if err := queue.Save(); err != nil {
+		t.Fatalf("Save failed with error: %v", err)
+	}
+
+	loaded, err := LoadRetryQueue(path)
+	if err != nil {
+		t.Fatalf("LoadRetryQueue failed with error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Failures(), queue.Failures()) {
+		t.Errorf("Expected the saved queue to be loaded; got %+v", loaded.Failures())
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("Expected the queue to be saved without leaving temporary files; got %d files", len(files))
+	}
+
+	if !loaded.Remove(42) || loaded.Remove(42) {
+		t.Error("Expected #42 to be removed once")
+	}
+	if failures := loaded.Failures(); len(failures) != 1 || failures[0].GitHubNumber != 13 {
+		t.Errorf("Expected only #13 to be left; got %+v", failures)
+	}
+}
+
+func TestRetryQueueDropsUnmatchedIssues(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":      "error",
+		"repo-name":      "owner/repo",
+		"exclude-labels": []string{"wontfix"},
+	})
+
+	queue, _ := LoadRetryQueue("")
+	now := time.Now()
+	for _, number := range []int{5, 6} {
+		queue.Fail(RunFailure{GitHubNumber: number, Action: actionUpdateIssue, Error: "transition failed"}, now, time.Minute, time.Hour)
+	}
+
+	label := "wontfix"
+	ghIssues := []models.ExtendedGithubIssue{
+		{Issue: github.Issue{Number: github.Int(5), Labels: []github.Label{{Name: &label}}}},
+		{Issue: github.Issue{Number: github.Int(6)}},
+	}
+	_, unmatched, err := filterIssues(config, ghIssues, issuesyncgithub.NewTestClient())
+	if err != nil {
+		t.Fatalf("filterIssues failed with error: %v", err)
+	}
+	removeUnmatchedRetries(config, queue, unmatched)
+
+	if failures := queue.Failures(); len(failures) != 1 || failures[0].GitHubNumber != 6 {
+		t.Errorf("Expected only #6, which still matches the filters, to be left; got %+v", failures)
+	}
+}
@@ -0,0 +1,244 @@
+package lib
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// notPlannedResolutions are the (lowercase) names of the JIRA resolutions which
+// close a GitHub issue as "not planned" rather than "completed".
+var notPlannedResolutions = map[string]bool{
+	"won't do":         true,
+	"won't fix":        true,
+	"duplicate":        true,
+	"cannot reproduce": true,
+	"incomplete":       true,
+	"declined":         true,
+}
+
+// ghCommentMarkerRegex matches the marker at the beginning of a GitHub comment
+// generated from a JIRA comment. It has a matching group for the JIRA comment
+// ID (\1). The marker is an HTML comment, so it isn't rendered by GitHub.
+var ghCommentMarkerRegex = regexp.MustCompile(`^<!-- issue-sync: JIRA comment (\d+) -->`)
+
+// reverseSyncFields updates the GitHub issue with the JIRA fields listed in the
+// `reverse-sync` option, and applies the same changes to `ghIssue` so that they
+// aren't synced back to JIRA as GitHub changes. For these fields, JIRA is the
+// source of truth; but the JIRA status only closes or reopens the GitHub issue
+// if it changed since the last sync, according to the sync record, so that an
+// issue reopened or closed on GitHub stays so.
+func reverseSyncFields(config cfg.Config, ghIssue *models.ExtendedGithubIssue, jIssue jira.Issue, stored syncRecord, ghClient issuesyncgithub.Client) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	fields := map[string]interface{}{}
+
+	if config.ReverseSyncs(cfg.ReverseSyncStatus) && jIssue.Fields.Status != nil {
+		done := jiraDone(jIssue)
+		if stored.Status != nil && stored.Status.JIRADone == done {
+			if (done && ghIssue.GetState() == "open") || (!done && ghIssue.GetState() == "closed") {
+				log.Debugf("GitHub #%d was %s on GitHub since the last sync; not changing its state", ghIssue.GetNumber(), map[bool]string{true: "reopened", false: "closed"}[done])
+			}
+		} else if done && ghIssue.GetState() == "open" {
+			fields["state"] = "closed"
+			fields["state_reason"] = closeReason(jIssue)
+		} else if !done && ghIssue.GetState() == "closed" {
+			fields["state"] = "open"
+		}
+		if state, ok := fields["state"].(string); ok {
+			ghIssue.State = &state
+		}
+	}
+
+	if config.ReverseSyncs(cfg.ReverseSyncPriority) {
+		priority := ""
+		if jIssue.Fields.Priority != nil {
+			priority = jIssue.Fields.Priority.Name
+		}
+
+		current := make([]string, len(ghIssue.Labels))
+		for i, l := range ghIssue.Labels {
+			current[i] = l.GetName()
+		}
+
+		labels := priorityLabels(config.GetPriorityLabelPrefix(), current, priority)
+		if strings.Join(labels, ",") != strings.Join(current, ",") {
+			fields["labels"] = labels
+			ghIssue.Labels = make([]github.Label, len(labels))
+			for i := range labels {
+				ghIssue.Labels[i] = github.Label{Name: &labels[i]}
+			}
+		}
+	}
+
+	if config.ReverseSyncs(cfg.ReverseSyncFixVersion) {
+		if len(jIssue.Fields.FixVersions) == 0 {
+			if ghIssue.Milestone != nil {
+				fields["milestone"] = nil
+				ghIssue.Milestone = nil
+			}
+		} else {
+			name := jIssue.Fields.FixVersions[0].Name
+			if ghIssue.Milestone == nil || ghIssue.Milestone.GetTitle() != name {
+				milestones, err := issuesyncgithub.ListMilestones(ghClient, config.GetTimeout(), user, repoName)
+				if err != nil {
+					return err
+				}
+				found := false
+				for _, m := range milestones {
+					if m.GetTitle() == name {
+						fields["milestone"] = m.GetNumber()
+						ghIssue.Milestone = m
+						found = true
+						break
+					}
+				}
+				if !found {
+					log.Warnf("GitHub milestone %s does not exist; not setting milestone of GitHub #%d", name, ghIssue.GetNumber())
+				}
+			}
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	log.Debugf("Updating GitHub #%d with JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
+
+	return issuesyncgithub.EditIssue(ghClient, config.GetTimeout(), user, repoName, ghIssue.GetNumber(), fields)
+}
+
+// jiraDone returns whether a JIRA issue is in a status of the "Done" category.
+func jiraDone(jIssue jira.Issue) bool {
+	return jIssue.Fields.Status != nil && jIssue.Fields.Status.StatusCategory.Key == jira.StatusCategoryComplete
+}
+
+// closeReason returns the GitHub state reason a GitHub issue should be closed with,
+// based on the resolution of its JIRA issue.
+func closeReason(jIssue jira.Issue) string {
+	if jIssue.Fields.Resolution != nil && notPlannedResolutions[strings.ToLower(jIssue.Fields.Resolution.Name)] {
+		return "not_planned"
+	}
+	return "completed"
+}
+
+// priorityLabels returns the labels of a GitHub issue with the label for the given
+// JIRA priority, replacing any other label with the same prefix. If the priority
+// is empty, priority labels are removed.
+func priorityLabels(prefix string, labels []string, priority string) []string {
+	result := make([]string, 0, len(labels)+1)
+	for _, l := range labels {
+		if !strings.HasPrefix(l, prefix) {
+			result = append(result, l)
+		}
+	}
+	if priority != "" {
+		result = append(result, prefix+priority)
+	}
+	return result
+}
+
+// reverseSyncComments copies the public comments of a JIRA issue to its GitHub
+// issue, and updates the copies if the JIRA comments were edited. A comment is
+// public if it starts with the `public-comment-prefix` and isn't restricted;
+// comments which issue-sync generated from GitHub comments are skipped. Nothing
+// is done unless comments are in the `reverse-sync` option.
+func reverseSyncComments(config cfg.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient issuesyncgithub.Client) error {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	if !config.ReverseSyncs(cfg.ReverseSyncComments) || jIssue.Fields.Comments == nil {
+		return nil
+	}
+
+	var ghComments []*github.IssueComment
+	if ghIssue.GetComments() != 0 {
+		var err error
+		ghComments, err = issuesyncgithub.ListComments(ghClient, config.GetTimeout(), user, repoName, ghIssue)
+		if err != nil {
+			return err
+		}
+	}
+
+	existing := map[string]*github.IssueComment{}
+	for _, c := range ghComments {
+		if m := ghCommentMarkerRegex.FindStringSubmatch(c.GetBody()); m != nil {
+			existing[m[1]] = c
+		}
+	}
+
+	for _, jComment := range jIssue.Fields.Comments.Comments {
+		if jComment.Visibility.Type != "" || jCommentIDRegex.MatchString(jComment.Body) {
+			continue
+		}
+		if _, err := strconv.ParseInt(jComment.ID, 10, 64); err != nil {
+			continue
+		}
+		public, ok := publicCommentBody(jComment.Body, config.GetPublicCommentPrefix())
+		if !ok {
+			continue
+		}
+
+		comment := *jComment
+		comment.Body = public
+		body := formatGitHubComment(config.GetConfigString("jira-uri"), jIssue, comment)
+
+		ghComment, ok := existing[jComment.ID]
+		if !ok {
+			if _, err := issuesyncgithub.CreateComment(ghClient, config.GetTimeout(), user, repoName, ghIssue.GetNumber(), body); err != nil {
+				return err
+			}
+			log.Debugf("Created GitHub comment from JIRA comment %s.", jComment.ID)
+			continue
+		}
+
+		if sameText(ghComment.GetBody(), body) {
+			continue
+		}
+		if _, err := issuesyncgithub.EditComment(ghClient, config.GetTimeout(), user, repoName, ghComment.GetID(), body); err != nil {
+			return err
+		}
+		log.Debugf("Updated GitHub comment %d from JIRA comment %s.", ghComment.GetID(), jComment.ID)
+	}
+
+	return nil
+}
+
+// publicCommentBody returns the body of a JIRA comment without the prefix which
+// marks it as public, and whether it's marked so.
+func publicCommentBody(body string, prefix string) (string, bool) {
+	body = strings.TrimLeft(body, " \t\r\n")
+	if !strings.HasPrefix(body, prefix) {
+		return "", false
+	}
+	return strings.TrimLeft(strings.TrimPrefix(body, prefix), " \t\r\n"), true
+}
+
+// formatGitHubComment renders a JIRA comment as the body of a GitHub comment,
+// starting with the marker which identifies it as generated by issue-sync.
+func formatGitHubComment(jiraURI string, jIssue jira.Issue, jComment jira.Comment) string {
+	author := jComment.Author.DisplayName
+	if author == "" {
+		author = jComment.Author.Name
+	}
+
+	url := fmt.Sprintf("%s/browse/%s?focusedCommentId=%s", strings.TrimSuffix(jiraURI, "/"), jIssue.Key, jComment.ID)
+
+	return fmt.Sprintf(
+		"<!-- issue-sync: JIRA comment %s -->\nComment from JIRA user **%s** on [%s](%s):\n\n%s",
+		jComment.ID,
+		author,
+		jIssue.Key,
+		url,
+		jComment.Body,
+	)
+}
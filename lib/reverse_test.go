@@ -0,0 +1,169 @@
+package lib
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestPriorityLabels(t *testing.T) {
+	labels := priorityLabels("priority: ", []string{"bug", "priority: Low", "ui"}, "High")
+	if strings.Join(labels, ",") != "bug,ui,priority: High" {
+		t.Fatalf("Expected labels = bug,ui,priority: High; Got labels = %s", strings.Join(labels, ","))
+	}
+
+	labels = priorityLabels("priority: ", []string{"bug", "priority: Low"}, "")
+	if strings.Join(labels, ",") != "bug" {
+		t.Fatalf("Expected labels = bug; Got labels = %s", strings.Join(labels, ","))
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	jIssue := jira.Issue{Fields: &jira.IssueFields{Resolution: &jira.Resolution{Name: "Won't Do"}}}
+	if reason := closeReason(jIssue); reason != "not_planned" {
+		t.Fatalf("Expected reason = not_planned; Got reason = %s", reason)
+	}
+
+	jIssue.Fields.Resolution.Name = "Done"
+	if reason := closeReason(jIssue); reason != "completed" {
+		t.Fatalf("Expected reason = completed; Got reason = %s", reason)
+	}
+}
+
+func TestGitHubCommentMarker(t *testing.T) {
+	jComment := jira.Comment{ID: "10023", Author: jira.User{DisplayName: "Jane"}, Body: "Looks good"}
+	body := formatGitHubComment("https://jira.example.com", jira.Issue{Key: "PROJ-1"}, jComment)
+
+	m := ghCommentMarkerRegex.FindStringSubmatch(body)
+	if m == nil || m[1] != "10023" {
+		t.Fatalf("Expected generated comment to be marked with JIRA comment 10023; Got body:\n%s", body)
+	}
+	if !strings.Contains(body, "[PROJ-1](https://jira.example.com/browse/PROJ-1?focusedCommentId=10023)") {
+		t.Fatalf("Expected generated comment to link to the JIRA comment; Got body:\n%s", body)
+	}
+}
+
+func TestReverseSyncCommentsOnlyCopiesPublicComments(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":    "error",
+		"repo-name":    "owner/repo",
+		"timeout":      "1m",
+		"jira-uri":     "https://jira.example.com",
+		"reverse-sync": []string{cfg.ReverseSyncComments},
+	})
+
+	var created []string
+	ghClient := issuesyncgithub.NewTestClient()
+	ghClient.HandleGetLogger = func() logrus.Entry {
+		return config.GetLogger()
+	}
+	ghClient.HandleCreateComment = func(ctx context.Context, owner string, repo string, number int, body string) (*github.IssueComment, *github.Response, error) {
+		created = append(created, body)
+		return &github.IssueComment{Body: &body}, &github.Response{}, nil
+	}
+
+	jIssue := jira.Issue{Key: "PROJ-1", Fields: &jira.IssueFields{Comments: &jira.Comments{Comments: []*jira.Comment{
+		{ID: "1", Body: "Internal discussion"},
+		{ID: "2", Body: "[public] Fixed in 1.2"},
+		{ID: "3", Body: "[public] Restricted", Visibility: jira.CommentVisibility{Type: "role", Value: "Developers"}},
+		{ID: "4", Body: "Mentions [public] later"},
+	}}}}
+
+	if err := reverseSyncComments(config, github.Issue{Number: github.Int(1)}, jIssue, ghClient); err != nil {
+		t.Fatalf("reverseSyncComments failed with error: %v", err)
+	}
+
+	if len(created) != 1 {
+		t.Fatalf("Expected only the comment marked public to be copied; Got %d comments", len(created))
+	}
+	if m := ghCommentMarkerRegex.FindStringSubmatch(created[0]); m == nil || m[1] != "2" {
+		t.Fatalf("Expected JIRA comment 2 to be copied; Got body:\n%s", created[0])
+	}
+	if !strings.HasSuffix(created[0], "\n\nFixed in 1.2") {
+		t.Fatalf("Expected the public prefix to be removed; Got body:\n%s", created[0])
+	}
+}
+
+func TestPublicCommentBody(t *testing.T) {
+	tests := []struct {
+		body     string
+		expected string
+		public   bool
+	}{
+		{body: "[public] Fixed", expected: "Fixed", public: true},
+		{body: "\r\n  [public]\r\nFixed", expected: "Fixed", public: true},
+		{body: "Fixed", public: false},
+		{body: "Fixed [public]", public: false},
+	}
+
+	for _, test := range tests {
+		body, public := publicCommentBody(test.body, "[public]")
+		if body != test.expected || public != test.public {
+			t.Errorf("%q: Expected (%q, %t); Got (%q, %t)", test.body, test.expected, test.public, body, public)
+		}
+	}
+}
+
+func TestReverseSyncStatusKeepsGitHubChanges(t *testing.T) {
+	tests := []struct {
+		name     string
+		ghState  string
+		jiraDone bool
+		stored   *statusRecord
+		expected string
+	}{
+		{name: "first sync closes", ghState: "open", jiraDone: true, expected: "closed"},
+		{name: "first sync reopens", ghState: "closed", jiraDone: false, expected: "open"},
+		{name: "done in JIRA closes", ghState: "open", jiraDone: true, stored: &statusRecord{GitHubState: "open", JIRADone: false}, expected: "closed"},
+		{name: "reopened in JIRA reopens", ghState: "closed", jiraDone: false, stored: &statusRecord{GitHubState: "closed", JIRADone: true}, expected: "open"},
+		{name: "reopened on GitHub stays open", ghState: "open", jiraDone: true, stored: &statusRecord{GitHubState: "closed", JIRADone: true}},
+		{name: "closed on GitHub stays closed", ghState: "closed", jiraDone: false, stored: &statusRecord{GitHubState: "open", JIRADone: false}},
+	}
+
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":    "error",
+		"repo-name":    "owner/repo",
+		"timeout":      "1m",
+		"reverse-sync": []string{cfg.ReverseSyncStatus},
+	})
+
+	for _, test := range tests {
+		edited := ""
+		ghClient := issuesyncgithub.NewTestClient()
+		ghClient.HandleGetLogger = func() logrus.Entry {
+			return config.GetLogger()
+		}
+		ghClient.HandleEditIssue = func(ctx context.Context, owner string, repo string, number int, fields map[string]interface{}) (*github.Issue, *github.Response, error) {
+			edited = fields["state"].(string)
+			return &github.Issue{}, &github.Response{}, nil
+		}
+
+		category := jira.StatusCategoryInProgress
+		if test.jiraDone {
+			category = jira.StatusCategoryComplete
+		}
+		jIssue := jira.Issue{Key: "PROJ-1", Fields: &jira.IssueFields{Status: &jira.Status{StatusCategory: jira.StatusCategory{Key: category}}}}
+		ghIssue := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(1), State: github.String(test.ghState)}}
+
+		if err := reverseSyncFields(config, &ghIssue, jIssue, syncRecord{Status: test.stored}, ghClient); err != nil {
+			t.Fatalf("%s: reverseSyncFields failed with error: %v", test.name, err)
+		}
+		if edited != test.expected {
+			t.Errorf("%s: Expected GitHub state changed to %q; Got %q", test.name, test.expected, edited)
+		}
+
+		var resolution conflictResolution
+		resolution.recordStatus(config, ghIssue, jIssue)
+		expected := statusRecord{GitHubState: ghIssue.GetState(), JIRADone: test.jiraDone}
+		if resolution.record.Status == nil || *resolution.record.Status != expected || !resolution.changed {
+			t.Errorf("%s: Expected status %+v to be recorded; Got %+v", test.name, expected, resolution.record.Status)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+// screenTab is a tab of a JIRA screen.
+type screenTab struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// screenField is a field on a tab of a JIRA screen.
+type screenField struct {
+	ID string `json:"id"`
+}
+
+// issueTypeScreenSchemeProject is an issue type screen scheme, and the projects
+// which use it.
+type issueTypeScreenSchemeProject struct {
+	IssueTypeScreenScheme struct {
+		ID string `json:"id"`
+	} `json:"issueTypeScreenScheme"`
+	ProjectIDs []string `json:"projectIds"`
+}
+
+// issueTypeScreenSchemeMapping maps an issue type to a screen scheme in an issue
+// type screen scheme; the issue type is "default" for those without a mapping.
+type issueTypeScreenSchemeMapping struct {
+	IssueTypeID    string `json:"issueTypeId"`
+	ScreenSchemeID string `json:"screenSchemeId"`
+}
+
+// screenScheme maps the operations on issues to the screens they show.
+type screenScheme struct {
+	ID      int `json:"id"`
+	Screens struct {
+		Default int `json:"default"`
+		Create  int `json:"create"`
+		Edit    int `json:"edit"`
+	} `json:"screens"`
+}
+
+// SetupJIRA creates the JIRA custom fields which the configured field mapper needs,
+// with the right type and searcher, and adds them to the first tab of each of the
+// screens. Unless screens are given, they're the create and edit screens of the
+// project's issues of the field mapper's issue type, according to its issue type
+// screen scheme. Fields and screen entries which already exist are left alone, so
+// it's safe to run it again. It requires JIRA administrator credentials, and
+// returns a description of each action.
+func SetupJIRA(config cfg.Config, jClient *jira.Client, screenIDs []int) ([]string, error) {
+	var actions []string
+
+	jFields, err := cfg.GetJIRAFields(*jClient)
+	if err != nil {
+		return actions, err
+	}
+	existing := map[string]cfg.JIRAField{}
+	for _, f := range jFields {
+		existing[f.Name] = f
+	}
+
+	var fieldIDs []string
+	for _, want := range config.GetCustomFields() {
+		if field, ok := existing[want.Name]; ok {
+			if field.Schema.Type != want.Type {
+				return actions, fmt.Errorf("field %q already exists with type %s, but must be a %s field; rename or delete it, then rerun", want.Name, field.Schema.Type, want.Type)
+			}
+			actions = append(actions, fmt.Sprintf("Field %q already exists as %s", want.Name, field.ID))
+			fieldIDs = append(fieldIDs, field.ID)
+			continue
+		}
+
+		if config.IsDryRun() {
+			actions = append(actions, fmt.Sprintf("Would create field %q (%s)", want.Name, want.Custom))
+			continue
+		}
+		id, err := createCustomField(jClient, want)
+		if err != nil {
+			return actions, fmt.Errorf("error creating field %q: %v", want.Name, err)
+		}
+		actions = append(actions, fmt.Sprintf("Created field %q as %s", want.Name, id))
+		fieldIDs = append(fieldIDs, id)
+	}
+
+	if len(screenIDs) == 0 {
+		screenIDs, err = projectScreenIDs(config, jClient)
+		if err != nil {
+			return actions, fmt.Errorf("error finding the screens of project %s: %v; set them with --screen-id", config.GetConfigString("jira-project"), err)
+		}
+		actions = append(actions, fmt.Sprintf("Using screens %s of %s issues in project %s", joinInts(screenIDs), config.GetIssueTypeName(), config.GetConfigString("jira-project")))
+	}
+	for _, screenID := range screenIDs {
+		screenActions, err := addFieldsToScreen(config, jClient, screenID, fieldIDs)
+		actions = append(actions, screenActions...)
+		if err != nil {
+			return actions, fmt.Errorf("error adding fields to screen %d: %v", screenID, err)
+		}
+	}
+
+	return actions, nil
+}
+
+// projectScreenIDs returns the IDs of the create and edit screens of the issues of
+// the field mapper's issue type in the JIRA project: the issue type screen scheme
+// of the project maps the issue type to a screen scheme, which maps the create
+// and edit operations to screens.
+func projectScreenIDs(config cfg.Config, jClient *jira.Client) ([]int, error) {
+	project, res, err := jClient.Project.Get(config.GetConfigString("jira-project"))
+	if err != nil {
+		return nil, jira.NewJiraError(res, err)
+	}
+
+	issueTypeID := ""
+	for _, t := range project.IssueTypes {
+		if t.Name == config.GetIssueTypeName() {
+			issueTypeID = t.ID
+		}
+	}
+	if issueTypeID == "" {
+		return nil, fmt.Errorf("the project has no %s issue type", config.GetIssueTypeName())
+	}
+
+	var schemes []issueTypeScreenSchemeProject
+	if err := getPagedValues(jClient, "rest/api/2/issuetypescreenscheme/project?projectId="+project.ID, &schemes); err != nil {
+		return nil, err
+	}
+	if len(schemes) == 0 {
+		return nil, errors.New("the project has no issue type screen scheme")
+	}
+
+	var mappings []issueTypeScreenSchemeMapping
+	if err := getPagedValues(jClient, "rest/api/2/issuetypescreenscheme/mapping?issueTypeScreenSchemeId="+schemes[0].IssueTypeScreenScheme.ID, &mappings); err != nil {
+		return nil, err
+	}
+	screenSchemeID := ""
+	for _, m := range mappings {
+		if m.IssueTypeID == issueTypeID || (m.IssueTypeID == "default" && screenSchemeID == "") {
+			screenSchemeID = m.ScreenSchemeID
+		}
+	}
+	if screenSchemeID == "" {
+		return nil, fmt.Errorf("issue type screen scheme %s maps no screen scheme to %s issues", schemes[0].IssueTypeScreenScheme.ID, config.GetIssueTypeName())
+	}
+
+	var screenSchemes []screenScheme
+	if err := getPagedValues(jClient, "rest/api/2/screenscheme?id="+screenSchemeID, &screenSchemes); err != nil {
+		return nil, err
+	}
+	if len(screenSchemes) == 0 {
+		return nil, fmt.Errorf("screen scheme %s doesn't exist", screenSchemeID)
+	}
+
+	// Operations without their own screen show the default one.
+	screens := screenSchemes[0].Screens
+	var screenIDs []int
+	for _, id := range []int{screens.Create, screens.Edit} {
+		if id == 0 {
+			id = screens.Default
+		}
+		if len(screenIDs) == 0 || screenIDs[0] != id {
+			screenIDs = append(screenIDs, id)
+		}
+	}
+	return screenIDs, nil
+}
+
+// getPagedValues gets the values of every page of a paginated JIRA API
+// resource, and decodes them into out, a pointer to a slice.
+func getPagedValues(jClient *jira.Client, url string, out interface{}) error {
+	var values []json.RawMessage
+	for startAt := 0; ; {
+		req, err := jClient.NewRequest("GET", fmt.Sprintf("%s&startAt=%d", url, startAt), nil)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			IsLast bool              `json:"isLast"`
+			Values []json.RawMessage `json:"values"`
+		}
+		if res, err := jClient.Do(req, &page); err != nil {
+			return jira.NewJiraError(res, err)
+		}
+		values = append(values, page.Values...)
+		if page.IsLast || len(page.Values) == 0 {
+			break
+		}
+		startAt += len(page.Values)
+	}
+
+	b, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, out)
+}
+
+// joinInts returns a comma-separated list of integers.
+func joinInts(ints []int) string {
+	s := make([]string, len(ints))
+	for i, n := range ints {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ", ")
+}
+
+// createCustomField creates a JIRA custom field, and returns its ID.
+func createCustomField(jClient *jira.Client, field cfg.CustomField) (string, error) {
+	body := map[string]string{
+		"name":        field.Name,
+		"description": "Managed by issue-sync; do not edit.",
+		"type":        field.Custom,
+		"searcherKey": field.Searcher,
+	}
+	req, err := jClient.NewRequest("POST", "rest/api/2/field", body)
+	if err != nil {
+		return "", err
+	}
+
+	var created cfg.JIRAField
+	res, err := jClient.Do(req, &created)
+	if err != nil {
+		return "", jira.NewJiraError(res, err)
+	}
+
+	return created.ID, nil
+}
+
+// addFieldsToScreen adds each field which isn't on a JIRA screen yet to its first
+// tab, and returns a description of each action.
+func addFieldsToScreen(config cfg.Config, jClient *jira.Client, screenID int, fieldIDs []string) ([]string, error) {
+	var actions []string
+
+	req, err := jClient.NewRequest("GET", fmt.Sprintf("rest/api/2/screens/%d/tabs", screenID), nil)
+	if err != nil {
+		return actions, err
+	}
+	var tabs []screenTab
+	if res, err := jClient.Do(req, &tabs); err != nil {
+		return actions, jira.NewJiraError(res, err)
+	}
+	if len(tabs) == 0 {
+		return actions, fmt.Errorf("screen %d has no tabs", screenID)
+	}
+
+	onScreen := map[string]bool{}
+	for _, tab := range tabs {
+		req, err := jClient.NewRequest("GET", fmt.Sprintf("rest/api/2/screens/%d/tabs/%d/fields", screenID, tab.ID), nil)
+		if err != nil {
+			return actions, err
+		}
+		var fields []screenField
+		if res, err := jClient.Do(req, &fields); err != nil {
+			return actions, jira.NewJiraError(res, err)
+		}
+		for _, f := range fields {
+			onScreen[f.ID] = true
+		}
+	}
+
+	tab := tabs[0]
+	for _, id := range fieldIDs {
+		if onScreen[id] {
+			actions = append(actions, fmt.Sprintf("Field %s is already on screen %d", id, screenID))
+			continue
+		}
+
+		if config.IsDryRun() {
+			actions = append(actions, fmt.Sprintf("Would add field %s to tab %q of screen %d", id, tab.Name, screenID))
+			continue
+		}
+		req, err := jClient.NewRequest("POST", fmt.Sprintf("rest/api/2/screens/%d/tabs/%d/fields", screenID, tab.ID), map[string]string{
+			"fieldId": id,
+		})
+		if err != nil {
+			return actions, err
+		}
+		if res, err := jClient.Do(req, nil); err != nil {
+			return actions, jira.NewJiraError(res, err)
+		}
+		actions = append(actions, fmt.Sprintf("Added field %s to tab %q of screen %d", id, tab.Name, screenID))
+	}
+
+	return actions, nil
+}
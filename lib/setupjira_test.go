@@ -0,0 +1,143 @@
+package lib
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+// stubJIRA serves the JIRA API resources used by SetupJIRA, with the project
+// SYNC's Task issues using screen scheme 3, which shows screen 20 when creating
+// issues and screen 21 when editing them, and records the fields added to each
+// screen.
+func stubJIRA(t *testing.T) (*httptest.Server, map[int][]string) {
+	added := map[int][]string{}
+	reply := func(w http.ResponseWriter, v interface{}) {
+		if err := json.NewEncoder(w).Encode(v); err != nil {
+			t.Error(err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/field", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			var field map[string]string
+			json.NewDecoder(r.Body).Decode(&field)
+			reply(w, cfg.JIRAField{ID: "customfield_10100", Name: field["name"]})
+			return
+		}
+		reply(w, []cfg.JIRAField{})
+	})
+	mux.HandleFunc("/rest/api/2/project/SYNC", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, jira.Project{ID: "10000", Key: "SYNC", IssueTypes: []jira.IssueType{
+			{ID: "1", Name: "Bug"},
+			{ID: "3", Name: "Task"},
+		}})
+	})
+	mux.HandleFunc("/rest/api/2/issuetypescreenscheme/project", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("projectId") != "10000" {
+			t.Errorf("Unexpected project ID %q", r.URL.Query().Get("projectId"))
+		}
+		fmt.Fprint(w, `{"isLast": true, "values": [{"issueTypeScreenScheme": {"id": "2"}, "projectIds": ["10000"]}]}`)
+	})
+	mux.HandleFunc("/rest/api/2/issuetypescreenscheme/mapping", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("issueTypeScreenSchemeId") != "2" {
+			t.Errorf("Unexpected issue type screen scheme ID %q", r.URL.Query().Get("issueTypeScreenSchemeId"))
+		}
+		// The mappings are split into two pages.
+		if r.URL.Query().Get("startAt") == "0" {
+			fmt.Fprint(w, `{"isLast": false, "values": [{"issueTypeId": "default", "screenSchemeId": "1"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"isLast": true, "values": [{"issueTypeId": "3", "screenSchemeId": "3"}]}`)
+	})
+	mux.HandleFunc("/rest/api/2/screenscheme", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("id") != "3" {
+			t.Errorf("Unexpected screen scheme ID %q", r.URL.Query().Get("id"))
+		}
+		fmt.Fprint(w, `{"isLast": true, "values": [{"id": 3, "screens": {"default": 1, "create": 20, "edit": 21}}]}`)
+	})
+	mux.HandleFunc("/rest/api/2/screens/", func(w http.ResponseWriter, r *http.Request) {
+		var screenID, tabID int
+		if strings.HasSuffix(r.URL.Path, "/tabs") {
+			reply(w, []screenTab{{ID: 5, Name: "Field Tab"}})
+			return
+		}
+		if _, err := fmt.Sscanf(r.URL.Path, "/rest/api/2/screens/%d/tabs/%d/fields", &screenID, &tabID); err != nil {
+			t.Errorf("Unexpected request for %s", r.URL.Path)
+			return
+		}
+		if r.Method == "POST" {
+			var field map[string]string
+			json.NewDecoder(r.Body).Decode(&field)
+			added[screenID] = append(added[screenID], field["fieldId"])
+			reply(w, screenField{ID: field["fieldId"]})
+			return
+		}
+		reply(w, []screenField{})
+	})
+
+	return httptest.NewServer(mux), added
+}
+
+func TestSetupJIRAUsesProjectScreens(t *testing.T) {
+	server, added := stubJIRA(t)
+	defer server.Close()
+
+	jClient, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":                   "error",
+		"jira-project":                "SYNC",
+		"github-to-jira-field-mapper": cfg.FieldMapperJSON,
+	})
+
+	if _, err := SetupJIRA(config, jClient, nil); err != nil {
+		t.Fatalf("SetupJIRA failed with error: %v", err)
+	}
+
+	var screens []int
+	for id, fields := range added {
+		screens = append(screens, id)
+		if !reflect.DeepEqual(fields, []string{"customfield_10100"}) {
+			t.Errorf("Expected the field to be added to screen %d once; got %v", id, fields)
+		}
+	}
+	sort.Ints(screens)
+	if !reflect.DeepEqual(screens, []int{20, 21}) {
+		t.Errorf("Expected the field to be added to the create and edit screens of Task issues; got screens %v", screens)
+	}
+}
+
+func TestSetupJIRAScreenIDOverride(t *testing.T) {
+	server, added := stubJIRA(t)
+	defer server.Close()
+
+	jClient, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":                   "error",
+		"jira-project":                "SYNC",
+		"github-to-jira-field-mapper": cfg.FieldMapperJSON,
+	})
+
+	if _, err := SetupJIRA(config, jClient, []int{30}); err != nil {
+		t.Fatalf("SetupJIRA failed with error: %v", err)
+	}
+
+	if len(added) != 1 || len(added[30]) != 1 {
+		t.Errorf("Expected the field to be added to the given screen only; got %v", added)
+	}
+}
@@ -0,0 +1,69 @@
+package lib
+
+import (
+	"time"
+
+	"github.com/indeedeng/issue-sync/cfg"
+)
+
+// nextSince returns the `since` date the next run lists GitHub issues from, or
+// zero if it shouldn't move. It's the last time GitHub updated one of the issues
+// listed by the run, which doesn't depend on the local clock. It's then moved
+// back by `since-overlap`, from the start of the run at the latest, so that
+// issues updated while the run listed them aren't skipped.
+//
+// Issues which failed to sync are left to the retry queue, which retries them
+// with backoff whatever the date, so they don't hold it back; unless queued is
+// false because the queue couldn't be saved. Otherwise, if some of them failed
+// in a way which isn't retried, such as applying the `unmatched-policy`, it's
+// the first time GitHub updated one of those instead, so that they're listed
+// again.
+//
+// The date doesn't move if the run stopped early, or if something which isn't
+// about a single issue failed, such as syncing the hierarchy; nor does it ever
+// move back.
+func nextSince(config cfg.Config, report RunReport, queued bool) time.Time {
+	if report.Error != "" {
+		return time.Time{}
+	}
+
+	failed := map[int]bool{}
+	for _, f := range report.Failures {
+		switch {
+		case queued && isRetried(f):
+		case f.GitHubNumber == 0:
+			return time.Time{}
+		default:
+			failed[f.GitHubNumber] = true
+		}
+	}
+
+	var lastSynced, firstFailed time.Time
+	for _, ghIssue := range report.listed {
+		updated := ghIssue.GetUpdatedAt()
+		if failed[ghIssue.GetNumber()] {
+			if firstFailed.IsZero() || updated.Before(firstFailed) {
+				firstFailed = updated
+			}
+		} else if updated.After(lastSynced) {
+			lastSynced = updated
+		}
+	}
+
+	since := lastSynced
+	if !firstFailed.IsZero() {
+		since = firstFailed
+	}
+	if since.IsZero() {
+		return time.Time{}
+	}
+	if since.After(report.Start) {
+		since = report.Start
+	}
+	since = since.Add(-config.GetSinceOverlap())
+
+	if !since.After(config.GetSinceParam()) {
+		return time.Time{}
+	}
+	return since
+}
@@ -0,0 +1,118 @@
+package lib
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+func TestNextSince(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":     "error",
+		"since-overlap": "1m",
+	})
+
+	start := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	issue := func(number int, updated time.Time) models.ExtendedGithubIssue {
+		return models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(number), UpdatedAt: &updated}}
+	}
+	newReport := func() RunReport {
+		report := newRunReport("run", "o/r")
+		report.Start = start
+		report.listed = []models.ExtendedGithubIssue{
+			issue(1, start.Add(-3*time.Hour)),
+			issue(2, start.Add(-2*time.Hour)),
+			issue(3, start.Add(-time.Hour)),
+		}
+		return report
+	}
+
+	report := newReport()
+	if since := nextSince(config, report, true); !since.Equal(start.Add(-time.Hour - time.Minute)) {
+		t.Errorf("Expected the since date to be the last update less the overlap; got %v", since)
+	}
+
+	report = newReport()
+	report.failed("update_issue", &report.listed[1], "SYNC-2", errors.New("transition failed"))
+	if since := nextSince(config, report, true); !since.Equal(start.Add(-time.Hour - time.Minute)) {
+		t.Errorf("Expected the since date to pass #2, which is queued; got %v", since)
+	}
+	if since := nextSince(config, report, false); !since.Equal(start.Add(-2*time.Hour - time.Minute)) {
+		t.Errorf("Expected the since date not to pass #2 if the queue wasn't saved; got %v", since)
+	}
+
+	report = newReport()
+	report.failed("close_unmatched", &report.listed[1], "SYNC-2", errors.New("transition failed"))
+	if since := nextSince(config, report, true); !since.Equal(start.Add(-2*time.Hour - time.Minute)) {
+		t.Errorf("Expected the since date not to pass #2, which failed and isn't queued; got %v", since)
+	}
+
+	report = newReport()
+	report.listed = append(report.listed, issue(4, start.Add(time.Hour)))
+	if since := nextSince(config, report, true); !since.Equal(start.Add(-time.Minute)) {
+		t.Errorf("Expected the since date not to pass the start of the run; got %v", since)
+	}
+
+	report = newReport()
+	report.failed("sync_hierarchy", nil, "", errors.New("link failed"))
+	if since := nextSince(config, report, true); !since.IsZero() {
+		t.Errorf("Expected the since date not to move after a failure of the run; got %v", since)
+	}
+
+	report = newReport()
+	report.finish(errors.New("GitHub is down"))
+	if since := nextSince(config, report, true); !since.IsZero() {
+		t.Errorf("Expected the since date not to move after the run stopped early; got %v", since)
+	}
+
+	report = newRunReport("run", "o/r")
+	if since := nextSince(config, report, true); !since.IsZero() {
+		t.Errorf("Expected the since date not to move without any issue; got %v", since)
+	}
+}
+
+func TestNextSinceWithRepeatedFailure(t *testing.T) {
+	config := cfg.NewConfigFromSettings(map[string]interface{}{
+		"log-level":          "error",
+		"since-overlap":      "1m",
+		"retry-backoff":      "1h",
+		"retry-max-attempts": 2,
+	})
+
+	start := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	stuck := models.ExtendedGithubIssue{Issue: github.Issue{Number: github.Int(7), UpdatedAt: &start}}
+	queue, _ := LoadRetryQueue("")
+
+	// Each run lists the issues GitHub updated since the last one, and #7 keeps
+	// failing.
+	for run := 0; run < 3; run++ {
+		runStart := start.Add(time.Duration(run+1) * 2 * time.Hour)
+		other := runStart.Add(-time.Hour)
+
+		report := newRunReport("run", "o/r")
+		report.Start = runStart
+		report.listed = []models.ExtendedGithubIssue{
+			{Issue: github.Issue{Number: github.Int(10 + run), UpdatedAt: &other}},
+		}
+		// The queue is updated with the current time.
+		due := queue.Due(time.Now().Add(time.Duration(run)*2*time.Hour), config.GetRetryMaxAttempts())
+		if run == 0 || (len(due) == 1 && due[0] == 7) {
+			report.failed(actionUpdateIssue, &stuck, "SYNC-7", errors.New("transition failed"))
+		}
+		updateRetryQueue(config, queue, report)
+
+		since := nextSince(config, report, true)
+		if !since.Equal(other.Add(-time.Minute)) {
+			t.Fatalf("run %d: Expected the since date to move past #7; got %v", run, since)
+		}
+	}
+
+	failures := queue.Failures()
+	if len(failures) != 1 || failures[0].Attempts != 2 {
+		t.Fatalf("Expected #7 to be a dead letter after 2 attempts; got %+v", failures)
+	}
+}
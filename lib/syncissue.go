@@ -0,0 +1,231 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+	"github.com/indeedeng/issue-sync/lib/models"
+)
+
+// IssueSyncResult describes the outcome of syncing a single GitHub issue.
+type IssueSyncResult struct {
+	// Number is the number of the GitHub issue.
+	Number int
+	// Key is the key of the JIRA issue, or an empty string if it wasn't created
+	// (e.g. in a dry run).
+	Key string
+	// Created is whether the JIRA issue was created by this sync.
+	Created bool
+	// Changes describes each JIRA field which this sync changed, or in a dry
+	// run, would have changed.
+	Changes []string
+}
+
+// FindGitHubNumber returns the number of the GitHub issue a JIRA issue (identified
+// by its key) is synced from.
+func FindGitHubNumber(config cfg.Config, key string, jClient issuesyncjira.Client) (int, error) {
+	jIssue, err := issuesyncjira.GetIssue(jClient, config.GetTimeout(), key)
+	if err != nil {
+		return 0, err
+	}
+
+	number, err := config.GetFieldMapper().GetFieldValue(&jIssue, cfg.GitHubNumber)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := number.(int64)
+	if !ok || n == 0 {
+		return 0, fmt.Errorf("JIRA issue %s is not synced from a GitHub issue", key)
+	}
+
+	return int(n), nil
+}
+
+// SyncIssue syncs exactly one GitHub issue and its comments, regardless of the
+// `since` date, calling UpdateIssue or CreateIssue as CompareIssues would. The
+// issue is synced even if it doesn't match the issue filters, so that it can be
+// debugged. It returns the JIRA key and the fields which changed.
+func SyncIssue(config cfg.Config, number int, ghClient issuesyncgithub.Client, jClient issuesyncjira.Client) (IssueSyncResult, error) {
+	log := config.GetLogger()
+	user, repoName := config.GetRepo()
+
+	result := IssueSyncResult{Number: number}
+
+	ghIssue, err := issuesyncgithub.GetIssue(ghClient, config.GetTimeout(), user, repoName, number)
+	if err != nil {
+		return result, err
+	}
+	if ghIssue.PullRequestLinks != nil {
+		return result, fmt.Errorf("GitHub #%d is a pull request, which is never synced", number)
+	}
+
+	if matching, _, err := filterIssues(config, []models.ExtendedGithubIssue{ghIssue}, ghClient); err == nil && len(matching) == 0 {
+		log.Warnf("GitHub #%d doesn't match the issue filters; syncing it anyway", number)
+	}
+
+	before, found, err := findJIRAIssue(config, ghIssue, jClient)
+	if err != nil {
+		return result, err
+	}
+
+	refs := newReferenceResolver(config, ghClient, jClient)
+	var updated *jira.IssueFields
+	if found {
+		updated, err = updateIssue(config, ghIssue, before, refs, ghClient, jClient)
+	} else {
+		err = CreateIssue(config, ghIssue, refs, ghClient, jClient)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if found && config.IsDryRun() {
+		// Nothing was written, so the changes are those which would have been.
+		result.Key = before.Key
+		result.Changes = describeChanges(config, before, withFields(before, updated))
+		return result, nil
+	}
+
+	after, ok, err := findJIRAIssue(config, ghIssue, jClient)
+	if err != nil {
+		return result, err
+	}
+	if !ok {
+		// Nothing was created, as in a dry run.
+		return result, nil
+	}
+
+	result.Key = after.Key
+	result.Created = !found
+	if found {
+		result.Changes = describeChanges(config, before, after)
+	}
+
+	return result, nil
+}
+
+// findJIRAIssue returns the complete JIRA issue synced from a GitHub issue, and
+// whether there is one.
+func findJIRAIssue(config cfg.Config, ghIssue models.ExtendedGithubIssue, jClient issuesyncjira.Client) (jira.Issue, bool, error) {
+	jIssues, err := jiraIssuesByNumber(config, map[int]models.ExtendedGithubIssue{ghIssue.GetNumber(): ghIssue}, jClient)
+	if err != nil {
+		return jira.Issue{}, false, err
+	}
+
+	jIssue, ok := jIssues[ghIssue.GetNumber()]
+	if !ok {
+		return jira.Issue{}, false, nil
+	}
+
+	// Search results don't include every field, such as comments.
+	jIssue, err = issuesyncjira.GetIssue(jClient, config.GetTimeout(), jIssue.Key)
+	if err != nil {
+		return jira.Issue{}, false, err
+	}
+	if jIssue.Fields == nil {
+		return jira.Issue{}, false, errors.New("JIRA issue has no fields")
+	}
+
+	return jIssue, true, nil
+}
+
+// withFields returns a copy of a JIRA issue with the fields of an update
+// applied to it, as JIRA would apply them.
+func withFields(jIssue jira.Issue, fields *jira.IssueFields) jira.Issue {
+	if fields == nil {
+		return jIssue
+	}
+
+	updated := *jIssue.Fields
+	updated.Summary = fields.Summary
+	updated.Description = fields.Description
+	if fields.Labels != nil {
+		updated.Labels = fields.Labels
+	}
+	if fields.FixVersions != nil {
+		updated.FixVersions = fields.FixVersions
+	}
+	updated.Unknowns = map[string]interface{}{}
+	for key, value := range jIssue.Fields.Unknowns {
+		updated.Unknowns[key] = value
+	}
+	for key, value := range fields.Unknowns {
+		updated.Unknowns[key] = value
+	}
+	jIssue.Fields = &updated
+
+	return jIssue
+}
+
+// describeChanges lists the differences between two versions of a JIRA issue,
+// in the fields issue-sync writes.
+func describeChanges(config cfg.Config, before jira.Issue, after jira.Issue) []string {
+	var changes []string
+
+	describe := func(field string, oldValue string, newValue string) {
+		if oldValue != newValue {
+			changes = append(changes, fmt.Sprintf("%s: %q -> %q", field, truncateChange(oldValue), truncateChange(newValue)))
+		}
+	}
+
+	describe("Summary", before.Fields.Summary, after.Fields.Summary)
+	describe("Description", before.Fields.Description, after.Fields.Description)
+
+	status := func(i jira.Issue) string {
+		if i.Fields.Status == nil {
+			return ""
+		}
+		return i.Fields.Status.Name
+	}
+	describe("Status", status(before), status(after))
+
+	versions := func(i jira.Issue) string {
+		names := make([]string, len(i.Fields.FixVersions))
+		for n, v := range i.Fields.FixVersions {
+			names[n] = v.Name
+		}
+		return strings.Join(names, ", ")
+	}
+	describe("Fix Versions", versions(before), versions(after))
+	describe("Labels", strings.Join(before.Fields.Labels, ", "), strings.Join(after.Fields.Labels, ", "))
+
+	for _, f := range []struct {
+		name string
+		key  cfg.FieldKey
+	}{
+		{"GitHub Labels", cfg.GitHubLabels},
+		{"GitHub Status", cfg.GitHubStatus},
+		{"GitHub Reporter", cfg.GitHubReporter},
+	} {
+		oldValue, _ := config.GetFieldMapper().GetFieldValue(&before, f.key)
+		newValue, _ := config.GetFieldMapper().GetFieldValue(&after, f.key)
+		describe(f.name, fmt.Sprint(oldValue), fmt.Sprint(newValue))
+	}
+
+	comments := func(i jira.Issue) int {
+		if i.Fields.Comments == nil {
+			return 0
+		}
+		return len(i.Fields.Comments.Comments)
+	}
+	if added := comments(after) - comments(before); added > 0 {
+		changes = append(changes, fmt.Sprintf("Comments: %d added", added))
+	}
+
+	return changes
+}
+
+// truncateChange shortens a field value for display in a list of changes.
+func truncateChange(s string) string {
+	const length = 60
+	s = strings.Replace(s, "\n", "\\n", -1)
+	if len(s) <= length {
+		return s
+	}
+	return s[:length] + "..."
+}
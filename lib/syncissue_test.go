@@ -0,0 +1,135 @@
+package lib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/v28/github"
+	"github.com/indeedeng/issue-sync/cfg"
+	"github.com/indeedeng/issue-sync/lib/issuesyncgithub"
+	"github.com/indeedeng/issue-sync/lib/issuesyncjira"
+)
+
+// syncIssueFields are the custom fields of the JIRA project SYNC.
+var syncIssueFields = map[string]int{
+	"GitHub ID":              10001,
+	"GitHub Number":          10002,
+	"GitHub Labels":          10003,
+	"GitHub Status":          10004,
+	"GitHub Reporter":        10005,
+	"Last Issue-Sync Update": 10006,
+}
+
+// newTestFieldConfig returns a configuration of the given settings whose field
+// IDs are loaded from a stub of the JIRA project SYNC.
+func newTestFieldConfig(t *testing.T, settings map[string]interface{}) cfg.Config {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rest/api/2/project/SYNC", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jira.Project{ID: "10000", Key: "SYNC"})
+	})
+	mux.HandleFunc("/rest/api/2/field", func(w http.ResponseWriter, r *http.Request) {
+		var fields []cfg.JIRAField
+		for name, id := range syncIssueFields {
+			field := cfg.JIRAField{Name: name}
+			field.Schema.CustomID = id
+			fields = append(fields, field)
+		}
+		json.NewEncoder(w).Encode(fields)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	jClient, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings["log-level"] = "error"
+	settings["repo-name"] = "owner/repo"
+	settings["jira-project"] = "SYNC"
+	config := cfg.NewConfigFromSettings(settings)
+	if err := config.LoadJIRAConfig(*jClient); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+func TestSyncIssueDryRunDescribesChanges(t *testing.T) {
+	config := newTestFieldConfig(t, map[string]interface{}{
+		"timeout": "1m",
+		"dry-run": true,
+	})
+
+	// The JIRA issue has the GitHub issue's old title and labels.
+	var jIssue jira.Issue
+	if err := json.Unmarshal([]byte(`{"id": "1", "key": "SYNC-1", "fields": {
+		"project": {"key": "SYNC"},
+		"summary": "Old title",
+		"description": "Steps to reproduce",
+		"customfield_10001": 1001,
+		"customfield_10002": 7,
+		"customfield_10003": "bug",
+		"customfield_10004": "open",
+		"customfield_10005": "octocat"
+	}}`), &jIssue); err != nil {
+		t.Fatal(err)
+	}
+
+	jClient := newTestPropertyClient(t, config).(issuesyncjira.TestJiraClient)
+	jClient.HandleGetFieldMapper = config.GetFieldMapper
+	jClient.HandleSearchIssues = func(jql string) (interface{}, *jira.Response, error) {
+		return []jira.Issue{jIssue}, &jira.Response{}, nil
+	}
+	jClient.HandleGetIssue = func(key string) (*jira.Issue, *jira.Response, error) {
+		issue := jIssue
+		return &issue, &jira.Response{}, nil
+	}
+	updates := 0
+	jClient.HandleUpdateIssue = func(issue *jira.Issue) (*jira.Issue, *jira.Response, error) {
+		// As in a dry run, nothing is written.
+		updates++
+		return issue, &jira.Response{}, nil
+	}
+
+	ghClient := issuesyncgithub.NewTestClient()
+	ghClient.HandleGetLogger = config.GetLogger
+	ghClient.HandleGetRepository = func(ctx context.Context, owner string, repo string) (*github.Repository, *github.Response, error) {
+		return &github.Repository{}, &github.Response{}, nil
+	}
+	ghClient.HandleGetIssue = func(ctx context.Context, owner string, repo string, number int) (*github.Issue, *github.Response, error) {
+		return &github.Issue{
+			ID:     github.Int64(1001),
+			Number: github.Int(7),
+			Title:  github.String("New title"),
+			Body:   github.String("Steps to reproduce"),
+			State:  github.String("open"),
+			User:   &github.User{Login: github.String("octocat")},
+			Labels: []github.Label{{Name: github.String("bug")}, {Name: github.String("ui")}},
+		}, &github.Response{}, nil
+	}
+	ghClient.HandleListComments = func(ctx context.Context, owner string, repo string, number int) ([]*github.IssueComment, *github.Response, error) {
+		return nil, &github.Response{}, nil
+	}
+
+	result, err := SyncIssue(config, 7, ghClient, jClient)
+	if err != nil {
+		t.Fatalf("SyncIssue failed with error: %v", err)
+	}
+	if updates != 1 {
+		t.Errorf("Expected the JIRA issue to be updated once; got %d updates", updates)
+	}
+	if result.Key != "SYNC-1" || result.Created {
+		t.Errorf("Expected SYNC-1 not to be created; got %+v", result)
+	}
+	expected := []string{
+		`Summary: "Old title" -> "New title"`,
+		`GitHub Labels: "bug" -> "bug,ui"`,
+	}
+	if strings.Join(result.Changes, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the changes:\n%s\nGot:\n%s", strings.Join(expected, "\n"), strings.Join(result.Changes, "\n"))
+	}
+}
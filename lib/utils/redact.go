@@ -0,0 +1,22 @@
+package utils
+
+import (
+	"regexp"
+)
+
+// redacted replaces secrets in logged request and response bodies.
+const redacted = "[REDACTED]"
+
+// secretFieldRegex matches JSON fields and form parameters whose names suggest
+// they hold a credential, capturing everything up to their value.
+var secretFieldRegex = regexp.MustCompile(`(?i)("?[a-z_-]*(?:password|passwd|secret|token|apikey|api_key|api-key|credential|private_key|signature)[a-z_-]*"?\s*[:=]\s*"?)([^"&,\s}]+)`)
+
+// authorizationRegex matches credentials sent in HTTP Authorization headers.
+var authorizationRegex = regexp.MustCompile(`(?i)\b(Basic|Bearer|OAuth)\s+[a-z0-9._~+/=-]{8,}`)
+
+// RedactSecrets replaces the values of the fields of a request or response
+// body which look like credentials, so that it can be logged.
+func RedactSecrets(body string) string {
+	body = secretFieldRegex.ReplaceAllString(body, "${1}"+redacted)
+	return authorizationRegex.ReplaceAllString(body, "$1 "+redacted)
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// TransportOptions are the network settings of the connections to a server.
+type TransportOptions struct {
+	// Proxy is the URL of the HTTP proxy to use; if it's empty, the proxy is
+	// taken from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// NoProxy are the hosts, domains and CIDR ranges to connect to directly.
+	NoProxy []string
+	// CABundle is the path of a PEM bundle of certificates to trust, besides
+	// the system's.
+	CABundle string
+	// ClientCert and ClientKey are the paths of the PEM certificate and key to
+	// authenticate with, for mutual TLS. If ClientKey is empty, the key is read
+	// from the certificate file.
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables the verification of the server's certificate;
+	// it's only meant for testing.
+	InsecureSkipVerify bool
+	// Headers are added to every request.
+	Headers map[string]string
+}
+
+// NewTransport creates an HTTP transport with the default settings, changed by
+// the options. With the zero value of TransportOptions, it's equivalent to the
+// default transport.
+func NewTransport(options TransportOptions) (http.RoundTripper, error) {
+	proxy, err := proxyFunc(options.Proxy, options.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if len(options.Headers) == 0 {
+		return transport, nil
+	}
+	return headerTransport{headers: options.Headers, base: transport}, nil
+}
+
+// newTLSConfig creates the TLS configuration of the options, or returns nil if
+// the default one will do.
+func newTLSConfig(options TransportOptions) (*tls.Config, error) {
+	if options.CABundle == "" && options.ClientCert == "" && !options.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.InsecureSkipVerify}
+
+	if options.CABundle != "" {
+		pem, err := ioutil.ReadFile(options.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA bundle: %v", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("CA bundle contains no PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.ClientCert != "" {
+		key := options.ClientKey
+		if key == "" {
+			key = options.ClientCert
+		}
+		cert, err := tls.LoadX509KeyPair(options.ClientCert, key)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// proxyFunc returns the function choosing the proxy of each request: the given
+// proxy, or that of the environment if it's empty, except for the hosts matching
+// the no-proxy list.
+func proxyFunc(proxy string, noProxy []string) (func(*http.Request) (*url.URL, error), error) {
+	fromConfig := http.ProxyFromEnvironment
+	if proxy != "" {
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+		}
+		fromConfig = http.ProxyURL(proxyURL)
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if matchesNoProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return fromConfig(req)
+	}, nil
+}
+
+// matchesNoProxy returns whether a URL matches one of the entries of a no-proxy
+// list: "*", a host, optionally with a port, a domain, which also matches its
+// subdomains, or a CIDR range.
+func matchesNoProxy(u *url.URL, noProxy []string) bool {
+	host := u.Hostname()
+	ip := net.ParseIP(host)
+
+	for _, entry := range noProxy {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		switch {
+		case entry == "":
+		case entry == "*":
+			return true
+		case strings.Contains(entry, "/"):
+			if _, cidr, err := net.ParseCIDR(entry); err == nil && ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		case entry == strings.ToLower(u.Host):
+			return true
+		default:
+			domain := strings.TrimPrefix(entry, ".")
+			host = strings.ToLower(host)
+			if host == domain || strings.HasSuffix(host, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headerTransport adds headers to each request.
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request they're given.
+	withHeaders := req.WithContext(req.Context())
+	withHeaders.Header = make(http.Header, len(req.Header)+len(t.headers))
+	for k, v := range req.Header {
+		withHeaders.Header[k] = v
+	}
+	for k, v := range t.headers {
+		withHeaders.Header.Set(k, v)
+	}
+
+	return t.base.RoundTrip(withHeaders)
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestMatchesNoProxy(t *testing.T) {
+	noProxy := []string{"jira.internal:8443", ".corp.example.com", "example.org", "10.0.0.0/8"}
+
+	tests := []struct {
+		url   string
+		match bool
+	}{
+		{"https://jira.internal:8443/rest/api/2", true},
+		{"https://jira.internal/rest/api/2", false},
+		{"https://jira.corp.example.com/", true},
+		{"https://corp.example.com/", true},
+		{"https://example.org/", true},
+		{"https://www.example.org/", true},
+		{"https://notexample.org/", false},
+		{"https://10.1.2.3/", true},
+		{"https://192.168.0.1/", false},
+		{"https://api.github.com/", false},
+	}
+
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+		if got := matchesNoProxy(u, noProxy); got != test.match {
+			t.Errorf("matchesNoProxy(%s) = %t; expected %t", test.url, got, test.match)
+		}
+	}
+
+	u, _ := url.Parse("https://api.github.com/")
+	if !matchesNoProxy(u, []string{"*"}) {
+		t.Error("Expected * to match every host")
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Custom")
+	}))
+	defer server.Close()
+
+	transport, err := NewTransport(TransportOptions{
+		Proxy:   "http://proxy.invalid:3128",
+		NoProxy: []string{"127.0.0.1"},
+		Headers: map[string]string{"x-custom": "value"},
+	})
+	if err != nil {
+		t.Fatalf("NewTransport failed with error: %v", err)
+	}
+
+	res, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request failed with error: %v", err)
+	}
+	res.Body.Close()
+
+	if header != "value" {
+		t.Errorf("Expected the custom header to be sent; got %q", header)
+	}
+
+	if _, err := NewTransport(TransportOptions{Proxy: "not a URL"}); err == nil {
+		t.Error("Expected an error for an invalid proxy URL")
+	}
+}
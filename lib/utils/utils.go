@@ -18,35 +18,75 @@ func GetOrElse(value interface{}, err error) func(defaultValue interface{}) inte
 
 const retryBackoffRoundRatio = time.Millisecond / time.Nanosecond
 
+// PermanentError is an error which Retry returns at once, without retrying, such
+// as a 404 response.
+type PermanentError struct {
+	Err error
+}
+
+func (e PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// Permanent marks an error returned to Retry as one retrying can't fix.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return PermanentError{Err: err}
+}
+
 // retry takes any function f: () -> (interface, interface, error) and calls it with exponential backoff.
 // If the function succeeds, it returns the values returned by f and the GitHub API response.
 // If it continues to fail until a maximum time is reached, the last return values from the function as well
-// as a timeout error.
+// as a timeout error. A PermanentError is returned at once, unwrapped. The time spent, including retries, is logged in the `duration_ms` field.
 func Retry(log logrus.Entry, timeout time.Duration, f func() (interface{}, interface{}, error)) (interface{}, interface{}, error) {
 
 	var ret interface{}
 	var res interface{}
+	var permanent error
 
 	op := func() error {
 		var err error
 		ret, res, err = f()
+		if p, ok := err.(PermanentError); ok {
+			permanent = p.Err
+			return nil
+		}
 		return err
 	}
 
 	b := backoff.NewExponentialBackOff()
 	b.MaxElapsedTime = timeout
 
+	start := time.Now()
 	backoffErr := backoff.RetryNotify(op, b, func(err error, duration time.Duration) {
 		// Round to a whole number of milliseconds
 		duration /= retryBackoffRoundRatio // Convert nanoseconds to milliseconds
 		duration *= retryBackoffRoundRatio // Convert back so it appears correct
 
-		log.Errorf("error performing operation; retrying in %v: %v", duration, err)
+		log.WithField("duration_ms", DurationMillis(time.Since(start))).Errorf("error performing operation; retrying in %v: %v", duration, err)
 	})
+	if permanent != nil {
+		backoffErr = permanent
+	}
+
+	done := log.WithField("duration_ms", DurationMillis(time.Since(start)))
+	if backoffErr != nil {
+		done.WithError(backoffErr).Debug("operation failed")
+	} else {
+		done.Debug("operation succeeded")
+	}
 
 	return ret, res, backoffErr
 }
 
+// DurationMillis returns a duration as a whole number of milliseconds, as logged
+// in `duration_ms` fields.
+func DurationMillis(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
 // treats nil the same as empty slice.
 func SliceStringsEq(a []string, b []string) bool {
 
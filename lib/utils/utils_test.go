@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestRetryPermanent(t *testing.T) {
+	log := *logrus.NewEntry(logrus.New())
+	notFound := errors.New("404 Not Found")
+
+	calls := 0
+	_, _, err := Retry(log, time.Minute, func() (interface{}, interface{}, error) {
+		calls++
+		return nil, nil, Permanent(notFound)
+	})
+	if err != notFound {
+		t.Errorf("Expected the permanent error to be returned unwrapped; got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a permanent error not to be retried; got %d calls", calls)
+	}
+
+	calls = 0
+	_, _, err = Retry(log, time.Minute, func() (interface{}, interface{}, error) {
+		calls++
+		if calls < 2 {
+			return nil, nil, errors.New("502 Bad Gateway")
+		}
+		return nil, nil, nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Expected a transient error to be retried; got %d calls and error %v", calls, err)
+	}
+}